@@ -1,10 +1,13 @@
 from fastapi import FastAPI
 from v1.routers import router
 from mangum import Mangum
-from v1.settings import APP_PORT
+from v1.audit import AUDIT_STDOUT, Auditor, Redactor
+from v1.settings import APP_PORT, AUDIT_SINK, AUDIT_REDACT_FIELDS, AUDIT_REDACTION, AUDIT_HASH_KEY
 
 app = FastAPI(title='Cytora GeoSpatial Functions', description='Cytora powered GeoSpatial Functions powered by AWS, PostGIS, AirFlow, etc.')
 app.include_router(router, prefix='/v1')
+if AUDIT_SINK == AUDIT_STDOUT:
+    app.middleware('http')(Auditor(Redactor(AUDIT_REDACTION, AUDIT_REDACT_FIELDS, AUDIT_HASH_KEY.encode())))
 
 
 @app.get('/')
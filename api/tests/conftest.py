@@ -0,0 +1,5 @@
+import os
+import sys
+
+# the service is run from api/, where v1 is a top level package
+sys.path.insert(0, os.path.join(os.path.dirname(os.path.abspath(__file__)), '..'))
@@ -0,0 +1,81 @@
+import io
+import json
+
+import pytest
+from fastapi import FastAPI
+from fastapi.testclient import TestClient
+
+from v1 import geospatial_general
+from v1.audit import REDACT_DROP, REDACT_HASH, Auditor, Redactor, outcome, parse_fields
+from v1.entitlements import EntitlementsStore, parse_entitlements
+
+RECORD = {'partner_id': 'acme', 'layer': 'flood', 'lat': '51.5', 'lon': '-0.1', 'status': 200}
+
+
+def test_redact_nothing():
+    assert Redactor(REDACT_HASH, [], b'').redact(RECORD) == RECORD
+
+
+def test_redact_drop():
+    got = Redactor(REDACT_DROP, ['coordinates'], b'').redact(RECORD)
+    assert got == {'partner_id': 'acme', 'layer': 'flood', 'lat': None, 'lon': None, 'status': 200}
+
+
+def test_redact_hash():
+    got = Redactor(REDACT_HASH, ['partner_id', 'layer'], b'secret').redact(RECORD)
+    assert got['partner_id'].startswith('hmac-sha256:')
+    assert got['layer'] != RECORD['layer']
+    assert got['lat'] == RECORD['lat']
+    # the digest of the Go function's redactor for the same key and value
+    assert Redactor(REDACT_HASH, ['layer'], b'secret').value('00111222') == \
+        'hmac-sha256:9e4a00d74885d915e4a57e92169e5813900762c833f2afd5787354484bd42f3a'
+
+
+@pytest.mark.parametrize('mode, fields, key', [
+    ('mask', [], b''),
+    (REDACT_HASH, ['email'], b'secret'),
+    (REDACT_HASH, ['layer'], b''),
+])
+def test_redactor_invalid(mode, fields, key):
+    with pytest.raises(ValueError):
+        Redactor(mode, fields, key)
+
+
+@pytest.mark.parametrize('status, expected', [
+    (200, 'success'), (403, 'denied'), (429, 'rate_limited'), (422, 'rejected'), (504, 'error'),
+])
+def test_outcome(status, expected):
+    assert outcome(status) == expected
+
+
+def test_parse_fields():
+    assert parse_fields('') == []
+    assert parse_fields('partner_id, coordinates') == ['partner_id', 'coordinates']
+
+
+def test_auditor(monkeypatch):
+    monkeypatch.setattr(geospatial_general, 'entitlements', EntitlementsStore(static=parse_entitlements('{}')))
+    out = io.StringIO()
+    app = FastAPI()
+    app.include_router(geospatial_general.router, prefix='/v1')
+    app.middleware('http')(Auditor(Redactor(REDACT_DROP, ['coordinates'], b''), out=out, now=lambda: 1615377600.0))
+
+    # the API gateway's authorizer, added last so it runs first
+    @app.middleware('http')
+    async def authorize(request, call_next):
+        request.scope['aws.event'] = {'requestContext': {'authorizer': {'partner_id': 'acme'}}}
+        return await call_next(request)
+
+    res = TestClient(app).get('/v1/intersect/?latitude=51.5&longitude=-0.1&layer=flood')
+    assert res.status_code == 403
+    assert json.loads(out.getvalue()) == {
+        'type': 'audit',
+        'time': '2021-03-10T12:00:00Z',
+        'partner_id': 'acme',
+        'endpoint': 'IntersectsWithLatLon',
+        'method': 'GET',
+        'layer': 'flood',
+        'status': 403,
+        'outcome': 'denied',
+        'latency_ms': 0,
+    }
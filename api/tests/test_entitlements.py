@@ -0,0 +1,93 @@
+import pytest
+from fastapi import FastAPI
+from fastapi.testclient import TestClient
+
+from v1 import geospatial_general
+from v1.entitlements import EntitlementsStore, parse_entitlements, partner_id
+
+ENTITLEMENTS = '''{
+    "partner": {"groups": ["dnb"], "layers": ["geo_uk_haz_t10_03"]},
+    "everything": {"groups": ["*"], "layers": ["*"]},
+    "no_layers": {"groups": ["dnb"]}
+}'''
+
+
+class Clock():
+    def __init__(self):
+        self.seconds = 0.0
+
+    def __call__(self):
+        return self.seconds
+
+
+def test_parse_entitlements():
+    entitlements = parse_entitlements(ENTITLEMENTS)
+    assert entitlements['partner'].allows_layer('geo_uk_haz_t10_03')
+    assert not entitlements['partner'].allows_layer('geo_uk_haz_t5_03')
+    assert entitlements['everything'].allows_layer('geo_uk_haz_t5_03')
+    assert not entitlements['no_layers'].allows_layer('geo_uk_haz_t10_03')
+    assert parse_entitlements('') == {}
+
+
+def test_parse_entitlements_invalid():
+    with pytest.raises(ValueError):
+        parse_entitlements('{"partner": null}')
+
+
+def test_store_load():
+    clock = Clock()
+    loads = []
+
+    def load(partner):
+        loads.append(partner)
+        return ['geo_uk_haz_t10_03'] if partner == 'partner' else None
+
+    store = EntitlementsStore(load=load, ttl_seconds=60, now=clock)
+    assert store.entitlements('partner').allows_layer('geo_uk_haz_t10_03')
+    assert store.entitlements('unknown') is None
+    assert store.entitlements('partner') is not None
+    assert store.entitlements('unknown') is None
+    assert loads == ['partner', 'unknown']
+
+    clock.seconds = 60
+    store.entitlements('partner')
+    assert loads == ['partner', 'unknown', 'partner']
+
+
+@pytest.fixture
+def client(monkeypatch):
+    def select_query_dict(connection, query, data=[]):
+        raise AssertionError('the database should not be queried')
+
+    monkeypatch.setattr(geospatial_general, 'entitlements', EntitlementsStore(static=parse_entitlements(ENTITLEMENTS)))
+    monkeypatch.setattr(geospatial_general, 'select_query_dict', select_query_dict)
+    app = FastAPI()
+    app.include_router(geospatial_general.router, prefix='/v1')
+
+    def client_of(partner):
+        app.dependency_overrides[partner_id] = lambda: partner
+        return TestClient(app)
+    return client_of
+
+
+@pytest.mark.parametrize('partner, path, detail', [
+    ('partner', '/v1/intersect/?latitude=52.71&longitude=-1.82&layer=geo_uk_haz_t5_03', 'layer geo_uk_haz_t5_03'),
+    ('no_layers', '/v1/intersect/?latitude=52.71&longitude=-1.82&layer=geo_uk_haz_t10_03',
+     'layer geo_uk_haz_t10_03'),
+    ('unknown', '/v1/intersect/?latitude=52.71&longitude=-1.82&layer=geo_uk_haz_t10_03',
+     'partner unknown has no entitlements'),
+    ('unknown', '/v1/discovery/layers', 'partner unknown has no entitlements'),
+    (None, '/v1/discovery/layers', 'missing partner'),
+])
+def test_forbidden(client, partner, path, detail):
+    res = client(partner).get(path, headers={'X-Request-Id': 'req-1'})
+    assert res.status_code == 403
+    assert res.headers['content-type'] == 'application/problem+json'
+    assert res.json() == {
+        'type': '/problems/forbidden',
+        'title': 'Forbidden',
+        'status': 403,
+        'detail': detail,
+        'instance': path.split('?')[0],
+        'request_id': 'req-1',
+    }
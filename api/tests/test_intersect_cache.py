@@ -0,0 +1,98 @@
+import pytest
+
+from v1.intersect_cache import IntersectCache, parse_precisions
+
+LAYER = 'geo_uk_haz_t10_03'
+RESULT = [{'id': 2558, 't10_id': '10_1_2558', 'country': 'Great Britain', 'area_km2': 19}]
+
+
+class Clock():
+    def __init__(self):
+        self.seconds = 0.0
+
+    def __call__(self):
+        return self.seconds
+
+
+@pytest.fixture
+def clock():
+    return Clock()
+
+
+@pytest.fixture
+def cache(clock):
+    return IntersectCache(2, {LAYER: 4}, ttl_seconds=60, version_ttl_seconds=10, now=clock)
+
+
+def test_hit_and_miss(cache):
+    key = cache.key(LAYER, 'v1', 52.71, -1.82)
+    assert cache.get(key) is None
+
+    cache.set(key, RESULT)
+    assert cache.get(key) == RESULT
+    assert cache.get(cache.key(LAYER, 'v1', 52.72, -1.82)) is None
+
+
+def test_layer_without_precision_isnt_cached(cache):
+    assert cache.key('geo_uk_haz_t5_03', 'v1', 52.71, -1.82) is None
+
+
+def test_snapping(cache):
+    key = cache.key(LAYER, 'v1', 52.71, -1.82)
+    cache.set(key, RESULT)
+
+    # 4 decimal places, the points within the same ~11m cell share the result
+    assert cache.key(LAYER, 'v1', 52.71004, -1.81996) == key
+    assert cache.get(cache.key(LAYER, 'v1', 52.71004, -1.81996)) == RESULT
+    assert cache.key(LAYER, 'v1', 52.71006, -1.82) != key
+    assert cache.get(cache.key(LAYER, 'v1', 52.7101, -1.82)) is None
+
+
+def test_version_invalidation(cache, clock):
+    cache.set_version(LAYER, 'v1')
+    cache.set(cache.key(LAYER, cache.version(LAYER), 52.71, -1.82), RESULT)
+
+    # the layer is reloaded, the results of the previous version aren't served
+    cache.set_version(LAYER, 'v2')
+    assert cache.get(cache.key(LAYER, cache.version(LAYER), 52.71, -1.82)) is None
+
+    # the version is looked up again once it expires
+    clock.seconds = 10
+    assert cache.version(LAYER) is None
+
+
+def test_ttl(cache, clock):
+    key = cache.key(LAYER, 'v1', 52.71, -1.82)
+    cache.set(key, RESULT)
+
+    clock.seconds = 59
+    assert cache.get(key) == RESULT
+    clock.seconds = 60
+    assert cache.get(key) is None
+
+
+def test_size(cache):
+    keys = [cache.key(LAYER, 'v1', 52.71 + i, -1.82) for i in range(3)]
+    cache.set(keys[0], RESULT)
+    cache.set(keys[1], RESULT)
+    # reading keys[0] makes keys[1] the least recently used entry, it's evicted
+    assert cache.get(keys[0]) == RESULT
+    cache.set(keys[2], RESULT)
+
+    assert cache.get(keys[0]) == RESULT
+    assert cache.get(keys[1]) is None
+    assert cache.get(keys[2]) == RESULT
+
+
+@pytest.mark.parametrize('value, expected', [
+    ('', {}),
+    ('{"geo_uk_haz_t10_03": 4}', {LAYER: 4}),
+])
+def test_parse_precisions(value, expected):
+    assert parse_precisions(value) == expected
+
+
+@pytest.mark.parametrize('value', ['{"geo_uk_haz_t10_03": -1}', '{"geo_uk_haz_t10_03": "4"}', 'not json'])
+def test_parse_precisions_invalid(value):
+    with pytest.raises(ValueError):
+        parse_precisions(value)
@@ -0,0 +1,134 @@
+import hashlib
+import hmac
+import json
+import logging
+import sys
+import time
+from datetime import datetime, timezone
+from typing import Optional
+
+from fastapi import Request
+
+from .entitlements import partner_id
+
+AUDIT_NONE = 'none'
+AUDIT_STDOUT = 'stdout'
+
+REDACT_HASH = 'hash'
+REDACT_DROP = 'drop'
+
+FIELD_PARTNER_ID = 'partner_id'
+FIELD_CRN = 'crn'
+FIELD_LAYER = 'layer'
+FIELD_COORDINATES = 'coordinates'
+REDACTABLE = {FIELD_PARTNER_ID, FIELD_CRN, FIELD_LAYER, FIELD_COORDINATES}
+
+# keys of the records hidden by field, the crns aren't looked up by the geospatial lookups
+REDACTED_KEYS = {
+    FIELD_PARTNER_ID: ['partner_id'],
+    FIELD_LAYER: ['layer'],
+    FIELD_COORDINATES: ['lat', 'lon'],
+}
+
+# endpoints audited by path, named after the operations of the OpenAPI document
+ENDPOINTS = {
+    '/v1/discovery/layers': 'DataDiscovery',
+    '/v1/intersect/': 'IntersectsWithLatLon',
+}
+
+
+class Redactor():
+    '''
+    purpose: hide the configured fields of the audit records like the Go function does, hashed
+    values are keyed HMACs so the few possible layers or coordinates can't be hashed again to
+    find them
+    '''
+
+    def __init__(self, mode: str, fields: list, key: bytes):
+        if mode not in (REDACT_HASH, REDACT_DROP):
+            raise ValueError(f'invalid audit redaction {mode!r}')
+        unknown = set(fields) - REDACTABLE
+        if unknown:
+            raise ValueError(f'unknown audit redactable fields {sorted(unknown)}')
+        if mode == REDACT_HASH and fields and not key:
+            raise ValueError('AUDIT_HASH_KEY is required to hash AUDIT_REDACT_FIELDS')
+        self.mode = mode
+        self.fields = set(fields)
+        self.key = key
+
+    def redact(self, record: dict) -> dict:
+        record = dict(record)
+        for field, keys in REDACTED_KEYS.items():
+            if field not in self.fields:
+                continue
+            for k in keys:
+                record[k] = self.value(record.get(k))
+        return record
+
+    def value(self, value: Optional[str]) -> Optional[str]:
+        if not value or self.mode == REDACT_DROP:
+            return None
+        return 'hmac-sha256:' + hmac.new(self.key, value.encode(), hashlib.sha256).hexdigest()
+
+
+def outcome(status: int) -> str:
+    if status < 400:
+        return 'success'
+    if status in (401, 403):
+        return 'denied'
+    if status == 429:
+        return 'rate_limited'
+    if status < 500:
+        return 'rejected'
+    return 'error'
+
+
+def parse_fields(value: str) -> list:
+    '''
+    parses the comma separated AUDIT_REDACT_FIELDS
+    '''
+    return [f.strip() for f in value.split(',') if f.strip()]
+
+
+class Auditor():
+    '''
+    purpose: write an audit record of every geospatial lookup as a JSON line tagged audit, the
+    records of the Go function's format so a log subscription routes both apart from the debug logs
+    '''
+
+    def __init__(self, redactor: Redactor, out=sys.stdout, now=time.time):
+        self.redactor = redactor
+        self.out = out
+        self.now = now
+
+    def record(self, request: Request, endpoint: str, status: int, latency_ms: int, ts: float) -> dict:
+        query = request.query_params
+        record = {
+            'time': datetime.fromtimestamp(ts, timezone.utc).isoformat().replace('+00:00', 'Z'),
+            'partner_id': partner_id(request),
+            'endpoint': endpoint,
+            'method': request.method,
+            'layer': query.get('layer'),
+            'lat': query.get('latitude'),
+            'lon': query.get('longitude'),
+            'status': status,
+            'outcome': outcome(status),
+            'latency_ms': latency_ms,
+        }
+        record = self.redactor.redact(record)
+        # like the Go records, empty fields are left out
+        return {'type': 'audit', **{k: v for k, v in record.items() if v is not None and v != ''}}
+
+    async def __call__(self, request: Request, call_next):
+        endpoint = ENDPOINTS.get(request.url.path)
+        if endpoint is None:
+            return await call_next(request)
+        ts = self.now()
+        response = await call_next(request)
+        latency_ms = int((self.now() - ts) * 1000)
+        try:
+            line = json.dumps(self.record(request, endpoint, response.status_code, latency_ms, ts))
+            print(line, file=self.out, flush=True)
+        except Exception as e:
+            logging.error(f'failed to write audit record of {endpoint}: {e}')
+        return response
@@ -0,0 +1,109 @@
+import json
+import threading
+import time
+from typing import Callable, Optional
+
+from fastapi import Request
+from fastapi.responses import JSONResponse
+
+WILDCARD = '*'
+
+ENTITLEMENTS_NONE = 'none'
+ENTITLEMENTS_CONFIG = 'config'
+ENTITLEMENTS_DB = 'db'
+
+PROBLEM_CONTENT_TYPE = 'application/problem+json'
+PROBLEM_FORBIDDEN = '/problems/forbidden'
+
+
+class Entitlements():
+    '''
+    purpose: the layers a partner is allowed to intersect, the wildcard allows every layer.
+    The groups and fields are only enforced by the Go function.
+    '''
+
+    def __init__(self, partner_id: str, layers: list):
+        self.partner_id = partner_id
+        self.layers = layers or []
+
+    def allows_layer(self, layer: str) -> bool:
+        return layer in self.layers or WILDCARD in self.layers
+
+
+class EntitlementsStore():
+    '''
+    purpose: look the partners' entitlements up, from the configuration or with load.
+    load returns the partner's layers, None for an unknown partner. The entitlements it
+    loads are kept for ttl_seconds to save a query per request.
+    '''
+
+    def __init__(self, static: Optional[dict] = None, load: Optional[Callable[[str], Optional[list]]] = None,
+                 ttl_seconds: float = 0, now=time.monotonic):
+        self.static = static or {}
+        self.load = load
+        self.ttl = ttl_seconds
+        self.now = now
+        self.lock = threading.Lock()
+        self.entries = {}
+
+    def entitlements(self, partner_id: str) -> Optional[Entitlements]:
+        '''
+        returns the partner's entitlements, None for an unknown partner
+        '''
+        if self.load is None:
+            return self.static.get(partner_id)
+        with self.lock:
+            entry = self.entries.get(partner_id)
+            if entry is not None and self.now() < entry[1]:
+                return entry[0]
+        layers = self.load(partner_id)
+        entitlements = None if layers is None else Entitlements(partner_id, layers)
+        with self.lock:
+            self.entries[partner_id] = (entitlements, self.now() + self.ttl)
+        return entitlements
+
+
+def parse_entitlements(value: str) -> dict:
+    '''
+    parses the JSON object of the entitlements keyed by partner ID, the format the Go function
+    reads, e.g. {"partner": {"groups": ["dnb"], "layers": ["*"]}}
+    '''
+    if not value:
+        return {}
+    entitlements = {}
+    for partner_id, e in json.loads(value).items():
+        if not isinstance(e, dict):
+            raise ValueError(f'partner {partner_id} has no entitlements')
+        entitlements[partner_id] = Entitlements(partner_id, e.get('layers'))
+    return entitlements
+
+
+def partner_id(request: Request) -> Optional[str]:
+    '''
+    returns the partner authenticated by the API gateway's authorizer, None when the request
+    carries none. Tests override the dependency.
+    '''
+    event = request.scope.get('aws.event') or {}
+    authorizer = (event.get('requestContext') or {}).get('authorizer') or {}
+    return authorizer.get('partner_id') or (authorizer.get('lambda') or {}).get('partner_id')
+
+
+def problem(request: Request, status: int, type: str, title: str, detail: str) -> JSONResponse:
+    '''
+    returns the RFC 7807 problem the Go function answers with
+    '''
+    body = {
+        'type': type,
+        'title': title,
+        'status': status,
+        'detail': detail,
+        'instance': request.url.path,
+    }
+    request_id = request.headers.get('X-Request-Id')
+    if request_id:
+        body['request_id'] = request_id
+    return JSONResponse(body, status_code=status, media_type=PROBLEM_CONTENT_TYPE)
+
+
+def forbidden(request: Request, detail: str) -> JSONResponse:
+    return problem(request, 403, PROBLEM_FORBIDDEN, 'Forbidden', detail)
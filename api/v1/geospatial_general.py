@@ -2,19 +2,27 @@ import psycopg2
 from psycopg2 import Error
 import ast
 import json
-from fastapi import APIRouter
+from fastapi import APIRouter, Depends, Request
 from typing import Optional
 
 import logging
 import os
 import time
 
+from .entitlements import ENTITLEMENTS_CONFIG, ENTITLEMENTS_DB, EntitlementsStore, forbidden, partner_id
+from .intersect_cache import IntersectCache
+from .settings import INTERSECT_CACHE_SIZE, INTERSECT_CACHE_PRECISIONS, INTERSECT_CACHE_TTL_SECONDS, \
+    LAYER_VERSION_TTL_SECONDS, ENTITLEMENTS_SOURCE, ENTITLEMENTS, ENTITLEMENTS_TTL_SECONDS
+
 logger = logging.getLogger()
 logger.setLevel(logging.DEBUG)
 
 
 router = APIRouter()
 
+intersect_cache = IntersectCache(INTERSECT_CACHE_SIZE, INTERSECT_CACHE_PRECISIONS, INTERSECT_CACHE_TTL_SECONDS,
+                                 LAYER_VERSION_TTL_SECONDS)
+
 
 def select_query_dict(connection, query, data=[]):
     """
@@ -54,6 +62,25 @@ def select_query_dict(connection, query, data=[]):
     return results
 
 
+def layer_version(connection, layer):
+    '''
+    returns the data version of the layer, it changes whenever the layer's table is reloaded or its
+    rows are written, None when the layer doesn't exist
+    '''
+    sql = '''
+        select concat_ws(':', c.relfilenode, coalesce(s.n_tup_ins, 0), coalesce(s.n_tup_upd, 0),
+            coalesce(s.n_tup_del, 0)) as version
+        from pg_class c
+        join pg_namespace n on n.oid = c.relnamespace
+        left join pg_stat_user_tables s on s.relid = c.oid
+        where n.nspname = 'public' and c.relname = %s;
+    '''
+    res = select_query_dict(connection, sql, [layer])
+    if not res:
+        return None
+    return res[0]['version']
+
+
 class PostgresConfiguration():
     POSTGRESQL_DB_HOST = None
     POSTGRESQL_DB_NAME = None
@@ -78,8 +105,48 @@ class PostgresConfiguration():
         )
 
 
+def load_partner_layers(partner):
+    '''
+    returns the layers the partner is entitled to from the partner_entitlements table, None for an
+    unknown partner
+    '''
+    sql = '''
+        select coalesce(layers, '{}') as layers
+        from partner_entitlements
+        where partner_id = %s;
+    '''
+    with PostgresConfiguration().pg2 as con:
+        res = select_query_dict(con, sql, [partner])
+    if not res:
+        return None
+    return res[0]['layers']
+
+
+# None when the partners aren't restricted
+entitlements = None
+if ENTITLEMENTS_SOURCE == ENTITLEMENTS_CONFIG:
+    entitlements = EntitlementsStore(static=ENTITLEMENTS)
+elif ENTITLEMENTS_SOURCE == ENTITLEMENTS_DB:
+    entitlements = EntitlementsStore(load=load_partner_layers, ttl_seconds=ENTITLEMENTS_TTL_SECONDS)
+
+
+def partner_entitlements(request, partner):
+    '''
+    returns the partner's entitlements, None when the partners aren't restricted, and the 403
+    problem of a request without partner or of a partner without entitlements
+    '''
+    if entitlements is None:
+        return None, None
+    if not partner:
+        return None, forbidden(request, 'missing partner')
+    e = entitlements.entitlements(partner)
+    if e is None:
+        return None, forbidden(request, f'partner {partner} has no entitlements')
+    return e, None
+
+
 @router.get('/discovery/layers')
-async def get_discovery():
+async def get_discovery(request: Request, partner: Optional[str] = Depends(partner_id)):
     '''
     purpose: function discover available GeoSpatial Layers/Tables for query/search.
     get all tables with GEOM column in public Schema and respond back with object as follows:
@@ -89,7 +156,8 @@ async def get_discovery():
             {
                 "gis_layer": "geo_uk_haz_t100_03",
                 "srid": 4326,
-                "count": 1355
+                "count": 1355,
+                "version": "16385:1355:0:0"
             },
             {
                 "gis_layer": "geo_uk_haz_t10_03",
@@ -106,7 +174,14 @@ async def get_discovery():
     }
     SRID stands for Spatial Reference ID. 4326 => WGS84, 27770 => UK GRID, ...
     COUNT presents number of objects/rows in given table/layer
+    VERSION changes whenever the layer's data is reloaded, the cached intersect results of older versions
+    aren't served
+
+    Only the layers the partner is entitled to are listed, partners without entitlements get a 403.
     '''
+    e, problem = partner_entitlements(request, partner)
+    if problem is not None:
+        return problem
 
     sql = '''
         select
@@ -121,6 +196,8 @@ async def get_discovery():
     start = time.perf_counter()
     with PostgresConfiguration().pg2 as con:
         res = select_query_dict(con, sql)
+    if e is not None:
+        res = [el for el in res if e.allows_layer(el['gis_layer'])]
 
     for el in res:
         print(el)
@@ -148,13 +225,16 @@ async def get_discovery():
             el['geometry'] = geom_typ[0]
             d = ast.literal_eval(ext[0]['extent'])
             el['extent'] = d
+            el['version'] = layer_version(con, el['gis_layer'])
+            intersect_cache.set_version(el['gis_layer'], el['version'])
 
     obj = {'layers': res, 'exec_time_seconds': f'{time.perf_counter() - start}'}
     return obj
 
 
 @router.get('/intersect/')
-async def get_intersection(latitude: float, longitude: float, layer: str):
+async def get_intersection(request: Request, latitude: float, longitude: float, layer: str,
+                           partner: Optional[str] = Depends(partner_id)):
     '''
     purpose: Find Intersection/drill down between caller provided lat, lon and feature layer name
     example
@@ -176,9 +256,43 @@ async def get_intersection(latitude: float, longitude: float, layer: str):
                 "area_km2": 19
             }
         ],
-        "exec_time_seconds": "0.6775946429999635"
+        "exec_time_seconds": "0.6775946429999635",
+        "cached": false
     }
+
+    The results of the layers with a cache precision configured are cached by snapped point and
+    layer version, cached tells whether they were served from the cache.
+
+    The layers the partner isn't entitled to are rejected with a 403.
     '''
+    e, problem = partner_entitlements(request, partner)
+    if problem is not None:
+        return problem
+    if e is not None and not e.allows_layer(layer):
+        return forbidden(request, f'layer {layer}')
+    start = time.perf_counter()
+    version = intersect_cache.version(layer)
+    if version is None and layer in INTERSECT_CACHE_PRECISIONS:
+        with PostgresConfiguration().pg2 as con:
+            version = layer_version(con, layer)
+        if version is not None:
+            intersect_cache.set_version(layer, version)
+    key = None
+    if version is not None:
+        key = intersect_cache.key(layer, version, latitude, longitude)
+    if key is not None:
+        res = intersect_cache.get(key)
+        if res is not None:
+            return {
+                'request': {
+                    'lat': latitude,
+                    'lon': longitude,
+                    'layer': layer},
+                'response': res,
+                'exec_time_seconds': f'{time.perf_counter() - start}',
+                'cached': True
+            }
+
     sql = f'''
     SELECT
     -- ST_AsGeoJSON(geom) as g,
@@ -186,7 +300,6 @@ async def get_intersection(latitude: float, longitude: float, layer: str):
     FROM {layer}
     WHERE ST_Intersects(geom, 'SRID=4326;POINT({longitude} {latitude})');
     '''
-    start = time.perf_counter()
     with PostgresConfiguration().pg2 as con:
         res = select_query_dict(con, sql)
 
@@ -196,13 +309,17 @@ async def get_intersection(latitude: float, longitude: float, layer: str):
         del el['geom']
         #del el['g']
 
+    if key is not None:
+        intersect_cache.set(key, res)
+
     obj = {
         'request': {
             'lat': latitude,
             'lon': longitude,
             'layer': layer},
         'response': res,
-        'exec_time_seconds': f'{time.perf_counter() - start}'
+        'exec_time_seconds': f'{time.perf_counter() - start}',
+        'cached': False
     }
     #obj['response'].append(ast.literal_eval(geometry))
     return obj
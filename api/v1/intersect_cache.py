@@ -0,0 +1,84 @@
+import json
+import threading
+import time
+from collections import OrderedDict
+from typing import Optional
+
+
+class IntersectCache():
+    '''
+    purpose: keep the intersect results of the points recently requested.
+    A point is snapped to the precision, in decimal places, configured for its layer and
+    the points sharing a cell share the result, so a precision is only configured when
+    the layer's features are much larger than a cell: 4 decimal places are ~11m cells.
+    Layers without a precision aren't cached.
+
+    Entries are keyed by the layer's data version, reported by discovery, so reloading
+    a layer invalidates them; ttl_seconds bounds how long an entry is served anyway.
+    The layers' versions are looked up again after version_ttl_seconds.
+    '''
+
+    def __init__(self, size: int, precisions: dict, ttl_seconds: float, version_ttl_seconds: float, now=time.monotonic):
+        self.size = size
+        self.precisions = precisions
+        self.ttl = ttl_seconds
+        self.version_ttl = version_ttl_seconds
+        self.now = now
+        self.lock = threading.Lock()
+        self.entries = OrderedDict()
+        self.versions = {}
+
+    def key(self, layer: str, version: str, latitude: float, longitude: float) -> Optional[tuple]:
+        '''
+        returns the key of the cell the point falls in, None when the layer isn't cached
+        '''
+        precision = self.precisions.get(layer)
+        if precision is None or self.size <= 0:
+            return None
+        return (layer, version, round(latitude, precision), round(longitude, precision))
+
+    def get(self, key: tuple):
+        with self.lock:
+            entry = self.entries.get(key)
+            if entry is None:
+                return None
+            result, expires_at = entry
+            if self.now() >= expires_at:
+                del self.entries[key]
+                return None
+            self.entries.move_to_end(key)
+            return result
+
+    def set(self, key: tuple, result) -> None:
+        with self.lock:
+            self.entries[key] = (result, self.now() + self.ttl)
+            self.entries.move_to_end(key)
+            while len(self.entries) > self.size:
+                self.entries.popitem(last=False)
+
+    def version(self, layer: str) -> Optional[str]:
+        '''
+        returns the layer's data version last seen, None when it has to be looked up again
+        '''
+        with self.lock:
+            entry = self.versions.get(layer)
+            if entry is None or self.now() >= entry[1]:
+                return None
+            return entry[0]
+
+    def set_version(self, layer: str, version: str) -> None:
+        with self.lock:
+            self.versions[layer] = (version, self.now() + self.version_ttl)
+
+
+def parse_precisions(value: str) -> dict:
+    '''
+    parses the JSON object of the decimal places the points are snapped to by layer
+    '''
+    if not value:
+        return {}
+    precisions = json.loads(value)
+    for layer, precision in precisions.items():
+        if not isinstance(precision, int) or precision < 0:
+            raise ValueError(f'invalid intersect cache precision {precision!r} for layer {layer}')
+    return precisions
@@ -1,3 +1,29 @@
 import os
 
+from .audit import parse_fields
+from .entitlements import parse_entitlements
+from .intersect_cache import parse_precisions
+
 APP_PORT = os.getenv('APP_PORT', 8088)
+
+# intersect results cache, a layer's results are only cached when it has a precision in
+# INTERSECT_CACHE_PRECISIONS, e.g. {"geo_uk_haz_t10_03": 4}
+INTERSECT_CACHE_SIZE = int(os.getenv('INTERSECT_CACHE_SIZE', 10000))
+INTERSECT_CACHE_PRECISIONS = parse_precisions(os.getenv('INTERSECT_CACHE_PRECISIONS', ''))
+INTERSECT_CACHE_TTL_SECONDS = float(os.getenv('INTERSECT_CACHE_TTL_SECONDS', 3600))
+LAYER_VERSION_TTL_SECONDS = float(os.getenv('LAYER_VERSION_TTL_SECONDS', 60))
+
+# layers the partners are entitled to, shared with the Go function: ENTITLEMENTS_SOURCE is one of
+# none, config (the ENTITLEMENTS JSON) or db (the partner_entitlements table, kept for
+# ENTITLEMENTS_TTL_SECONDS)
+ENTITLEMENTS_SOURCE = os.getenv('ENTITLEMENTS_SOURCE', 'none')
+ENTITLEMENTS = parse_entitlements(os.getenv('ENTITLEMENTS', ''))
+ENTITLEMENTS_TTL_SECONDS = float(os.getenv('ENTITLEMENTS_TTL_SECONDS', 300))
+
+# audit records of the lookups, shared with the Go function: AUDIT_SINK is one of none or stdout,
+# AUDIT_REDACT_FIELDS lists the fields hidden with AUDIT_REDACTION, hash or drop, the hashes are
+# keyed with AUDIT_HASH_KEY
+AUDIT_SINK = os.getenv('AUDIT_SINK', 'stdout')
+AUDIT_REDACT_FIELDS = parse_fields(os.getenv('AUDIT_REDACT_FIELDS', ''))
+AUDIT_REDACTION = os.getenv('AUDIT_REDACTION', 'hash')
+AUDIT_HASH_KEY = os.getenv('AUDIT_HASH_KEY', '')
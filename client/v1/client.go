@@ -5,10 +5,17 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/cytora/geospatial-lambda/internal"
 	"github.com/cytora/geospatial-lambda/internal/handler"
+	"github.com/cytora/geospatial-lambda/internal/tracing"
 	"github.com/cytora/go-platform-utils/client"
 	"github.com/cytora/go-platform-utils/common"
 )
@@ -19,12 +26,20 @@ type GeospatialLambda interface {
 
 type CompanyService interface {
 	RetrieveCompanyData(ctx context.Context, crn string, groups []string) (*handler.RetrieveResponse, error)
+	RetrieveCompanyDataAsOf(ctx context.Context, crn string, groups []string, asOf time.Time) (*handler.RetrieveResponse, error)
+	RetrieveCompanyVersions(ctx context.Context, crn string) (*handler.VersionsResponse, error)
+	RetrieveCompanyChanges(ctx context.Context, since time.Time, cursor string, limit int) (*handler.ChangesResponse, error)
+	RetrieveEntitlements(ctx context.Context) (*handler.EntitlementsResponse, error)
+	RetrieveUsage(ctx context.Context, from, to time.Time, period string) (*handler.UsageResponse, error)
 }
 
 type Client struct {
-	c *client.HTTPClient
+	c sender
 }
 
+// New returns a client sending its requests with the platform client, the
+// problems of the error responses it reports are returned as errors matching
+// the Err variables
 func New(opts ...client.HTTPClientFunc) (CompanyService, error) {
 	errorCodeLookup := common.NewErrorCodeLookup(internal.ServiceName)
 	c, err := client.NewClient(internal.ServiceName, errorCodeLookup, opts...)
@@ -36,15 +51,123 @@ func New(opts ...client.HTTPClientFunc) (CompanyService, error) {
 	}, nil
 }
 
+// NewHTTP returns a client sending its requests with s, the problems of the
+// error responses are returned as errors matching the Err variables
+func NewHTTP(s *HTTPSender) CompanyService {
+	return &Client{
+		c: s,
+	}
+}
+
+// send traces the request with a client span and passes its context on to
+// the service so both join the same trace. The error responses are returned
+// as problems.
+func (s *Client) send(ctx context.Context, req client.HTTPRequest, res interface{}) (err error) {
+	ctx, span := tracing.Start(ctx, req.API, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		semconv.HTTPMethodKey.String(req.Method),
+		semconv.HTTPTargetKey.String(req.Path),
+	))
+	defer func() { tracing.End(span, err) }()
+	if req.Headers == nil {
+		req.Headers = map[string]string{}
+	}
+	tracing.Propagator.Inject(ctx, propagation.MapCarrier(req.Headers))
+	return decodeProblem(s.c.Send(ctx, req, res))
+}
+
+// RetrieveCompanyData retrieves the given groups of the company's current
+// data
 func (s *Client) RetrieveCompanyData(ctx context.Context, crn string, groups []string) (*handler.RetrieveResponse, error) {
+	return s.RetrieveCompanyDataAsOf(ctx, crn, groups, time.Time{})
+}
+
+// RetrieveCompanyDataAsOf retrieves the given groups of the company's data,
+// as they were at asOf when it is not zero
+func (s *Client) RetrieveCompanyDataAsOf(ctx context.Context, crn string, groups []string, asOf time.Time) (*handler.RetrieveResponse, error) {
 	params := url.Values{
 		"groups": {strings.Join(groups, ",")},
 	}
+	if !asOf.IsZero() {
+		params.Set("as_of", asOf.UTC().Format(time.RFC3339))
+	}
 	res := handler.RetrieveResponse{}
-	err := s.c.Send(ctx, client.HTTPRequest{
+	err := s.send(ctx, client.HTTPRequest{
 		API:           internal.CompanyDataEndpoint,
 		Method:        http.MethodGet,
-		Path:          fmt.Sprintf("/v1/company/%s", crn),
+		Path:          fmt.Sprintf("/v2/company/%s", crn),
+		QueryParams:   params,
+		NotLogReqBody: true,
+		NotLogResBody: true,
+	}, &res)
+	return &res, err
+}
+
+func (s *Client) RetrieveCompanyVersions(ctx context.Context, crn string) (*handler.VersionsResponse, error) {
+	res := handler.VersionsResponse{}
+	err := s.send(ctx, client.HTTPRequest{
+		API:           internal.CompanyVersionsEndpoint,
+		Method:        http.MethodGet,
+		Path:          fmt.Sprintf("/v2/company/%s/versions", crn),
+		NotLogReqBody: true,
+		NotLogResBody: true,
+	}, &res)
+	return &res, err
+}
+
+// RetrieveCompanyChanges lists the companies updated since the given time, or
+// after the given cursor when not empty. The returned NextCursor is used to
+// retrieve the following changes.
+func (s *Client) RetrieveCompanyChanges(ctx context.Context, since time.Time, cursor string, limit int) (*handler.ChangesResponse, error) {
+	params := url.Values{}
+	if cursor != "" {
+		params.Set("cursor", cursor)
+	} else {
+		params.Set("since", since.UTC().Format(time.RFC3339Nano))
+	}
+	if limit > 0 {
+		params.Set("limit", strconv.Itoa(limit))
+	}
+	res := handler.ChangesResponse{}
+	err := s.send(ctx, client.HTTPRequest{
+		API:           internal.CompanyChangesEndpoint,
+		Method:        http.MethodGet,
+		Path:          "/v2/companies/changes",
+		QueryParams:   params,
+		NotLogReqBody: true,
+		NotLogResBody: true,
+	}, &res)
+	return &res, err
+}
+
+// RetrieveEntitlements lists the groups, fields and layers the calling
+// partner can request
+func (s *Client) RetrieveEntitlements(ctx context.Context) (*handler.EntitlementsResponse, error) {
+	res := handler.EntitlementsResponse{}
+	err := s.send(ctx, client.HTTPRequest{
+		API:           internal.EntitlementsEndpoint,
+		Method:        http.MethodGet,
+		Path:          "/v2/entitlements",
+		NotLogReqBody: true,
+		NotLogResBody: true,
+	}, &res)
+	return &res, err
+}
+
+// RetrieveUsage summarises the lookups of the calling partner between from
+// and to by period, one of day, week or month
+func (s *Client) RetrieveUsage(ctx context.Context, from, to time.Time, period string) (*handler.UsageResponse, error) {
+	params := url.Values{
+		"from": {from.UTC().Format(time.RFC3339)},
+		"to":   {to.UTC().Format(time.RFC3339)},
+	}
+	if period != "" {
+		params.Set("period", period)
+	}
+	res := handler.UsageResponse{}
+	err := s.send(ctx, client.HTTPRequest{
+		API:           internal.UsageEndpoint,
+		Method:        http.MethodGet,
+		Path:          "/v2/usage",
 		QueryParams:   params,
 		NotLogReqBody: true,
 		NotLogResBody: true,
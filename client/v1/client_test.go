@@ -0,0 +1,193 @@
+package v2
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cytora/geospatial-lambda/internal/handler"
+	"github.com/cytora/go-platform-utils/client"
+)
+
+// platformSender reports the error responses like the platform client, with
+// an error of their status and body
+type platformSender struct {
+	s *HTTPSender
+}
+
+func (p *platformSender) Send(ctx context.Context, req client.HTTPRequest, res interface{}) error {
+	err := p.s.Send(ctx, req, res)
+	var resErr *ResponseError
+	if errors.As(err, &resErr) {
+		return fmt.Errorf("%s request failed with status %d: %s", req.API, resErr.StatusCode, resErr.Body)
+	}
+	return err
+}
+
+func TestClient_RetrieveCompanyData(t *testing.T) {
+	var got *http.Request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(handler.RetrieveResponse{CRN: "00111222"})
+	}))
+	defer srv.Close()
+
+	c := NewHTTP(&HTTPSender{BaseURL: srv.URL, Client: srv.Client()})
+	asOf := time.Date(2021, 3, 10, 12, 0, 0, 0, time.FixedZone("CET", 3600))
+	res, err := c.RetrieveCompanyDataAsOf(context.Background(), "00111222", []string{"dnb", "officers"}, asOf)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "00111222", res.CRN)
+	if assert.NotNil(t, got, "expected the service to be called") {
+		assert.Equal(t, "/v2/company/00111222", got.URL.Path)
+		assert.Equal(t, "dnb,officers", got.URL.Query().Get("groups"))
+		assert.Equal(t, "2021-03-10T11:00:00Z", got.URL.Query().Get("as_of"), "expected as_of in UTC")
+	}
+}
+
+func TestClient_problems(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		contentType string
+		body        string
+
+		expectedErr     error
+		expectedProblem *handler.Problem
+	}{
+		{
+			name:        "not found",
+			status:      http.StatusNotFound,
+			contentType: "application/problem+json",
+			body:        `{"type":"/problems/not-found","title":"Not found","status":404,"detail":"company not found"}`,
+			expectedErr: ErrNotFound,
+			expectedProblem: &handler.Problem{
+				Type:   handler.ProblemNotFound,
+				Title:  "Not found",
+				Status: http.StatusNotFound,
+				Detail: "company not found",
+			},
+		},
+		{
+			name:        "invalid groups",
+			status:      http.StatusBadRequest,
+			contentType: "application/problem+json",
+			body:        `{"type":"/problems/invalid-groups","title":"Invalid groups","status":400,"invalid_params":[{"name":"groups","reason":"unknown group"}]}`,
+			expectedErr: ErrInvalidGroups,
+			expectedProblem: &handler.Problem{
+				Type:          handler.ProblemInvalidGroups,
+				Title:         "Invalid groups",
+				Status:        http.StatusBadRequest,
+				InvalidParams: []handler.InvalidParam{{Name: "groups", Reason: "unknown group"}},
+			},
+		},
+		{
+			name:        "not a problem",
+			status:      http.StatusBadGateway,
+			contentType: "text/html",
+			body:        "<html>Bad Gateway</html>",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tt.contentType)
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			c := NewHTTP(&HTTPSender{BaseURL: srv.URL, Client: srv.Client()})
+			_, err := c.RetrieveCompanyData(context.Background(), "00111222", nil)
+
+			if tt.expectedErr != nil {
+				assert.True(t, errors.Is(err, tt.expectedErr), "expected %v, got %v", tt.expectedErr, err)
+			}
+			var p *handler.Problem
+			if tt.expectedProblem == nil {
+				assert.False(t, errors.As(err, &p), "expected no problem, got %v", err)
+				var resErr *ResponseError
+				if assert.True(t, errors.As(err, &resErr), "expected the response error, got %v", err) {
+					assert.Equal(t, tt.status, resErr.StatusCode)
+				}
+				return
+			}
+			if assert.True(t, errors.As(err, &p), "expected a problem, got %v", err) {
+				assert.Equal(t, tt.expectedProblem, p)
+			}
+		})
+	}
+}
+
+func TestClient_platformProblems(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+
+		expectedErr     error
+		expectedProblem *handler.Problem
+	}{
+		{
+			name:        "not found",
+			status:      http.StatusNotFound,
+			body:        `{"type":"/problems/not-found","title":"Not found","status":404,"detail":"company not found"}`,
+			expectedErr: ErrNotFound,
+			expectedProblem: &handler.Problem{
+				Type:   handler.ProblemNotFound,
+				Title:  "Not found",
+				Status: http.StatusNotFound,
+				Detail: "company not found",
+			},
+		},
+		{
+			name:        "rate limited",
+			status:      http.StatusTooManyRequests,
+			body:        `{"type":"/problems/rate-limited","title":"Too many requests","status":429,"request_id":"req-1"}`,
+			expectedErr: ErrRateLimited,
+			expectedProblem: &handler.Problem{
+				Type:      handler.ProblemRateLimited,
+				Title:     "Too many requests",
+				Status:    http.StatusTooManyRequests,
+				RequestID: "req-1",
+			},
+		},
+		{
+			name:   "not a problem",
+			status: http.StatusBadGateway,
+			body:   "<html>Bad Gateway</html>",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/problem+json")
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			c := &Client{c: &platformSender{s: &HTTPSender{BaseURL: srv.URL, Client: srv.Client()}}}
+			_, err := c.RetrieveCompanyData(context.Background(), "00111222", nil)
+
+			var p *handler.Problem
+			if tt.expectedProblem == nil {
+				assert.Error(t, err)
+				assert.False(t, errors.As(err, &p), "expected no problem, got %v", err)
+				return
+			}
+			assert.True(t, errors.Is(err, tt.expectedErr), "expected %v, got %v", tt.expectedErr, err)
+			if assert.True(t, errors.As(err, &p), "expected a problem, got %v", err) {
+				assert.Equal(t, tt.expectedProblem, p)
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package v2
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/cytora/geospatial-lambda/internal/handler"
+)
+
+// Errors returned by the client, the problems decoded from the error
+// responses match them with errors.Is and errors.As gives the
+// *handler.Problem with its detail and invalid params
+var (
+	ErrInvalidRequest     error = &handler.Problem{Type: handler.ProblemInvalidRequest, Title: "Invalid request"}
+	ErrInvalidQueryParams error = &handler.Problem{Type: handler.ProblemInvalidQueryParams, Title: "Invalid query parameters"}
+	ErrInvalidCRN         error = &handler.Problem{Type: handler.ProblemInvalidCRN, Title: "Invalid company registration number"}
+	ErrInvalidGroups      error = &handler.Problem{Type: handler.ProblemInvalidGroups, Title: "Invalid groups"}
+	ErrInvalidFields      error = &handler.Problem{Type: handler.ProblemInvalidFields, Title: "Invalid fields"}
+	ErrNotFound           error = &handler.Problem{Type: handler.ProblemNotFound, Title: "Not found"}
+	ErrStaleData          error = &handler.Problem{Type: handler.ProblemStaleData, Title: "Stale data"}
+	ErrForbidden          error = &handler.Problem{Type: handler.ProblemForbidden, Title: "Forbidden"}
+	ErrRateLimited        error = &handler.Problem{Type: handler.ProblemRateLimited, Title: "Too many requests"}
+	ErrTimeout            error = &handler.Problem{Type: handler.ProblemTimeout, Title: "Timeout"}
+	ErrInternal           error = &handler.Problem{Type: handler.ProblemInternal, Title: "Internal error"}
+)
+
+// decodeProblem returns the problem in the body of an error response, err is
+// returned as is when it carries no problem. The body is that of the
+// *ResponseError of HTTPSender, the other errors, e.g. the platform client's
+// reporting the status and body of the response, are decoded from the JSON
+// object in their message.
+func decodeProblem(err error) error {
+	if err == nil {
+		return nil
+	}
+	status := 0
+	body := []byte(problemObject(err.Error()))
+	var resErr *ResponseError
+	if errors.As(err, &resErr) {
+		status, body = resErr.StatusCode, resErr.Body
+	}
+	p := &handler.Problem{}
+	if json.Unmarshal(body, p) != nil || p.Type == "" {
+		return err
+	}
+	if p.Status == 0 {
+		p.Status = status
+	}
+	return p
+}
+
+// problemObject returns the JSON object in msg, from its first opening brace
+// to its last closing one
+func problemObject(msg string) string {
+	start, end := strings.Index(msg, "{"), strings.LastIndex(msg, "}")
+	if start < 0 || end < start {
+		return ""
+	}
+	return msg[start : end+1]
+}
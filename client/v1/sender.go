@@ -0,0 +1,73 @@
+package v2
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/cytora/go-platform-utils/client"
+)
+
+// sender sends the requests of the client and decodes the responses into
+// res, the platform client and HTTPSender implement it
+type sender interface {
+	Send(ctx context.Context, req client.HTTPRequest, res interface{}) error
+}
+
+// ResponseError is the error of HTTPSender for the responses with an error
+// status, Body is the response's body
+type ResponseError struct {
+	StatusCode int
+	Body       []byte
+}
+
+func (e *ResponseError) Error() string {
+	return fmt.Sprintf("geospatial-lambda responded %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// HTTPSender sends the requests with the standard library to the service at
+// BaseURL, e.g. https://geospatial.internal
+type HTTPSender struct {
+	BaseURL string
+	Client  *http.Client
+}
+
+var _ sender = (*HTTPSender)(nil)
+
+func (s *HTTPSender) Send(ctx context.Context, req client.HTTPRequest, res interface{}) error {
+	u := strings.TrimSuffix(s.BaseURL, "/") + req.Path
+	if len(req.QueryParams) > 0 {
+		u += "?" + req.QueryParams.Encode()
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, req.Method, u, nil)
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Accept", "application/json, application/problem+json")
+	for k, v := range req.Headers {
+		httpReq.Header.Set(k, v)
+	}
+	httpClient := s.Client
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	httpRes, err := httpClient.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer httpRes.Body.Close()
+	body, err := ioutil.ReadAll(httpRes.Body)
+	if err != nil {
+		return err
+	}
+	if httpRes.StatusCode >= http.StatusBadRequest {
+		return &ResponseError{StatusCode: httpRes.StatusCode, Body: body}
+	}
+	if res == nil || len(body) == 0 {
+		return nil
+	}
+	return json.Unmarshal(body, res)
+}
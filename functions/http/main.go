@@ -1,15 +1,29 @@
 package main
 
 import (
-	"net/http"
+	"context"
+	"os"
 
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/sqs"
 	"github.com/cytora/go-platform-utils/logging"
 	"github.com/cytora/go-platform-utils/server"
 
-	"github.com/cytora/geospatial-lambda/internal"
+	"github.com/cytora/geospatial-lambda/internal/audit"
 	"github.com/cytora/geospatial-lambda/internal/config"
+	"github.com/cytora/geospatial-lambda/internal/entitlements"
 	"github.com/cytora/geospatial-lambda/internal/handler"
+	"github.com/cytora/geospatial-lambda/internal/health"
+	"github.com/cytora/geospatial-lambda/internal/metering"
+	"github.com/cytora/geospatial-lambda/internal/metrics"
+	"github.com/cytora/geospatial-lambda/internal/ratelimit"
+	"github.com/cytora/geospatial-lambda/internal/storage"
+	"github.com/cytora/geospatial-lambda/internal/storage/cache"
+	"github.com/cytora/geospatial-lambda/internal/storage/coalesce"
 	"github.com/cytora/geospatial-lambda/internal/storage/pg"
+	"github.com/cytora/geospatial-lambda/internal/tracing"
 )
 
 var (
@@ -30,15 +44,133 @@ func main() {
 	if err != nil {
 		logging.FatalNoCtx(err, nil, "failed to create lambda server")
 	}
-	stg, err := pg.New(configs)
+	provider, err := tracing.Setup(context.Background(), configs)
+	if err != nil {
+		logging.FatalNoCtx(err, nil, "failed to configure tracing")
+	}
+	var emitter metrics.Emitter = metrics.Nop{}
+	if configs.MetricsNamespace != "" {
+		emitter = metrics.NewEMF(os.Stdout, configs.MetricsNamespace, metrics.Dimensions{"Service": configs.Service, "Env": configs.Env})
+	}
+	pgStg, err := pg.New(configs, pg.WithMetrics(emitter))
 	if err != nil {
 		logging.FatalNoCtx(err, nil, "failed to start storage connection")
 	}
-	h := handler.New(stg)
-	srv.MustAddRoute(server.RouteOption{
-		API:    internal.CompanyDataEndpoint,
-		Method: http.MethodGet,
-		Path:   "/v2/company/{crn}",
-	}, server.ToHTTPHandlerFunc(h.Retrieve))
+	var stg storage.Storage = coalesce.New(pgStg)
+	if configs.CacheSize > 0 {
+		stg = cache.New(stg, cache.NewLRU(configs.CacheSize),
+			cache.WithTTL(configs.CacheTTL),
+			cache.WithNegativeTTL(configs.CacheNegativeTTL),
+			cache.WithMetrics(emitter),
+		)
+	}
+	opts := []handler.OptionFunc{
+		handler.WithRetrieveTimeout(configs.RetrieveTimeout),
+		handler.WithVersionsTimeout(configs.VersionsTimeout),
+		handler.WithChangesTimeout(configs.ChangesTimeout),
+		handler.WithChangesLag(configs.ChangesLag),
+		handler.WithUsageTimeout(configs.UsageTimeout),
+		handler.WithHealthTimeout(configs.HealthTimeout),
+		handler.WithEntitlementsTimeout(configs.EntitlementsTimeout),
+		handler.WithMetadataTTL(configs.MetadataTTL),
+		handler.WithHealth(health.New(pgStg, configs.Version, configs.HealthLayers, configs.HealthShallowTTL)),
+		handler.WithHealthInternalPartners(configs.HealthInternalPartners),
+		handler.WithMetrics(emitter),
+		handler.WithSpanFlusher(provider, configs.TracingFlushTimeout),
+	}
+	switch configs.EntitlementsSource {
+	case config.EntitlementsConfig:
+		static, err := entitlements.ParseStatic(configs.Entitlements)
+		if err != nil {
+			logging.FatalNoCtx(err, nil, "failed to parse entitlements")
+		}
+		opts = append(opts, handler.WithEntitlements(static))
+	case config.EntitlementsDB:
+		opts = append(opts, handler.WithEntitlements(entitlements.NewDB(pgStg, configs.EntitlementsTTL)))
+	}
+	sink, usage := meteringSink(pgStg)
+	if sink != nil {
+		opts = append(opts, handler.WithMeter(metering.New(sink, configs.MeteringTimeout)))
+	}
+	if usage != nil {
+		opts = append(opts, handler.WithUsageSummarizer(usage))
+	}
+	limiter, err := rateLimiter()
+	if err != nil {
+		logging.FatalNoCtx(err, nil, "failed to configure rate limits")
+	}
+	if limiter != nil {
+		opts = append(opts, handler.WithRateLimiter(limiter))
+	}
+	if configs.AuditSink == config.AuditStdout {
+		redactor, err := audit.NewRedactor(configs.AuditRedaction, configs.AuditRedactFields, []byte(configs.AuditHashKey))
+		if err != nil {
+			logging.FatalNoCtx(err, nil, "failed to configure audit redaction")
+		}
+		opts = append(opts, handler.WithAudit(audit.NewWriter(os.Stdout), redactor))
+	}
+	h := handler.New(stg, opts...)
+	// every route is traced and instrumented and its errors are problem
+	// details, the partners' ones are entitled and their lookups are rate
+	// limited and audited too
+	for _, rt := range h.Routes() {
+		next := server.ToHTTPHandlerFunc(rt.Handle)
+		if rt.Entitled {
+			next = h.Entitle(rt.OperationID, next)
+		}
+		if rt.RateLimited {
+			next = h.RateLimit(rt.OperationID, next)
+		}
+		if rt.Audited {
+			next = h.Audit(rt.OperationID, next)
+		}
+		srv.MustAddRoute(server.RouteOption{
+			API:    rt.OperationID,
+			Method: rt.Method,
+			Path:   rt.Path,
+		}, handler.ProblemJSON(h.Trace(rt.OperationID, h.Instrument(rt.OperationID, next))))
+	}
 	srv.Run()
 }
+
+// meteringSink returns the sink of the usage events configured, and what
+// summarises them when the sink can be queried
+func meteringSink(pgStg *pg.Storage) (metering.Sink, metering.Summarizer) {
+	switch configs.MeteringSink {
+	case config.MeteringLog:
+		return metering.Log{}, nil
+	case config.MeteringSQS:
+		awsConf := &aws.Config{Region: aws.String(configs.AWSRegion)}
+		if configs.MeteringSQSEndpoint != "" {
+			awsConf.Endpoint = aws.String(configs.MeteringSQSEndpoint)
+		}
+		sess := session.Must(session.NewSession(awsConf))
+		return metering.NewSQS(sqs.New(sess), configs.MeteringQueueURL), nil
+	case config.MeteringDB:
+		db := metering.NewDB(pgStg)
+		return db, db
+	default:
+		return nil, nil
+	}
+}
+
+// rateLimiter returns the limiter of the partners' requests configured, nil
+// when requests aren't limited
+func rateLimiter() (*ratelimit.Limiter, error) {
+	if configs.RateLimitStore == config.RateLimitNone {
+		return nil, nil
+	}
+	limits, err := ratelimit.ParseLimits(configs.RateLimits)
+	if err != nil {
+		return nil, err
+	}
+	if configs.RateLimitStore == config.RateLimitMemory {
+		return ratelimit.New(ratelimit.NewMemory(), limits), nil
+	}
+	awsConf := &aws.Config{Region: aws.String(configs.AWSRegion)}
+	if configs.RateLimitDynamoDBEndpoint != "" {
+		awsConf.Endpoint = aws.String(configs.RateLimitDynamoDBEndpoint)
+	}
+	sess := session.Must(session.NewSession(awsConf))
+	return ratelimit.New(ratelimit.NewDynamoDB(dynamodb.New(sess), configs.RateLimitTable), limits), nil
+}
@@ -0,0 +1,82 @@
+// Package audit records who accessed which company or location, and when,
+// apart from the debug logs
+package audit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// fields that can be redacted
+const (
+	FieldPartnerID   = "partner_id"
+	FieldCRN         = "crn"
+	FieldLayer       = "layer"
+	FieldCoordinates = "coordinates"
+)
+
+// redaction modes
+const (
+	// RedactHash replaces a value with its HMAC, the records of the same
+	// value can still be matched
+	RedactHash = "hash"
+	// RedactDrop removes a value
+	RedactDrop = "drop"
+)
+
+// outcomes of a request
+const (
+	OutcomeSuccess     = "success"
+	OutcomeDenied      = "denied"
+	OutcomeRateLimited = "rate_limited"
+	OutcomeRejected    = "rejected"
+	OutcomeError       = "error"
+)
+
+var (
+	ErrAudit             = errors.New("audit error")
+	ErrInvalidRedaction  = fmt.Errorf("%w invalid redaction", ErrAudit)
+	ErrUnknownRedactable = fmt.Errorf("%w unknown redactable field", ErrAudit)
+	ErrMissingHashKey    = fmt.Errorf("%w missing hash key", ErrAudit)
+)
+
+// Record of an access to the data. Layer, Lat and Lon are only set by the
+// Python function in api/, which audits the geospatial lookups in the same
+// format.
+type Record struct {
+	Time      time.Time `json:"time"`
+	PartnerID string    `json:"partner_id,omitempty"`
+	Endpoint  string    `json:"endpoint"`
+	Method    string    `json:"method"`
+	CRN       string    `json:"crn,omitempty"`
+	Layer     string    `json:"layer,omitempty"`
+	Lat       string    `json:"lat,omitempty"`
+	Lon       string    `json:"lon,omitempty"`
+	Status    int       `json:"status"`
+	Outcome   string    `json:"outcome"`
+	LatencyMS int64     `json:"latency_ms"`
+}
+
+// Sink stores the audit records
+type Sink interface {
+	Write(ctx context.Context, record Record) error
+}
+
+// Outcome classifies the status of a response
+func Outcome(status int) string {
+	switch {
+	case status < http.StatusBadRequest:
+		return OutcomeSuccess
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return OutcomeDenied
+	case status == http.StatusTooManyRequests:
+		return OutcomeRateLimited
+	case status < http.StatusInternalServerError:
+		return OutcomeRejected
+	default:
+		return OutcomeError
+	}
+}
@@ -0,0 +1,126 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOutcome(t *testing.T) {
+	tests := []struct {
+		status int
+		want   string
+	}{
+		{status: http.StatusOK, want: OutcomeSuccess},
+		{status: http.StatusForbidden, want: OutcomeDenied},
+		{status: http.StatusTooManyRequests, want: OutcomeRateLimited},
+		{status: http.StatusNotFound, want: OutcomeRejected},
+		{status: http.StatusGatewayTimeout, want: OutcomeError},
+	}
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			assert.Equal(t, tt.want, Outcome(tt.status))
+		})
+	}
+}
+
+func TestRedactor_Redact(t *testing.T) {
+	record := Record{PartnerID: "acme", CRN: "00111222", Layer: "flood", Lat: "51.5", Lon: "-0.1"}
+	tests := []struct {
+		name   string
+		mode   string
+		fields []string
+		check  func(t *testing.T, got Record)
+	}{
+		{
+			name: "nothing",
+			mode: RedactHash,
+			check: func(t *testing.T, got Record) {
+				assert.Equal(t, record, got)
+			},
+		},
+		{
+			name:   "drop",
+			mode:   RedactDrop,
+			fields: []string{FieldCRN, FieldCoordinates},
+			check: func(t *testing.T, got Record) {
+				assert.Equal(t, Record{PartnerID: "acme", Layer: "flood"}, got)
+			},
+		},
+		{
+			name:   "hash",
+			mode:   RedactHash,
+			fields: []string{FieldPartnerID, FieldCRN},
+			check: func(t *testing.T, got Record) {
+				assert.NotEqual(t, record.PartnerID, got.PartnerID)
+				assert.NotEqual(t, record.CRN, got.CRN)
+				assert.Equal(t, "hmac-sha256:9e4a00d74885d915e4a57e92169e5813900762c833f2afd5787354484bd42f3a", got.CRN)
+				assert.Equal(t, record.Layer, got.Layer)
+				assert.Equal(t, record.Lat, got.Lat)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := NewRedactor(tt.mode, tt.fields, []byte("secret"))
+			assert.NoError(t, err)
+			tt.check(t, r.Redact(record))
+		})
+	}
+}
+
+func TestRedactor_hashIsStable(t *testing.T) {
+	r, err := NewRedactor(RedactHash, []string{FieldCRN}, []byte("secret"))
+	assert.NoError(t, err)
+	a := r.Redact(Record{CRN: "00111222"})
+	b := r.Redact(Record{CRN: "00111222"})
+	c := r.Redact(Record{CRN: "00111223"})
+	assert.Equal(t, a.CRN, b.CRN, "expected records of the same crn to match")
+	assert.NotEqual(t, a.CRN, c.CRN)
+
+	other, err := NewRedactor(RedactHash, []string{FieldCRN}, []byte("other"))
+	assert.NoError(t, err)
+	assert.NotEqual(t, a.CRN, other.Redact(Record{CRN: "00111222"}).CRN, "expected the digest to depend on the key")
+}
+
+func TestNewRedactor_errors(t *testing.T) {
+	_, err := NewRedactor("mask", nil, nil)
+	assert.ErrorIs(t, err, ErrInvalidRedaction)
+	_, err = NewRedactor(RedactHash, []string{"email"}, []byte("secret"))
+	assert.ErrorIs(t, err, ErrUnknownRedactable)
+	_, err = NewRedactor(RedactHash, []string{FieldCRN}, nil)
+	assert.ErrorIs(t, err, ErrMissingHashKey)
+	_, err = NewRedactor(RedactDrop, []string{FieldCRN}, nil)
+	assert.NoError(t, err, "expected no key to be needed to drop fields")
+}
+
+func TestWriter_Write(t *testing.T) {
+	var b bytes.Buffer
+	w := NewWriter(&b)
+	record := Record{
+		Time:      time.Date(2021, 3, 10, 12, 0, 0, 0, time.UTC),
+		PartnerID: "acme",
+		Endpoint:  "CompanyData",
+		Method:    http.MethodGet,
+		CRN:       "00111222",
+		Status:    http.StatusOK,
+		Outcome:   OutcomeSuccess,
+		LatencyMS: 12,
+	}
+	assert.NoError(t, w.Write(context.Background(), record))
+	assert.NoError(t, w.Write(context.Background(), record))
+
+	lines := bytes.Split(bytes.TrimSpace(b.Bytes()), []byte("\n"))
+	assert.Len(t, lines, 2)
+	got := map[string]interface{}{}
+	assert.NoError(t, json.Unmarshal(lines[0], &got))
+	assert.Equal(t, "audit", got["type"])
+	assert.Equal(t, "00111222", got["crn"])
+	assert.Equal(t, "2021-03-10T12:00:00Z", got["time"])
+	assert.NotContains(t, got, "layer")
+}
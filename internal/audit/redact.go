@@ -0,0 +1,72 @@
+package audit
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+// Redactor hides the configured fields of the records
+type Redactor struct {
+	mode   string
+	fields map[string]bool
+	key    []byte
+}
+
+var redactable = map[string]bool{
+	FieldPartnerID:   true,
+	FieldCRN:         true,
+	FieldLayer:       true,
+	FieldCoordinates: true,
+}
+
+// NewRedactor returns a redactor hiding fields with mode, the values hashed
+// are keyed with key so the few possible crns or coordinates can't be hashed
+// again to find them
+func NewRedactor(mode string, fields []string, key []byte) (*Redactor, error) {
+	if mode != RedactHash && mode != RedactDrop {
+		return nil, fmt.Errorf("%w: mode %q", ErrInvalidRedaction, mode)
+	}
+	if mode == RedactHash && len(fields) > 0 && len(key) == 0 {
+		return nil, ErrMissingHashKey
+	}
+	r := &Redactor{mode: mode, fields: make(map[string]bool), key: key}
+	for i := range fields {
+		if !redactable[fields[i]] {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownRedactable, fields[i])
+		}
+		r.fields[fields[i]] = true
+	}
+	return r, nil
+}
+
+// Redact returns the record with the configured fields hidden
+func (r *Redactor) Redact(record Record) Record {
+	if r == nil {
+		return record
+	}
+	if r.fields[FieldPartnerID] {
+		record.PartnerID = r.redact(record.PartnerID)
+	}
+	if r.fields[FieldCRN] {
+		record.CRN = r.redact(record.CRN)
+	}
+	if r.fields[FieldLayer] {
+		record.Layer = r.redact(record.Layer)
+	}
+	if r.fields[FieldCoordinates] {
+		record.Lat = r.redact(record.Lat)
+		record.Lon = r.redact(record.Lon)
+	}
+	return record
+}
+
+func (r *Redactor) redact(value string) string {
+	if value == "" || r.mode == RedactDrop {
+		return ""
+	}
+	mac := hmac.New(sha256.New, r.key)
+	mac.Write([]byte(value))
+	return "hmac-sha256:" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,61 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+)
+
+// Writer writes the records as JSON lines, tagged so a log subscription can
+// route them apart from the debug logs
+type Writer struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+var _ Sink = (*Writer)(nil)
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+func (w *Writer) Write(ctx context.Context, record Record) error {
+	line, err := json.Marshal(struct {
+		Type string `json:"type"`
+		Record
+	}{Type: "audit", Record: record})
+	if err != nil {
+		return err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_, err = w.w.Write(append(line, '\n'))
+	return err
+}
+
+// Memory keeps the records in process, for tests
+type Memory struct {
+	mu      sync.Mutex
+	records []Record
+}
+
+var _ Sink = (*Memory)(nil)
+
+func NewMemory() *Memory {
+	return &Memory{}
+}
+
+func (m *Memory) Write(ctx context.Context, record Record) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.records = append(m.records, record)
+	return nil
+}
+
+// Records returns a copy of the records written
+func (m *Memory) Records() []Record {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Record(nil), m.records...)
+}
@@ -2,19 +2,267 @@ package config
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"os"
 	"strconv"
+	"time"
 
 	"github.com/cytora/go-platform-utils/config"
 	"github.com/cytora/go-platform-utils/logging"
 )
 
+// ErrInvalidConfig is returned when the configuration values are not usable
+var ErrInvalidConfig = errors.New("invalid configuration")
+
+// database authentication modes
+const (
+	// DBAuthIAM connects through RDS Proxy with IAM auth tokens
+	DBAuthIAM = "iam"
+	// DBAuthPassword connects to RDSProxyEndpoint with a static password
+	DBAuthPassword = "password"
+	// DBAuthDSN connects with the connection string in DBDSN as it is
+	DBAuthDSN = "dsn"
+)
+
+// entitlements sources
+const (
+	// EntitlementsNone doesn't restrict what partners can request
+	EntitlementsNone = "none"
+	// EntitlementsConfig reads the entitlements from the Entitlements JSON
+	EntitlementsConfig = "config"
+	// EntitlementsDB reads the entitlements from the partner_entitlements table
+	EntitlementsDB = "db"
+)
+
+// metering sinks
+const (
+	MeteringNone = "none"
+	MeteringLog  = "log"
+	MeteringSQS  = "sqs"
+	MeteringDB   = "db"
+)
+
+// rate limit stores
+const (
+	RateLimitNone     = "none"
+	RateLimitMemory   = "memory"
+	RateLimitDynamoDB = "dynamodb"
+)
+
+// audit sinks
+const (
+	AuditNone   = "none"
+	AuditStdout = "stdout"
+)
+
+// tracing exporters
+const (
+	TracingNone   = "none"
+	TracingStdout = "stdout"
+	TracingOTLP   = "otlp"
+)
+
+var sslModes = map[string]bool{
+	"disable":     true,
+	"allow":       true,
+	"prefer":      true,
+	"require":     true,
+	"verify-ca":   true,
+	"verify-full": true,
+}
+
 type Config struct {
 	config.CoreEnvLambda
 	Local            bool   `envconfig:"LOCAL"`
 	RDSProxyEndpoint string `envconfig:"RDS_PROXY_ENDPOINT"`
 	RDSProxyUser     string `envconfig:"RDS_PROXY_USER"`
 	RDSDBName        string `envconfig:"RDS_DB_NAME"`
+
+	// DBAuthMode is one of DBAuthIAM, DBAuthPassword or DBAuthDSN. DBPassword
+	// and DBDSN carry credentials so they are expected to be resolved from
+	// Secrets Manager or Vault outside local runs. DBSSLMode and DBSSLRootCert
+	// are not applied to DBDSN, which carries its own sslmode
+	DBAuthMode    string `envconfig:"DB_AUTH_MODE" default:"iam"`
+	DBPassword    string `envconfig:"DB_PASSWORD"`
+	DBDSN         string `envconfig:"DB_DSN"`
+	DBSSLMode     string `envconfig:"DB_SSL_MODE" default:"require"`
+	DBSSLRootCert string `envconfig:"DB_SSL_ROOT_CERT"`
+
+	// CacheSize is the number of entries kept by the in-process cache, 0
+	// disables it
+	CacheSize        int           `envconfig:"CACHE_SIZE" default:"1000"`
+	CacheTTL         time.Duration `envconfig:"CACHE_TTL" default:"5m"`
+	CacheNegativeTTL time.Duration `envconfig:"CACHE_NEGATIVE_TTL" default:"1m"`
+
+	// MetadataTTL is how long the groups' latest loads are kept in memory, 0
+	// queries them on every request
+	MetadataTTL time.Duration `envconfig:"METADATA_TTL" default:"1m"`
+
+	// ChangesLag is how long the changes are held back before they are
+	// listed, it must be longer than any transaction writing the companies'
+	// data or the rows it commits late are skipped by the consumers
+	ChangesLag time.Duration `envconfig:"CHANGES_LAG" default:"5m"`
+
+	// retry policy of the database queries failing with transient errors,
+	// DBRetryMaxAttempts 0 only limits the retries by time
+	DBRetryInitialInterval time.Duration `envconfig:"DB_RETRY_INITIAL_INTERVAL" default:"50ms"`
+	DBRetryMaxInterval     time.Duration `envconfig:"DB_RETRY_MAX_INTERVAL" default:"1s"`
+	DBRetryMaxElapsedTime  time.Duration `envconfig:"DB_RETRY_MAX_ELAPSED_TIME" default:"10s"`
+	DBRetryMaxAttempts     int           `envconfig:"DB_RETRY_MAX_ATTEMPTS" default:"5"`
+
+	// connection pool, the defaults suit a 128MB lambda serving a request
+	// at a time behind RDS Proxy. DBStatementTimeout is the statement_timeout
+	// of the connections, 0 keeps the database's one: the queries are
+	// cancelled at the request's deadline anyway
+	DBPoolMaxConns          int           `envconfig:"DB_POOL_MAX_CONNS" default:"4"`
+	DBPoolMinConns          int           `envconfig:"DB_POOL_MIN_CONNS" default:"1"`
+	DBPoolMaxConnLifetime   time.Duration `envconfig:"DB_POOL_MAX_CONN_LIFETIME" default:"30m"`
+	DBPoolMaxConnIdleTime   time.Duration `envconfig:"DB_POOL_MAX_CONN_IDLE_TIME" default:"5m"`
+	DBPoolHealthCheckPeriod time.Duration `envconfig:"DB_POOL_HEALTH_CHECK_PERIOD" default:"1m"`
+	DBStatementTimeout      time.Duration `envconfig:"DB_STATEMENT_TIMEOUT" default:"10s"`
+	DBConnectTimeout        time.Duration `envconfig:"DB_CONNECT_TIMEOUT" default:"5s"`
+
+	// deadlines of the endpoints' requests, 0 leaves them bound only to the
+	// lambda timeout
+	RetrieveTimeout     time.Duration `envconfig:"RETRIEVE_TIMEOUT" default:"8s"`
+	VersionsTimeout     time.Duration `envconfig:"VERSIONS_TIMEOUT" default:"5s"`
+	ChangesTimeout      time.Duration `envconfig:"CHANGES_TIMEOUT" default:"8s"`
+	UsageTimeout        time.Duration `envconfig:"USAGE_TIMEOUT" default:"8s"`
+	HealthTimeout       time.Duration `envconfig:"HEALTH_TIMEOUT" default:"3s"`
+	EntitlementsTimeout time.Duration `envconfig:"ENTITLEMENTS_TIMEOUT" default:"3s"`
+
+	// EntitlementsSource is one of EntitlementsNone, EntitlementsConfig or
+	// EntitlementsDB, EntitlementsTTL is how long the entitlements read from
+	// the database are kept in memory
+	EntitlementsSource string        `envconfig:"ENTITLEMENTS_SOURCE" default:"none"`
+	Entitlements       string        `envconfig:"ENTITLEMENTS"`
+	EntitlementsTTL    time.Duration `envconfig:"ENTITLEMENTS_TTL" default:"5m"`
+
+	// MeteringSink is one of MeteringNone, MeteringLog, MeteringSQS or
+	// MeteringDB, MeteringSQSEndpoint overrides the SQS endpoint to use
+	// Localstack in local runs. MeteringTimeout bounds the recording of a
+	// lookup, which doesn't share the request's deadline
+	MeteringSink        string        `envconfig:"METERING_SINK" default:"log"`
+	MeteringQueueURL    string        `envconfig:"METERING_QUEUE_URL"`
+	MeteringSQSEndpoint string        `envconfig:"METERING_SQS_ENDPOINT"`
+	MeteringTimeout     time.Duration `envconfig:"METERING_TIMEOUT" default:"2s"`
+
+	// RateLimitStore is one of RateLimitNone, RateLimitMemory or
+	// RateLimitDynamoDB, RateLimits is the JSON of the limits per endpoint
+	RateLimitStore            string `envconfig:"RATE_LIMIT_STORE" default:"none"`
+	RateLimits                string `envconfig:"RATE_LIMITS"`
+	RateLimitTable            string `envconfig:"RATE_LIMIT_TABLE"`
+	RateLimitDynamoDBEndpoint string `envconfig:"RATE_LIMIT_DYNAMODB_ENDPOINT"`
+
+	// AuditSink is one of AuditNone or AuditStdout, AuditRedactFields lists
+	// the fields of the audit records hidden with AuditRedaction, hash or drop.
+	// AuditHashKey keys the HMAC of the fields hashed, like DBPassword it is
+	// expected to be resolved from Secrets Manager or Vault. The Python
+	// function in api/ reads them too to audit the geospatial lookups
+	AuditSink         string   `envconfig:"AUDIT_SINK" default:"stdout"`
+	AuditRedactFields []string `envconfig:"AUDIT_REDACT_FIELDS"`
+	AuditRedaction    string   `envconfig:"AUDIT_REDACTION" default:"hash"`
+	AuditHashKey      string   `envconfig:"AUDIT_HASH_KEY"`
+
+	// MetricsNamespace is the CloudWatch namespace of the metrics, they
+	// aren't emitted when it's empty
+	MetricsNamespace string `envconfig:"METRICS_NAMESPACE" default:"GeospatialLambda"`
+
+	// TracingExporter is one of TracingNone, TracingStdout or TracingOTLP,
+	// TracingEndpoint overrides the OTLP collector's host and port.
+	// TracingFlushTimeout bounds the export of a request's spans once it's
+	// handled, it delays the response as much when the collector is slow
+	TracingExporter     string        `envconfig:"TRACING_EXPORTER" default:"none"`
+	TracingEndpoint     string        `envconfig:"TRACING_ENDPOINT"`
+	TracingSampleRatio  float64       `envconfig:"TRACING_SAMPLE_RATIO" default:"1"`
+	TracingFlushTimeout time.Duration `envconfig:"TRACING_FLUSH_TIMEOUT" default:"200ms"`
+
+	// HealthLayers lists the layers the deep health check expects to be
+	// registered in PostGIS, every layer found is reported when it's empty.
+	// HealthInternalPartners may request the deep check, HealthShallowTTL is
+	// how long the load balancers' shallow check is reused
+	HealthLayers           []string      `envconfig:"HEALTH_LAYERS"`
+	HealthInternalPartners []string      `envconfig:"HEALTH_INTERNAL_PARTNERS"`
+	HealthShallowTTL       time.Duration `envconfig:"HEALTH_SHALLOW_TTL" default:"5s"`
+}
+
+// Validate checks the values populated can be used, the error returned
+// names the first invalid setting
+func (c *Config) Validate() error {
+	switch {
+	case c.DBAuthMode != DBAuthIAM && c.DBAuthMode != DBAuthPassword && c.DBAuthMode != DBAuthDSN:
+		return fmt.Errorf("%w: DB_AUTH_MODE must be one of %s, %s or %s, got %q", ErrInvalidConfig, DBAuthIAM, DBAuthPassword, DBAuthDSN, c.DBAuthMode)
+	case c.DBAuthMode == DBAuthPassword && c.DBPassword == "":
+		return fmt.Errorf("%w: DB_PASSWORD is required by DB_AUTH_MODE %s", ErrInvalidConfig, DBAuthPassword)
+	case c.DBAuthMode == DBAuthDSN && c.DBDSN == "":
+		return fmt.Errorf("%w: DB_DSN is required by DB_AUTH_MODE %s", ErrInvalidConfig, DBAuthDSN)
+	case !sslModes[c.DBSSLMode]:
+		return fmt.Errorf("%w: DB_SSL_MODE %q is not a valid sslmode", ErrInvalidConfig, c.DBSSLMode)
+	case c.DBSSLRootCert != "" && c.DBSSLMode != "verify-ca" && c.DBSSLMode != "verify-full":
+		return fmt.Errorf("%w: DB_SSL_ROOT_CERT is only used with DB_SSL_MODE verify-ca or verify-full, got %q", ErrInvalidConfig, c.DBSSLMode)
+	case c.CacheSize < 0:
+		return fmt.Errorf("%w: CACHE_SIZE must not be negative, got %d", ErrInvalidConfig, c.CacheSize)
+	case c.MetadataTTL < 0:
+		return fmt.Errorf("%w: METADATA_TTL must not be negative, got %s", ErrInvalidConfig, c.MetadataTTL)
+	case c.DBRetryMaxAttempts < 0:
+		return fmt.Errorf("%w: DB_RETRY_MAX_ATTEMPTS must not be negative, got %d", ErrInvalidConfig, c.DBRetryMaxAttempts)
+	case c.DBRetryInitialInterval <= 0:
+		return fmt.Errorf("%w: DB_RETRY_INITIAL_INTERVAL must be positive, got %s", ErrInvalidConfig, c.DBRetryInitialInterval)
+	case c.DBRetryMaxInterval < c.DBRetryInitialInterval:
+		return fmt.Errorf("%w: DB_RETRY_MAX_INTERVAL %s is lower than DB_RETRY_INITIAL_INTERVAL %s", ErrInvalidConfig, c.DBRetryMaxInterval, c.DBRetryInitialInterval)
+	case c.DBPoolMaxConns < 1:
+		return fmt.Errorf("%w: DB_POOL_MAX_CONNS must be at least 1, got %d", ErrInvalidConfig, c.DBPoolMaxConns)
+	case c.DBPoolMinConns < 0 || c.DBPoolMinConns > c.DBPoolMaxConns:
+		return fmt.Errorf("%w: DB_POOL_MIN_CONNS must be between 0 and DB_POOL_MAX_CONNS %d, got %d", ErrInvalidConfig, c.DBPoolMaxConns, c.DBPoolMinConns)
+	case c.DBPoolMaxConnLifetime <= 0:
+		return fmt.Errorf("%w: DB_POOL_MAX_CONN_LIFETIME must be positive, got %s", ErrInvalidConfig, c.DBPoolMaxConnLifetime)
+	case c.DBPoolMaxConnIdleTime <= 0:
+		return fmt.Errorf("%w: DB_POOL_MAX_CONN_IDLE_TIME must be positive, got %s", ErrInvalidConfig, c.DBPoolMaxConnIdleTime)
+	case c.DBPoolHealthCheckPeriod <= 0:
+		return fmt.Errorf("%w: DB_POOL_HEALTH_CHECK_PERIOD must be positive, got %s", ErrInvalidConfig, c.DBPoolHealthCheckPeriod)
+	case c.DBStatementTimeout < 0:
+		return fmt.Errorf("%w: DB_STATEMENT_TIMEOUT must not be negative, got %s", ErrInvalidConfig, c.DBStatementTimeout)
+	case c.DBConnectTimeout <= 0:
+		return fmt.Errorf("%w: DB_CONNECT_TIMEOUT must be positive, got %s", ErrInvalidConfig, c.DBConnectTimeout)
+	case c.RetrieveTimeout < 0:
+		return fmt.Errorf("%w: RETRIEVE_TIMEOUT must not be negative, got %s", ErrInvalidConfig, c.RetrieveTimeout)
+	case c.VersionsTimeout < 0:
+		return fmt.Errorf("%w: VERSIONS_TIMEOUT must not be negative, got %s", ErrInvalidConfig, c.VersionsTimeout)
+	case c.ChangesTimeout < 0:
+		return fmt.Errorf("%w: CHANGES_TIMEOUT must not be negative, got %s", ErrInvalidConfig, c.ChangesTimeout)
+	case c.UsageTimeout < 0:
+		return fmt.Errorf("%w: USAGE_TIMEOUT must not be negative, got %s", ErrInvalidConfig, c.UsageTimeout)
+	case c.ChangesLag < 0:
+		return fmt.Errorf("%w: CHANGES_LAG must not be negative, got %s", ErrInvalidConfig, c.ChangesLag)
+	case c.HealthTimeout < 0:
+		return fmt.Errorf("%w: HEALTH_TIMEOUT must not be negative, got %s", ErrInvalidConfig, c.HealthTimeout)
+	case c.EntitlementsTimeout < 0:
+		return fmt.Errorf("%w: ENTITLEMENTS_TIMEOUT must not be negative, got %s", ErrInvalidConfig, c.EntitlementsTimeout)
+	case c.EntitlementsSource != EntitlementsNone && c.EntitlementsSource != EntitlementsConfig && c.EntitlementsSource != EntitlementsDB:
+		return fmt.Errorf("%w: ENTITLEMENTS_SOURCE must be one of %s, %s or %s, got %q", ErrInvalidConfig, EntitlementsNone, EntitlementsConfig, EntitlementsDB, c.EntitlementsSource)
+	case c.EntitlementsSource == EntitlementsDB && c.EntitlementsTTL <= 0:
+		return fmt.Errorf("%w: ENTITLEMENTS_TTL must be positive, got %s", ErrInvalidConfig, c.EntitlementsTTL)
+	case c.MeteringSink != MeteringNone && c.MeteringSink != MeteringLog && c.MeteringSink != MeteringSQS && c.MeteringSink != MeteringDB:
+		return fmt.Errorf("%w: METERING_SINK must be one of %s, %s, %s or %s, got %q", ErrInvalidConfig, MeteringNone, MeteringLog, MeteringSQS, MeteringDB, c.MeteringSink)
+	case c.MeteringSink == MeteringSQS && c.MeteringQueueURL == "":
+		return fmt.Errorf("%w: METERING_QUEUE_URL is required by METERING_SINK %s", ErrInvalidConfig, MeteringSQS)
+	case c.RateLimitStore != RateLimitNone && c.RateLimitStore != RateLimitMemory && c.RateLimitStore != RateLimitDynamoDB:
+		return fmt.Errorf("%w: RATE_LIMIT_STORE must be one of %s, %s or %s, got %q", ErrInvalidConfig, RateLimitNone, RateLimitMemory, RateLimitDynamoDB, c.RateLimitStore)
+	case c.RateLimitStore == RateLimitDynamoDB && c.RateLimitTable == "":
+		return fmt.Errorf("%w: RATE_LIMIT_TABLE is required by RATE_LIMIT_STORE %s", ErrInvalidConfig, RateLimitDynamoDB)
+	case c.AuditSink != AuditNone && c.AuditSink != AuditStdout:
+		return fmt.Errorf("%w: AUDIT_SINK must be one of %s or %s, got %q", ErrInvalidConfig, AuditNone, AuditStdout, c.AuditSink)
+	case c.AuditSink == AuditStdout && c.AuditRedaction == "hash" && len(c.AuditRedactFields) > 0 && c.AuditHashKey == "":
+		return fmt.Errorf("%w: AUDIT_HASH_KEY is required to hash AUDIT_REDACT_FIELDS", ErrInvalidConfig)
+	case c.TracingExporter != TracingNone && c.TracingExporter != TracingStdout && c.TracingExporter != TracingOTLP:
+		return fmt.Errorf("%w: TRACING_EXPORTER must be one of %s, %s or %s, got %q", ErrInvalidConfig, TracingNone, TracingStdout, TracingOTLP, c.TracingExporter)
+	case c.TracingSampleRatio < 0 || c.TracingSampleRatio > 1:
+		return fmt.Errorf("%w: TRACING_SAMPLE_RATIO must be between 0 and 1, got %g", ErrInvalidConfig, c.TracingSampleRatio)
+	case c.TracingFlushTimeout <= 0:
+		return fmt.Errorf("%w: TRACING_FLUSH_TIMEOUT must be positive, got %s", ErrInvalidConfig, c.TracingFlushTimeout)
+	}
+	return nil
 }
 
 func Load() (*Config, error) {
@@ -37,5 +285,9 @@ func Load() (*Config, error) {
 		logging.Error(context.Background(), err, logging.Data{"local": c.Local}, "failed to populate config")
 		return nil, err
 	}
+	if err := c.Validate(); err != nil {
+		logging.Error(context.Background(), err, nil, "invalid config")
+		return nil, err
+	}
 	return c, nil
 }
@@ -0,0 +1,65 @@
+// Package crn parses and normalises Companies House registration numbers
+package crn
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	// Length of every normalised company registration number
+	Length = 8
+)
+
+// Errors exported by the crn package
+var (
+	ErrInvalidCRN    = errors.New("invalid crn")
+	ErrEmpty         = fmt.Errorf("%w: empty value", ErrInvalidCRN)
+	ErrUnknownPrefix = fmt.Errorf("%w: unknown prefix", ErrInvalidCRN)
+	ErrInvalidDigits = fmt.Errorf("%w: invalid digits", ErrInvalidCRN)
+	ErrTooLong       = fmt.Errorf("%w: too long", ErrInvalidCRN)
+)
+
+// prefixes used by Companies House for companies not registered in England
+// and Wales or for entities other than limited companies
+var prefixes = map[string]bool{
+	"AC": true, "BR": true, "CE": true, "CS": true, "FC": true, "FE": true,
+	"GE": true, "GN": true, "GS": true, "IC": true, "IP": true, "LP": true,
+	"NA": true, "NC": true, "NF": true, "NI": true, "NL": true, "NO": true,
+	"NP": true, "NR": true, "NV": true, "NZ": true, "OC": true, "OE": true,
+	"R": true, "RC": true, "SA": true, "SC": true, "SE": true, "SF": true,
+	"SG": true, "SI": true, "SL": true, "SO": true, "SP": true, "SR": true,
+	"SZ": true, "ZC": true,
+}
+
+// Parse validates a company registration number and returns it in its
+// canonical form: upper case, without spaces and zero padded to 8 characters,
+// e.g. "sc123" becomes "SC000123" and " 123456 " becomes "00123456". Values
+// longer than 8 characters are rejected, even when they're zero padded.
+func Parse(value string) (string, error) {
+	v := strings.ToUpper(strings.TrimSpace(value))
+	if v == "" {
+		return "", ErrEmpty
+	}
+	if len(v) > Length {
+		return "", fmt.Errorf("%w %q", ErrTooLong, value)
+	}
+	i := 0
+	for i < len(v) && v[i] >= 'A' && v[i] <= 'Z' {
+		i++
+	}
+	prefix, digits := v[:i], v[i:]
+	if prefix != "" && !prefixes[prefix] {
+		return "", fmt.Errorf("%w %q", ErrUnknownPrefix, prefix)
+	}
+	if digits == "" {
+		return "", fmt.Errorf("%w %q", ErrInvalidDigits, value)
+	}
+	for j := range digits {
+		if digits[j] < '0' || digits[j] > '9' {
+			return "", fmt.Errorf("%w %q", ErrInvalidDigits, value)
+		}
+	}
+	return prefix + strings.Repeat("0", Length-len(v)) + digits, nil
+}
@@ -0,0 +1,51 @@
+package crn
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    string
+		wantErr error
+	}{
+		{name: "canonical", value: "00123456", want: "00123456"},
+		{name: "not padded", value: "123456", want: "00123456"},
+		{name: "spaces", value: " 00123456 ", want: "00123456"},
+		{name: "leading zeros", value: "00011122", want: "00011122"},
+		{name: "scottish", value: "SC123456", want: "SC123456"},
+		{name: "lower case prefix", value: "sc123456", want: "SC123456"},
+		{name: "prefix not padded", value: "oc123", want: "OC000123"},
+		{name: "single letter prefix", value: "R12345", want: "R0012345"},
+		{name: "empty", value: "  ", wantErr: ErrEmpty},
+		{name: "unknown prefix", value: "XX123456", wantErr: ErrUnknownPrefix},
+		{name: "prefix only", value: "NI", wantErr: ErrInvalidDigits},
+		{name: "letters after digits", value: "123456AB", wantErr: ErrInvalidDigits},
+		{name: "too long", value: "123456789", wantErr: ErrTooLong},
+		{name: "over padded", value: "000111222", wantErr: ErrTooLong},
+		{name: "prefixed too long", value: "SC1234567", wantErr: ErrTooLong},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.value)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("Parse() error = %v, want %v", err, tt.wantErr)
+				}
+				if !errors.Is(err, ErrInvalidCRN) {
+					t.Errorf("Parse() error = %v, want it to wrap %v", err, ErrInvalidCRN)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("Parse() unexpected error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Parse() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
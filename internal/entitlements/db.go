@@ -0,0 +1,76 @@
+package entitlements
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/cytora/geospatial-lambda/internal/storage"
+)
+
+// Source loads the entitlements of a partner from the database,
+// storage.ErrNotFound is returned for unknown partners
+type Source interface {
+	PartnerEntitlement(ctx context.Context, partnerID string) (*storage.Entitlement, error)
+}
+
+// DB reads the entitlements from the partner_entitlements table, they're
+// kept in memory for the ttl given to save a query per request
+type DB struct {
+	source Source
+	ttl    time.Duration
+	now    func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]dbEntry
+}
+
+type dbEntry struct {
+	entitlements *Entitlements
+	err          error
+	expiresAt    time.Time
+}
+
+var _ Store = (*DB)(nil)
+
+func NewDB(source Source, ttl time.Duration) *DB {
+	return &DB{
+		source:  source,
+		ttl:     ttl,
+		now:     time.Now,
+		entries: make(map[string]dbEntry),
+	}
+}
+
+func (d *DB) Entitlements(ctx context.Context, partnerID string) (*Entitlements, error) {
+	d.mu.Lock()
+	entry, ok := d.entries[partnerID]
+	d.mu.Unlock()
+	if ok && d.now().Before(entry.expiresAt) {
+		return entry.entitlements, entry.err
+	}
+	e, err := d.load(ctx, partnerID)
+	if err != nil && err != ErrUnknownPartner {
+		return nil, err
+	}
+	d.mu.Lock()
+	d.entries[partnerID] = dbEntry{entitlements: e, err: err, expiresAt: d.now().Add(d.ttl)}
+	d.mu.Unlock()
+	return e, err
+}
+
+func (d *DB) load(ctx context.Context, partnerID string) (*Entitlements, error) {
+	row, err := d.source.PartnerEntitlement(ctx, partnerID)
+	if err != nil {
+		if err == storage.ErrNotFound {
+			return nil, ErrUnknownPartner
+		}
+		return nil, err
+	}
+	return &Entitlements{
+		PartnerID: partnerID,
+		Groups:    row.Groups,
+		Fields:    row.Fields,
+		Layers:    row.Layers,
+	}, nil
+}
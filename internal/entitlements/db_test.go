@@ -0,0 +1,71 @@
+package entitlements
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgtype"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cytora/geospatial-lambda/internal/storage"
+)
+
+type sourceMock struct {
+	results *storage.Entitlement
+	err     error
+	calls   int
+}
+
+func (s *sourceMock) PartnerEntitlement(ctx context.Context, partnerID string) (*storage.Entitlement, error) {
+	s.calls++
+	return s.results, s.err
+}
+
+func TestDB_Entitlements(t *testing.T) {
+	source := &sourceMock{
+		results: &storage.Entitlement{
+			PartnerID: pgtype.Text{String: "acme", Status: pgtype.Present},
+			Groups:    []string{"dnb"},
+			Layers:    []string{"flood"},
+		},
+	}
+	now := time.Now()
+	db := NewDB(source, time.Minute)
+	db.now = func() time.Time { return now }
+
+	want := &Entitlements{PartnerID: "acme", Groups: []string{"dnb"}, Layers: []string{"flood"}}
+	got, err := db.Entitlements(context.Background(), "acme")
+	assert.NoError(t, err)
+	assert.Equal(t, want, got)
+
+	got, err = db.Entitlements(context.Background(), "acme")
+	assert.NoError(t, err)
+	assert.Equal(t, want, got)
+	assert.Equal(t, 1, source.calls, "expected cached entitlements")
+
+	now = now.Add(2 * time.Minute)
+	_, err = db.Entitlements(context.Background(), "acme")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, source.calls, "expected expired entitlements to be reloaded")
+}
+
+func TestDB_Entitlements_errors(t *testing.T) {
+	source := &sourceMock{err: storage.ErrNotFound}
+	db := NewDB(source, time.Minute)
+
+	_, err := db.Entitlements(context.Background(), "acme")
+	assert.Equal(t, ErrUnknownPartner, err)
+	_, err = db.Entitlements(context.Background(), "acme")
+	assert.Equal(t, ErrUnknownPartner, err)
+	assert.Equal(t, 1, source.calls, "expected unknown partner to be cached")
+
+	source = &sourceMock{err: errors.New("oops")}
+	db = NewDB(source, time.Minute)
+	_, err = db.Entitlements(context.Background(), "acme")
+	assert.Error(t, err)
+	_, err = db.Entitlements(context.Background(), "acme")
+	assert.Error(t, err)
+	assert.Equal(t, 2, source.calls, "expected storage errors not to be cached")
+}
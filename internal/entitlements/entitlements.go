@@ -0,0 +1,63 @@
+// Package entitlements tells which data groups, fields and layers a partner
+// is allowed to request
+package entitlements
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Wildcard entitles a partner to every group, field or layer
+const Wildcard = "*"
+
+var (
+	ErrEntitlements        = errors.New("entitlements error")
+	ErrUnknownPartner      = fmt.Errorf("%w unknown partner", ErrEntitlements)
+	ErrInvalidEntitlements = fmt.Errorf("%w invalid entitlements", ErrEntitlements)
+)
+
+// Entitlements of a partner. An empty list of fields, or one holding the
+// wildcard, doesn't restrict the fields of the groups the partner is
+// entitled to.
+type Entitlements struct {
+	PartnerID string   `json:"partner_id"`
+	Groups    []string `json:"groups"`
+	Fields    []string `json:"fields,omitempty"`
+	Layers    []string `json:"layers"`
+}
+
+// Store retrieves the entitlements of a partner, ErrUnknownPartner is
+// returned for partners without entitlements
+type Store interface {
+	Entitlements(ctx context.Context, partnerID string) (*Entitlements, error)
+}
+
+func (e *Entitlements) AllowsGroup(group string) bool {
+	return contains(e.Groups, group) || contains(e.Groups, Wildcard)
+}
+
+// AllowsLayer mirrors allows_layer of the Python function in api/, which
+// serves the geospatial lookups and enforces the layers
+func (e *Entitlements) AllowsLayer(layer string) bool {
+	return contains(e.Layers, layer) || contains(e.Layers, Wildcard)
+}
+
+// RestrictsFields tells whether the partner can only request some fields
+// of the groups it's entitled to
+func (e *Entitlements) RestrictsFields() bool {
+	return len(e.Fields) > 0 && !contains(e.Fields, Wildcard)
+}
+
+func (e *Entitlements) AllowsField(field string) bool {
+	return !e.RestrictsFields() || contains(e.Fields, field)
+}
+
+func contains(values []string, value string) bool {
+	for i := range values {
+		if values[i] == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,140 @@
+package entitlements
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseStatic(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    Static
+		wantErr error
+	}{
+		{
+			name: "empty",
+			data: "",
+			want: Static{},
+		},
+		{
+			name: "partners",
+			data: `{"acme":{"groups":["dnb"],"fields":["dnb_employees"],"layers":["*"]},"initech":{"groups":["*"]}}`,
+			want: Static{
+				"acme": {
+					PartnerID: "acme",
+					Groups:    []string{"dnb"},
+					Fields:    []string{"dnb_employees"},
+					Layers:    []string{"*"},
+				},
+				"initech": {
+					PartnerID: "initech",
+					Groups:    []string{"*"},
+				},
+			},
+		},
+		{
+			name:    "malformed",
+			data:    `{"acme":["dnb"]}`,
+			wantErr: ErrInvalidEntitlements,
+		},
+		{
+			name:    "null partner",
+			data:    `{"acme":null}`,
+			wantErr: ErrInvalidEntitlements,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseStatic(tt.data)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestStatic_Entitlements(t *testing.T) {
+	s, err := ParseStatic(`{"acme":{"groups":["dnb"]}}`)
+	assert.NoError(t, err)
+
+	got, err := s.Entitlements(context.Background(), "acme")
+	assert.NoError(t, err)
+	assert.Equal(t, &Entitlements{PartnerID: "acme", Groups: []string{"dnb"}}, got)
+
+	_, err = s.Entitlements(context.Background(), "initech")
+	assert.Equal(t, ErrUnknownPartner, err)
+}
+
+func TestEntitlements_Allows(t *testing.T) {
+	tests := []struct {
+		name         string
+		entitlements *Entitlements
+		group        string
+		field        string
+		layer        string
+		wantGroup    bool
+		wantField    bool
+		wantLayer    bool
+	}{
+		{
+			name:         "nothing",
+			entitlements: &Entitlements{},
+			group:        "dnb",
+			field:        "dnb_employees",
+			layer:        "flood",
+			wantField:    true,
+		},
+		{
+			name: "listed",
+			entitlements: &Entitlements{
+				Groups: []string{"dnb"},
+				Fields: []string{"dnb_employees"},
+				Layers: []string{"flood"},
+			},
+			group:     "dnb",
+			field:     "dnb_employees",
+			layer:     "flood",
+			wantGroup: true,
+			wantField: true,
+			wantLayer: true,
+		},
+		{
+			name: "not listed",
+			entitlements: &Entitlements{
+				Groups: []string{"companies_house"},
+				Fields: []string{"ch_company_status"},
+				Layers: []string{"subsidence"},
+			},
+			group: "dnb",
+			field: "dnb_employees",
+			layer: "flood",
+		},
+		{
+			name: "wildcards",
+			entitlements: &Entitlements{
+				Groups: []string{Wildcard},
+				Fields: []string{Wildcard},
+				Layers: []string{Wildcard},
+			},
+			group:     "dnb",
+			field:     "dnb_employees",
+			layer:     "flood",
+			wantGroup: true,
+			wantField: true,
+			wantLayer: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.wantGroup, tt.entitlements.AllowsGroup(tt.group), "unexpected group entitlement")
+			assert.Equal(t, tt.wantField, tt.entitlements.AllowsField(tt.field), "unexpected field entitlement")
+			assert.Equal(t, tt.wantLayer, tt.entitlements.AllowsLayer(tt.layer), "unexpected layer entitlement")
+		})
+	}
+}
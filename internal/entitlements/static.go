@@ -0,0 +1,39 @@
+package entitlements
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// Static holds the entitlements from the configuration, keyed by partner ID
+type Static map[string]*Entitlements
+
+var _ Store = Static(nil)
+
+// ParseStatic reads the entitlements from a JSON object keyed by partner
+// ID, e.g. {"partner":{"groups":["dnb"],"layers":["*"]}}
+func ParseStatic(data string) (Static, error) {
+	s := Static{}
+	if data == "" {
+		return s, nil
+	}
+	if err := json.Unmarshal([]byte(data), &s); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidEntitlements, err)
+	}
+	for partnerID, e := range s {
+		if e == nil {
+			return nil, fmt.Errorf("%w: partner %s has no entitlements", ErrInvalidEntitlements, partnerID)
+		}
+		e.PartnerID = partnerID
+	}
+	return s, nil
+}
+
+func (s Static) Entitlements(ctx context.Context, partnerID string) (*Entitlements, error) {
+	e, ok := s[partnerID]
+	if !ok {
+		return nil, ErrUnknownPartner
+	}
+	return e, nil
+}
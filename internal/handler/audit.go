@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/cytora/geospatial-lambda/internal/audit"
+	"github.com/cytora/go-platform-utils/logging"
+	"github.com/cytora/go-platform-utils/server"
+)
+
+// statusRecorder keeps the status written by the wrapped handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Audit records the accesses to endpoint: the partner, the company looked
+// up, the outcome and the latency. The locations are looked up through the
+// Python function in api/, which audits them in the same records.
+func (h *Handler) Audit(endpoint string, next http.HandlerFunc) http.HandlerFunc {
+	if h.opts.audit == nil {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		ts := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next(rec, r)
+		ctx := r.Context()
+		record := audit.Record{
+			Time:      ts.UTC(),
+			PartnerID: partnerID(ctx),
+			Endpoint:  endpoint,
+			Method:    r.Method,
+			CRN:       auditedCRN(r),
+			Status:    rec.status,
+			Outcome:   audit.Outcome(rec.status),
+			LatencyMS: time.Since(ts).Milliseconds(),
+		}
+		if err := h.opts.audit.Write(ctx, h.opts.redactor.Redact(record)); err != nil {
+			logging.Error(ctx, err, logging.Data{"endpoint": endpoint}, "failed to write audit record")
+		}
+	}
+}
+
+// auditedCRN returns the normalised company registration number of the
+// request, or the value received when it's malformed
+func auditedCRN(r *http.Request) string {
+	req, err := server.Unmarshal(r, nil)
+	if err != nil {
+		return ""
+	}
+	value := req.PathParams["crn"]
+	if number, err := parseCRN(value); err == nil {
+		return number
+	}
+	return value
+}
@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cytora/geospatial-lambda/internal/audit"
+	"github.com/cytora/geospatial-lambda/internal/storage"
+	"github.com/cytora/geospatial-lambda/internal/storage/mock"
+	"github.com/cytora/go-platform-utils/common"
+	"github.com/cytora/go-platform-utils/server"
+)
+
+func TestHandler_Audit(t *testing.T) {
+	tests := []struct {
+		name   string
+		crn    string
+		stgErr error
+		fields []string
+
+		expectedRecord audit.Record
+	}{
+		{
+			name: "success",
+			crn:  "111222",
+			expectedRecord: audit.Record{
+				PartnerID: "acme",
+				Endpoint:  "CompanyVersions",
+				Method:    http.MethodGet,
+				CRN:       "00111222",
+				Status:    http.StatusOK,
+				Outcome:   audit.OutcomeSuccess,
+			},
+		},
+		{
+			name:   "not found",
+			crn:    "00111222",
+			stgErr: storage.ErrNotFound,
+			expectedRecord: audit.Record{
+				PartnerID: "acme",
+				Endpoint:  "CompanyVersions",
+				Method:    http.MethodGet,
+				CRN:       "00111222",
+				Status:    http.StatusNotFound,
+				Outcome:   audit.OutcomeRejected,
+			},
+		},
+		{
+			name:   "redacted",
+			crn:    "00111222",
+			fields: []string{audit.FieldCRN, audit.FieldPartnerID},
+			expectedRecord: audit.Record{
+				Endpoint: "CompanyVersions",
+				Method:   http.MethodGet,
+				Status:   http.StatusOK,
+				Outcome:  audit.OutcomeSuccess,
+			},
+		},
+	}
+
+	for i := range tests {
+		tt := tests[i]
+		t.Run(tt.name, func(t *testing.T) {
+			sink := audit.NewMemory()
+			redactor, err := audit.NewRedactor(audit.RedactDrop, tt.fields, nil)
+			assert.NoError(t, err)
+			stg := &mock.StorageMock{VersionsResults: []storage.Version{}, Err: tt.stgErr}
+			h := New(stg, WithAudit(sink, redactor))
+			router := mux.NewRouter()
+			router.HandleFunc("/v2/company/{crn}/versions", h.Audit("CompanyVersions", server.ToHTTPHandlerFunc(h.Versions)))
+			req := httptest.NewRequest(http.MethodGet, "/v2/company/"+tt.crn+"/versions", nil)
+			req = req.WithContext(common.SetAuthData(req.Context(), &common.AuthData{PartnerID: "acme"}))
+			router.ServeHTTP(httptest.NewRecorder(), req)
+
+			records := sink.Records()
+			if assert.Len(t, records, 1, "expected a record per request") {
+				got := records[0]
+				assert.False(t, got.Time.IsZero(), "expected time to be set")
+				got.Time = tt.expectedRecord.Time
+				got.LatencyMS = 0
+				assert.Equal(t, tt.expectedRecord, got, "unexpected record")
+			}
+		})
+	}
+}
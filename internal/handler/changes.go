@@ -0,0 +1,140 @@
+package handler
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cytora/go-platform-utils/logging"
+	"github.com/cytora/go-platform-utils/server"
+)
+
+const (
+	defaultChangesLimit = 100
+	cursorSeparator     = "|"
+)
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+type changesQueryParams struct {
+	Since  string `schema:"since"`
+	Cursor string `schema:"cursor"`
+	Limit  int    `schema:"limit" validate:"omitempty,min=1,max=1000"`
+}
+
+// changesCursor is the position of a consumer in the change feed, it's handed
+// out as an opaque token
+type changesCursor struct {
+	UpdatedAt time.Time
+	CRN       string
+}
+
+func (c changesCursor) Encode() string {
+	raw := c.UpdatedAt.UTC().Format(time.RFC3339Nano) + cursorSeparator + c.CRN
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeChangesCursor(cursor string) (changesCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return changesCursor{}, errInvalidCursor
+	}
+	parts := strings.SplitN(string(raw), cursorSeparator, 2)
+	if len(parts) != 2 {
+		return changesCursor{}, errInvalidCursor
+	}
+	updatedAt, err := time.Parse(time.RFC3339Nano, parts[0])
+	if err != nil {
+		return changesCursor{}, errInvalidCursor
+	}
+	return changesCursor{UpdatedAt: updatedAt, CRN: parts[1]}, nil
+}
+
+// Position returns where the feed has to be read from
+func (p *changesQueryParams) Position() (changesCursor, error) {
+	if p.Cursor != "" {
+		return decodeChangesCursor(p.Cursor)
+	}
+	if p.Since == "" {
+		return changesCursor{}, errors.New("either since or cursor is required")
+	}
+	since, err := time.Parse(time.RFC3339Nano, p.Since)
+	if err != nil {
+		return changesCursor{}, err
+	}
+	return changesCursor{UpdatedAt: since}, nil
+}
+
+type Change struct {
+	CRN       string `json:"crn"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+type ChangesResponse struct {
+	Changes    []Change `json:"changes"`
+	NextCursor string   `json:"next_cursor"`
+	HasMore    bool     `json:"has_more"`
+}
+
+// Changes lists the companies whose data changed since a point in time or
+// a cursor returned by a previous call.
+//
+// updated_at is set when a row is written but the row is only visible when
+// its transaction commits, so a row can show up behind a cursor already
+// handed out. Only the changes older than the changes' lag are listed: the
+// cursor never goes past now - lag, and as long as no write transaction lasts
+// longer than the lag every row behind it is already committed. The changes
+// show up in the feed lag after they are written
+func (h *Handler) Changes(r *http.Request) (int, interface{}, error) {
+	ctx, cancel := requestContext(r, h.opts.changesTimeout)
+	defer cancel()
+	req, err := server.Unmarshal(r, nil)
+	if err != nil {
+		logging.Error(ctx, err, nil, "invalid request")
+		return problem(r, ErrInvalidRequest, http.StatusBadRequest)
+	}
+	params := &changesQueryParams{}
+	if err := req.UnmarshalQueryParams(ctx, params, true); err != nil {
+		logging.Error(ctx, err, nil, "invalid query params")
+		return problem(r, ErrInvalidQueryParams, http.StatusBadRequest)
+	}
+	if err := h.validator.Struct(params); err != nil {
+		return problem(r, fmt.Errorf("%w %s", ErrInvalidQueryParams, err), http.StatusBadRequest, invalidParams(err)...)
+	}
+	position, err := params.Position()
+	if err != nil {
+		return problem(r, fmt.Errorf("%w %s", ErrInvalidQueryParams, err), http.StatusBadRequest, invalidParams(err)...)
+	}
+	limit := params.Limit
+	if limit == 0 {
+		limit = defaultChangesLimit
+	}
+	// truncated so that concurrent consumers of the same page share the query
+	until := h.now().Add(-h.opts.changesLag).Truncate(time.Second)
+	changes, err := h.storage.CompanyChanges(ctx, position.UpdatedAt, position.CRN, until, limit+1)
+	if err != nil {
+		logging.Error(ctx, err, logging.Data{"since": position.UpdatedAt, "after_crn": position.CRN}, "error retrieving companies' changes")
+		return internalError(r, err)
+	}
+	payload := &ChangesResponse{
+		Changes:    make([]Change, 0, limit),
+		NextCursor: position.Encode(),
+	}
+	if len(changes) > limit {
+		payload.HasMore = true
+		changes = changes[:limit]
+	}
+	for i := range changes {
+		c := changes[i]
+		payload.Changes = append(payload.Changes, Change{
+			CRN:       c.CRN.String,
+			UpdatedAt: c.UpdatedAt.Time.UTC().Format(time.RFC3339Nano),
+		})
+		position = changesCursor{UpdatedAt: c.UpdatedAt.Time, CRN: c.CRN.String}
+	}
+	payload.NextCursor = position.Encode()
+	return http.StatusOK, payload, nil
+}
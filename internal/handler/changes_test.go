@@ -0,0 +1,203 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgtype"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cytora/geospatial-lambda/internal/storage"
+	"github.com/cytora/geospatial-lambda/internal/storage/mock"
+	"github.com/cytora/go-platform-utils/common"
+	"github.com/cytora/go-platform-utils/server"
+)
+
+func Test_changesCursor(t *testing.T) {
+	c := changesCursor{UpdatedAt: time.Date(2021, 1, 2, 3, 4, 5, 6, time.UTC), CRN: "SC000123"}
+	got, err := decodeChangesCursor(c.Encode())
+	assert.Nil(t, err, "unexpected error")
+	assert.True(t, c.UpdatedAt.Equal(got.UpdatedAt), "unexpected updated at")
+	assert.Equal(t, c.CRN, got.CRN, "unexpected crn")
+
+	_, err = decodeChangesCursor("not a cursor")
+	assert.Equal(t, errInvalidCursor, err, "unexpected error")
+}
+
+func TestHandler_Changes(t *testing.T) {
+	since := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	updated := time.Date(2021, 1, 2, 0, 0, 0, 0, time.UTC)
+	now := time.Date(2021, 1, 3, 0, 0, 0, 500, time.UTC)
+	until := time.Date(2021, 1, 2, 23, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		params map[string]string
+
+		stgErr     error
+		stgResults []storage.Change
+
+		expectedStatus  int
+		expectedSince   time.Time
+		expectedLimit   int
+		expectedResults *ChangesResponse
+	}{
+		{
+			name:   "since with more",
+			params: map[string]string{"since": "2021-01-01T00:00:00Z", "limit": "1"},
+			stgResults: []storage.Change{
+				{CRN: pgtype.Text{String: "00111222", Status: pgtype.Present}, UpdatedAt: pgtype.Timestamptz{Time: updated, Status: pgtype.Present}},
+				{CRN: pgtype.Text{String: "000111333", Status: pgtype.Present}, UpdatedAt: pgtype.Timestamptz{Time: updated, Status: pgtype.Present}},
+			},
+			expectedStatus: http.StatusOK,
+			expectedSince:  since,
+			expectedLimit:  2,
+			expectedResults: &ChangesResponse{
+				Changes:    []Change{{CRN: "00111222", UpdatedAt: "2021-01-02T00:00:00Z"}},
+				NextCursor: changesCursor{UpdatedAt: updated, CRN: "00111222"}.Encode(),
+				HasMore:    true,
+			},
+		},
+		{
+			name:           "cursor without changes",
+			params:         map[string]string{"cursor": changesCursor{UpdatedAt: updated, CRN: "00111222"}.Encode()},
+			stgResults:     []storage.Change{},
+			expectedStatus: http.StatusOK,
+			expectedSince:  updated,
+			expectedLimit:  defaultChangesLimit + 1,
+			expectedResults: &ChangesResponse{
+				Changes:    []Change{},
+				NextCursor: changesCursor{UpdatedAt: updated, CRN: "00111222"}.Encode(),
+			},
+		},
+		{
+			name:   "changes within the lag are held back",
+			params: map[string]string{"since": "2021-01-01T00:00:00Z"},
+			stgResults: []storage.Change{
+				{CRN: pgtype.Text{String: "00111222", Status: pgtype.Present}, UpdatedAt: pgtype.Timestamptz{Time: updated, Status: pgtype.Present}},
+				{CRN: pgtype.Text{String: "00111333", Status: pgtype.Present}, UpdatedAt: pgtype.Timestamptz{Time: until, Status: pgtype.Present}},
+			},
+			expectedStatus: http.StatusOK,
+			expectedSince:  since,
+			expectedLimit:  defaultChangesLimit + 1,
+			expectedResults: &ChangesResponse{
+				Changes:    []Change{{CRN: "00111222", UpdatedAt: "2021-01-02T00:00:00Z"}},
+				NextCursor: changesCursor{UpdatedAt: updated, CRN: "00111222"}.Encode(),
+			},
+		},
+		{
+			name:           "missing since",
+			params:         map[string]string{},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "invalid cursor",
+			params:         map[string]string{"cursor": "xxx"},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "storage error",
+			params:         map[string]string{"since": "2021-01-01T00:00:00Z"},
+			stgErr:         errors.New("oops"),
+			expectedStatus: http.StatusInternalServerError,
+		},
+		{
+			name:           "timeout",
+			params:         map[string]string{"since": "2021-01-01T00:00:00Z"},
+			stgErr:         storage.ErrTimeout,
+			expectedStatus: http.StatusGatewayTimeout,
+		},
+	}
+
+	for i := range tests {
+		tt := tests[i]
+		t.Run(tt.name, func(t *testing.T) {
+			stg := &mock.StorageMock{
+				Err:            tt.stgErr,
+				ChangesResults: tt.stgResults,
+			}
+			h := New(stg, WithChangesLag(time.Hour))
+			h.now = func() time.Time { return now }
+			router := mux.NewRouter()
+			router.HandleFunc("/v2/companies/changes", server.ToHTTPHandlerFunc(h.Changes))
+			values := url.Values{}
+			for key, val := range tt.params {
+				values.Add(key, val)
+			}
+			u := url.URL{Path: "/v2/companies/changes", RawQuery: values.Encode()}
+			req := httptest.NewRequest(http.MethodGet, u.String(), nil)
+			req = req.WithContext(common.SetAuthData(req.Context(), &common.AuthData{PartnerID: "test"}))
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+			assert.Equal(t, tt.expectedStatus, rr.Code, "unexpected status code")
+			if tt.expectedStatus == http.StatusOK {
+				assert.True(t, tt.expectedSince.Equal(stg.CalledWithSince), "unexpected since")
+				assert.True(t, until.Equal(stg.CalledWithUntil), "unexpected until")
+				assert.Equal(t, tt.expectedLimit, stg.CalledWithLimit, "unexpected limit")
+				data, err := ioutil.ReadAll(rr.Body)
+				assert.Nil(t, err, "unexpected error reading response payload")
+				resp := &ChangesResponse{}
+				err = json.Unmarshal(data, resp)
+				assert.Nil(t, err, "unexpected error unmarshaling json data")
+				assert.Equal(t, tt.expectedResults, resp, "unexpected results")
+			}
+		})
+	}
+}
+
+// a row written by a transaction that commits after the consumer read the page
+// behind it is still listed, as long as the transaction lasted less than the lag
+func TestHandler_Changes_lateCommit(t *testing.T) {
+	lag := 5 * time.Minute
+	now := time.Date(2021, 1, 2, 12, 0, 0, 0, time.UTC)
+	change := func(crn string, updatedAt time.Time) storage.Change {
+		return storage.Change{
+			CRN:       pgtype.Text{String: crn, Status: pgtype.Present},
+			UpdatedAt: pgtype.Timestamptz{Time: updatedAt, Status: pgtype.Present},
+		}
+	}
+	stg := &mock.StorageMock{}
+	h := New(stg, WithChangesLag(lag))
+	h.now = func() time.Time { return now }
+	router := mux.NewRouter()
+	router.HandleFunc("/v2/companies/changes", server.ToHTTPHandlerFunc(h.Changes))
+	read := func(values url.Values) *ChangesResponse {
+		u := url.URL{Path: "/v2/companies/changes", RawQuery: values.Encode()}
+		req := httptest.NewRequest(http.MethodGet, u.String(), nil)
+		req = req.WithContext(common.SetAuthData(req.Context(), &common.AuthData{PartnerID: "test"}))
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code, "unexpected status code")
+		resp := &ChangesResponse{}
+		assert.Nil(t, json.NewDecoder(rr.Body).Decode(resp), "unexpected error decoding the response")
+		return resp
+	}
+
+	// the transaction writing 00111333 started a minute ago and hasn't
+	// committed yet, the one writing 00111444 has just committed
+	stg.ChangesResults = []storage.Change{
+		change("00111222", now.Add(-10*time.Minute)),
+		change("00111444", now.Add(-30*time.Second)),
+	}
+	first := read(url.Values{"since": {"2021-01-02T00:00:00Z"}})
+	assert.Equal(t, []Change{{CRN: "00111222", UpdatedAt: "2021-01-02T11:50:00Z"}}, first.Changes, "unexpected first page")
+
+	// the late transaction commits and the consumer carries on from its cursor
+	stg.ChangesResults = []storage.Change{
+		change("00111222", now.Add(-10*time.Minute)),
+		change("00111333", now.Add(-time.Minute)),
+		change("00111444", now.Add(-30*time.Second)),
+	}
+	now = now.Add(lag)
+	second := read(url.Values{"cursor": {first.NextCursor}})
+	assert.Equal(t, []Change{
+		{CRN: "00111333", UpdatedAt: "2021-01-02T11:59:00Z"},
+		{CRN: "00111444", UpdatedAt: "2021-01-02T11:59:30Z"},
+	}, second.Changes, "unexpected second page")
+}
@@ -0,0 +1,152 @@
+package handler
+
+import (
+	"context"
+	"time"
+
+	"github.com/cytora/geospatial-lambda/internal/storage"
+	"github.com/jackc/pgtype"
+)
+
+const (
+	dateLayout = "2006-01-02"
+
+	defaultFilingsPageSize  = 100
+	defaultOfficersPageSize = 25
+)
+
+type Filing struct {
+	TransactionID string `json:"transaction_id,omitempty"`
+	Date          string `json:"date,omitempty"`
+	Category      string `json:"category,omitempty"`
+	Type          string `json:"type,omitempty"`
+	Description   string `json:"description,omitempty"`
+}
+
+// FilingHistory is a page of a company's filings, newest first
+type FilingHistory struct {
+	Items    []Filing `json:"items"`
+	Page     int      `json:"page"`
+	PageSize int      `json:"page_size"`
+	HasMore  bool     `json:"has_more"`
+}
+
+type CompaniesHouse struct {
+	Status            string         `json:"status,omitempty"`
+	Type              string         `json:"type,omitempty"`
+	IncorporationDate string         `json:"incorporation_date,omitempty"`
+	DissolutionDate   string         `json:"dissolution_date,omitempty"`
+	Filings           *FilingHistory `json:"filings,omitempty"`
+}
+
+type Officer struct {
+	Name        string `json:"name"`
+	Role        string `json:"role,omitempty"`
+	AppointedOn string `json:"appointed_on,omitempty"`
+	ResignedOn  string `json:"resigned_on,omitempty"`
+	Nationality string `json:"nationality,omitempty"`
+	Occupation  string `json:"occupation,omitempty"`
+}
+
+type Officers struct {
+	Items    []Officer `json:"items"`
+	Page     int       `json:"page"`
+	PageSize int       `json:"page_size"`
+	HasMore  bool      `json:"has_more"`
+}
+
+func (ch *CompaniesHouse) isEmpty() bool {
+	return ch.Status == "" && ch.Type == "" && ch.IncorporationDate == "" &&
+		ch.DissolutionDate == "" && (ch.Filings == nil || ch.Filings.Page == 1 && len(ch.Filings.Items) == 0)
+}
+
+func formatDate(d pgtype.Date) string {
+	if d.Status != pgtype.Present {
+		return ""
+	}
+	return d.Time.Format(dateLayout)
+}
+
+// pagination defaults the page requested and returns the rows to query, one
+// extra row is requested to find out whether a further page exists
+func pagination(page, pageSize, defaultPageSize int) (int, int, storage.Page) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		pageSize = defaultPageSize
+	}
+	return page, pageSize, storage.Page{
+		Limit:  pageSize + 1,
+		Offset: (page - 1) * pageSize,
+	}
+}
+
+// loadCompaniesHouse builds the companies_house group, with a single page of
+// the company's filings made by asOf when withFilings is set
+func (h *Handler) loadCompaniesHouse(ctx context.Context, crn string, data *storage.Data, asOf time.Time, withFilings bool, page, pageSize int) (*CompaniesHouse, error) {
+	ch := &CompaniesHouse{
+		Status:            data.CHCompanyStatus.String,
+		Type:              data.CHCompanyType.String,
+		IncorporationDate: formatDate(data.CHIncorporationDate),
+		DissolutionDate:   formatDate(data.CHDissolutionDate),
+	}
+	if !withFilings {
+		return ch, nil
+	}
+	page, pageSize, rows := pagination(page, pageSize, defaultFilingsPageSize)
+	filings, err := h.storage.CompanyFilings(ctx, crn, asOf, rows)
+	if err != nil {
+		return nil, err
+	}
+	ch.Filings = &FilingHistory{
+		Items:    make([]Filing, 0, pageSize),
+		Page:     page,
+		PageSize: pageSize,
+	}
+	if len(filings) > pageSize {
+		ch.Filings.HasMore = true
+		filings = filings[:pageSize]
+	}
+	for i := range filings {
+		f := filings[i]
+		ch.Filings.Items = append(ch.Filings.Items, Filing{
+			TransactionID: f.TransactionID.String,
+			Date:          formatDate(f.Date),
+			Category:      f.Category.String,
+			Type:          f.Type.String,
+			Description:   f.Description.String,
+		})
+	}
+	return ch, nil
+}
+
+// loadOfficers retrieves a single page of officers as they were at asOf
+func (h *Handler) loadOfficers(ctx context.Context, crn string, asOf time.Time, page, pageSize int) (*Officers, error) {
+	page, pageSize, rows := pagination(page, pageSize, defaultOfficersPageSize)
+	officers, err := h.storage.CompanyOfficers(ctx, crn, asOf, rows)
+	if err != nil {
+		return nil, err
+	}
+	res := &Officers{
+		Items:    make([]Officer, 0, pageSize),
+		Page:     page,
+		PageSize: pageSize,
+	}
+	if len(officers) > pageSize {
+		res.HasMore = true
+		officers = officers[:pageSize]
+	}
+	for i := range officers {
+		o := officers[i]
+		res.Items = append(res.Items, Officer{
+			Name:        o.Name.String,
+			Role:        o.Role.String,
+			AppointedOn: formatDate(o.AppointedOn),
+			ResignedOn:  formatDate(o.ResignedOn),
+			Nationality: o.Nationality.String,
+			Occupation:  o.Occupation.String,
+		})
+	}
+	return res, nil
+}
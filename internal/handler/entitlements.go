@@ -0,0 +1,140 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/cytora/geospatial-lambda/internal/entitlements"
+	"github.com/cytora/go-platform-utils/common"
+	"github.com/cytora/go-platform-utils/logging"
+	"github.com/cytora/go-platform-utils/server"
+)
+
+// EntitlementsResponse lists what the calling partner can request
+type EntitlementsResponse struct {
+	PartnerID string   `json:"partner_id"`
+	Groups    []string `json:"groups"`
+	Fields    []string `json:"fields"`
+	Layers    []string `json:"layers"`
+}
+
+// partnerID returns the partner authenticated for the request, empty when
+// the request carries no auth data
+func partnerID(ctx context.Context) string {
+	auth := common.GetAuthData(ctx)
+	if auth == nil {
+		return ""
+	}
+	return auth.PartnerID
+}
+
+// entitlementsKey carries the entitlements loaded by Entitle in the
+// request's context
+type entitlementsKey struct{}
+
+// partnerEntitlements returns the entitlements of the calling partner, nil
+// when partners aren't restricted. The ones Entitle loaded are reused.
+func (h *Handler) partnerEntitlements(ctx context.Context) (*entitlements.Entitlements, error) {
+	if h.opts.entitlements == nil {
+		return nil, nil
+	}
+	if e, ok := ctx.Value(entitlementsKey{}).(*entitlements.Entitlements); ok {
+		return e, nil
+	}
+	partner := partnerID(ctx)
+	if partner == "" {
+		return nil, fmt.Errorf("%w missing partner", ErrForbidden)
+	}
+	e, err := h.opts.entitlements.Entitlements(ctx, partner)
+	if err != nil {
+		if errors.Is(err, entitlements.ErrUnknownPartner) {
+			return nil, fmt.Errorf("%w partner %s has no entitlements", ErrForbidden, partner)
+		}
+		return nil, err
+	}
+	return e, nil
+}
+
+// checkEntitlements tells whether the groups and fields requested are all
+// permitted, partners restricted to some fields must list the fields
+func checkEntitlements(e *entitlements.Entitlements, groups, fields []string) error {
+	if e == nil {
+		return nil
+	}
+	for i := range groups {
+		if !e.AllowsGroup(groups[i]) {
+			return fmt.Errorf("%w group %s", ErrForbidden, groups[i])
+		}
+	}
+	if e.RestrictsFields() && len(fields) == 0 {
+		return fmt.Errorf("%w fields must be listed", ErrForbidden)
+	}
+	for i := range fields {
+		if !e.AllowsField(fields[i]) {
+			return fmt.Errorf("%w field %s", ErrForbidden, fields[i])
+		}
+	}
+	return nil
+}
+
+// entitlementsError is the response of a request the partner isn't
+// entitled to, or of a failure loading the entitlements
+func entitlementsError(r *http.Request, err error) (int, interface{}, error) {
+	if errors.Is(err, ErrForbidden) {
+		return problem(r, err, http.StatusForbidden)
+	}
+	return internalError(r, err)
+}
+
+// Entitle rejects the requests of the partners without entitlements with a
+// 403 before they reach next, the entitlements loaded are passed on in the
+// request's context for next to check what's requested
+func (h *Handler) Entitle(endpoint string, next http.HandlerFunc) http.HandlerFunc {
+	if h.opts.entitlements == nil {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := requestContext(r, h.opts.entitlementsTimeout)
+		e, err := h.partnerEntitlements(ctx)
+		cancel()
+		if err != nil {
+			logging.Error(ctx, err, logging.Data{"endpoint": endpoint}, "request not entitled")
+			server.ToHTTPHandlerFunc(func(r *http.Request) (int, interface{}, error) {
+				return entitlementsError(r, err)
+			})(w, r)
+			return
+		}
+		next(w, r.WithContext(context.WithValue(r.Context(), entitlementsKey{}, e)))
+	}
+}
+
+// Entitlements lists the groups, fields and layers the calling partner can
+// request, wildcards are returned when partners aren't restricted
+func (h *Handler) Entitlements(r *http.Request) (int, interface{}, error) {
+	ctx, cancel := requestContext(r, h.opts.entitlementsTimeout)
+	defer cancel()
+	e, err := h.partnerEntitlements(ctx)
+	if err != nil {
+		logging.Error(ctx, err, nil, "error retrieving partner's entitlements")
+		return entitlementsError(r, err)
+	}
+	if e == nil {
+		e = &entitlements.Entitlements{
+			PartnerID: partnerID(ctx),
+			Groups:    []string{entitlements.Wildcard},
+			Layers:    []string{entitlements.Wildcard},
+		}
+	}
+	payload := &EntitlementsResponse{
+		PartnerID: e.PartnerID,
+		Groups:    e.Groups,
+		Fields:    e.Fields,
+		Layers:    e.Layers,
+	}
+	if !e.RestrictsFields() {
+		payload.Fields = []string{entitlements.Wildcard}
+	}
+	return http.StatusOK, payload, nil
+}
@@ -0,0 +1,246 @@
+package handler
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgtype"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cytora/geospatial-lambda/internal/entitlements"
+	"github.com/cytora/geospatial-lambda/internal/storage"
+	"github.com/cytora/geospatial-lambda/internal/storage/mock"
+	"github.com/cytora/go-platform-utils/common"
+	"github.com/cytora/go-platform-utils/server"
+)
+
+var testEntitlements = entitlements.Static{
+	"acme": {
+		PartnerID: "acme",
+		Groups:    []string{"companies_house"},
+		Layers:    []string{"flood"},
+	},
+	"initech": {
+		PartnerID: "initech",
+		Groups:    []string{"dnb"},
+		Fields:    []string{"company_name", "dnb_employees"},
+	},
+}
+
+func TestHandler_Retrieve_entitlements(t *testing.T) {
+	tests := []struct {
+		name   string
+		auth   *common.AuthData
+		params map[string]string
+
+		expectedStatus int
+	}{
+		{
+			name:           "base",
+			auth:           &common.AuthData{PartnerID: "acme"},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "entitled group",
+			auth:           &common.AuthData{PartnerID: "acme"},
+			params:         map[string]string{"groups": "companies_house"},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "not entitled group",
+			auth:           &common.AuthData{PartnerID: "acme"},
+			params:         map[string]string{"groups": "dnb"},
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "entitled fields",
+			auth:           &common.AuthData{PartnerID: "initech"},
+			params:         map[string]string{"groups": "dnb", "fields": "dnb_employees"},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "not entitled field",
+			auth:           &common.AuthData{PartnerID: "initech"},
+			params:         map[string]string{"groups": "dnb", "fields": "dnb_failure_score"},
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "fields not listed",
+			auth:           &common.AuthData{PartnerID: "initech"},
+			params:         map[string]string{"groups": "dnb"},
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "unknown partner",
+			auth:           &common.AuthData{PartnerID: "umbrella"},
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "missing partner",
+			auth:           &common.AuthData{},
+			expectedStatus: http.StatusForbidden,
+		},
+	}
+
+	for i := range tests {
+		tt := tests[i]
+		t.Run(tt.name, func(t *testing.T) {
+			stg := &mock.StorageMock{
+				Results: &storage.Data{
+					CRN: pgtype.Text{String: "00111222", Status: pgtype.Present},
+				},
+			}
+			h := New(stg, WithEntitlements(testEntitlements))
+			router := mux.NewRouter()
+			router.HandleFunc("/v2/company/{crn}", server.ToHTTPHandlerFunc(h.Retrieve))
+			values := url.Values{}
+			for key, val := range tt.params {
+				values.Add(key, val)
+			}
+			req := httptest.NewRequest(http.MethodGet, "/v2/company/00111222?"+values.Encode(), nil)
+			req = req.WithContext(common.SetAuthData(req.Context(), tt.auth))
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+			assert.Equal(t, tt.expectedStatus, rr.Code, "unexpected status code")
+			if tt.expectedStatus == http.StatusForbidden {
+				assert.False(t, stg.IsCalled, "unexpected storage call")
+			}
+		})
+	}
+}
+
+func TestHandler_Entitlements(t *testing.T) {
+	tests := []struct {
+		name  string
+		auth  *common.AuthData
+		store entitlements.Store
+
+		expectedStatus  int
+		expectedResults *EntitlementsResponse
+	}{
+		{
+			name:           "restricted groups",
+			auth:           &common.AuthData{PartnerID: "acme"},
+			store:          testEntitlements,
+			expectedStatus: http.StatusOK,
+			expectedResults: &EntitlementsResponse{
+				PartnerID: "acme",
+				Groups:    []string{"companies_house"},
+				Fields:    []string{"*"},
+				Layers:    []string{"flood"},
+			},
+		},
+		{
+			name:           "restricted fields",
+			auth:           &common.AuthData{PartnerID: "initech"},
+			store:          testEntitlements,
+			expectedStatus: http.StatusOK,
+			expectedResults: &EntitlementsResponse{
+				PartnerID: "initech",
+				Groups:    []string{"dnb"},
+				Fields:    []string{"company_name", "dnb_employees"},
+			},
+		},
+		{
+			name:           "not restricted",
+			auth:           &common.AuthData{PartnerID: "acme"},
+			expectedStatus: http.StatusOK,
+			expectedResults: &EntitlementsResponse{
+				PartnerID: "acme",
+				Groups:    []string{"*"},
+				Fields:    []string{"*"},
+				Layers:    []string{"*"},
+			},
+		},
+		{
+			name:           "unknown partner",
+			auth:           &common.AuthData{PartnerID: "umbrella"},
+			store:          testEntitlements,
+			expectedStatus: http.StatusForbidden,
+		},
+	}
+
+	for i := range tests {
+		tt := tests[i]
+		t.Run(tt.name, func(t *testing.T) {
+			var opts []OptionFunc
+			if tt.store != nil {
+				opts = append(opts, WithEntitlements(tt.store))
+			}
+			h := New(&mock.StorageMock{}, opts...)
+			router := mux.NewRouter()
+			router.HandleFunc("/v2/entitlements", server.ToHTTPHandlerFunc(h.Entitlements))
+			req := httptest.NewRequest(http.MethodGet, "/v2/entitlements", nil)
+			req = req.WithContext(common.SetAuthData(req.Context(), tt.auth))
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+			assert.Equal(t, tt.expectedStatus, rr.Code, "unexpected status code")
+			if tt.expectedStatus == http.StatusOK {
+				data, err := ioutil.ReadAll(rr.Body)
+				assert.Nil(t, err, "unexpected error reading response payload")
+				resp := &EntitlementsResponse{}
+				err = json.Unmarshal(data, resp)
+				assert.Nil(t, err, "unexpected error unmarshaling json data")
+				assert.Equal(t, tt.expectedResults, resp, "unexpected results")
+			}
+		})
+	}
+}
+
+func TestHandler_Entitle(t *testing.T) {
+	tests := []struct {
+		name string
+		auth *common.AuthData
+
+		expectedStatus int
+	}{
+		{
+			name:           "entitled partner",
+			auth:           &common.AuthData{PartnerID: "acme"},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "unknown partner",
+			auth:           &common.AuthData{PartnerID: "umbrella"},
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "missing partner",
+			auth:           &common.AuthData{},
+			expectedStatus: http.StatusForbidden,
+		},
+	}
+
+	for i := range tests {
+		tt := tests[i]
+		t.Run(tt.name, func(t *testing.T) {
+			stg := &mock.StorageMock{
+				VersionsResults: []storage.Version{{ValidFrom: pgtype.Timestamptz{Status: pgtype.Present}}},
+			}
+			h := New(stg, WithEntitlements(testEntitlements))
+			router := mux.NewRouter()
+			router.HandleFunc("/v2/company/{crn}/versions", h.Entitle("CompanyVersions", server.ToHTTPHandlerFunc(h.Versions)))
+			req := httptest.NewRequest(http.MethodGet, "/v2/company/00111222/versions", nil)
+			req = req.WithContext(common.SetAuthData(req.Context(), tt.auth))
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+			assert.Equal(t, tt.expectedStatus, rr.Code, "unexpected status code")
+			assert.Equal(t, tt.expectedStatus == http.StatusOK, stg.IsCalled, "unexpected storage call")
+		})
+	}
+}
+
+// TestHandler_Routes_entitled fails when a route serving partners skips the
+// entitlements check
+func TestHandler_Routes_entitled(t *testing.T) {
+	for _, rt := range New(&mock.StorageMock{}).Routes() {
+		if rt.RateLimited || rt.Audited {
+			assert.True(t, rt.Entitled, "expected %s to be entitled", rt.OperationID)
+		}
+	}
+}
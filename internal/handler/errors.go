@@ -10,6 +10,12 @@ var (
 	ErrHandler            = errors.New("handler error")
 	ErrInvalidRequest     = fmt.Errorf("%w invalid request", ErrHandler)
 	ErrInvalidQueryParams = fmt.Errorf("%w invalid query params", ErrHandler)
+	ErrInvalidCRN         = fmt.Errorf("%w invalid crn", ErrHandler)
 	ErrInternal           = fmt.Errorf("%w internal error", ErrHandler)
 	ErrNotFound           = fmt.Errorf("%w not found", ErrHandler)
+	ErrStaleData          = fmt.Errorf("%w stale data", ErrHandler)
+	ErrTimeout            = fmt.Errorf("%w timeout", ErrHandler)
+	ErrCancelled          = fmt.Errorf("%w cancelled", ErrHandler)
+	ErrForbidden          = fmt.Errorf("%w forbidden", ErrHandler)
+	ErrRateLimited        = fmt.Errorf("%w rate limited", ErrHandler)
 )
@@ -0,0 +1,61 @@
+package handler
+
+// The geospatial lookups are served by the Python function in api/, the
+// types below mirror its responses so GeospatialRoutes documents them in the
+// same OpenAPI document as the Go routes.
+
+type intersectQueryParams struct {
+	Latitude  float64 `schema:"latitude" validate:"required"`
+	Longitude float64 `schema:"longitude" validate:"required"`
+	Layer     string  `schema:"layer" validate:"required"`
+}
+
+// LayerGeometry is the number of features of a layer and their geometry type
+type LayerGeometry struct {
+	Count    int    `json:"count"`
+	GeomType string `json:"geom_type"`
+}
+
+// DiscoveryLayer is a PostGIS table of the public schema with a geom column.
+// Extent is the GeoJSON polygon of its features' bounding box, Version
+// changes whenever its data is reloaded.
+type DiscoveryLayer struct {
+	GISLayer string                 `json:"gis_layer"`
+	SRID     int                    `json:"srid"`
+	Geometry LayerGeometry          `json:"geometry"`
+	Extent   map[string]interface{} `json:"extent"`
+	Version  *string                `json:"version"`
+}
+
+type DiscoveryResponse struct {
+	Layers          []DiscoveryLayer `json:"layers"`
+	ExecTimeSeconds string           `json:"exec_time_seconds"`
+}
+
+type IntersectRequest struct {
+	Lat   float64 `json:"lat"`
+	Lon   float64 `json:"lon"`
+	Layer string  `json:"layer"`
+}
+
+// IntersectResponse lists the features of the layer the point falls in,
+// their properties are the columns of the layer's table but geom. Cached
+// tells whether they were served from the intersect cache.
+type IntersectResponse struct {
+	Request         IntersectRequest         `json:"request"`
+	Response        []map[string]interface{} `json:"response"`
+	ExecTimeSeconds string                   `json:"exec_time_seconds"`
+	Cached          bool                     `json:"cached"`
+}
+
+// ValidationError is the body of the 422 responses of the Python function,
+// FastAPI validates the query parameters before the lookups run
+type ValidationError struct {
+	Detail []ValidationErrorDetail `json:"detail"`
+}
+
+type ValidationErrorDetail struct {
+	Loc  []interface{} `json:"loc"`
+	Msg  string        `json:"msg"`
+	Type string        `json:"type"`
+}
@@ -1,6 +1,14 @@
 package handler
 
 import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/cytora/geospatial-lambda/internal/crn"
 	"github.com/cytora/geospatial-lambda/internal/storage"
 	"github.com/go-playground/validator"
 )
@@ -8,6 +16,9 @@ import (
 type Handler struct {
 	validator *validator.Validate
 	storage   storage.Storage
+	opts      *Options
+	now       func() time.Time
+	metadata  metadataCache
 }
 
 func New(storage storage.Storage, opts ...OptionFunc) *Handler {
@@ -15,8 +26,71 @@ func New(storage storage.Storage, opts ...OptionFunc) *Handler {
 	for _, f := range opts {
 		f(opt)
 	}
+	v := validator.New()
+	// the invalid params of the problems are named after the query params
+	v.RegisterTagNameFunc(func(f reflect.StructField) string {
+		return strings.SplitN(f.Tag.Get("schema"), ",", 2)[0]
+	})
 	return &Handler{
-		validator: validator.New(),
+		validator: v,
 		storage:   storage,
+		opts:      opt,
+		now:       time.Now,
+		metadata:  metadataCache{entries: make(map[string]metadataEntry)},
+	}
+}
+
+// requestContext bounds the request's context to the endpoint's timeout,
+// the request's context is cancelled as well when the client goes away
+func requestContext(r *http.Request, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(r.Context())
+	}
+	return context.WithTimeout(r.Context(), timeout)
+}
+
+// StatusClientClosedRequest is the status of the requests abandoned by the
+// client before a response was sent, nginx's non standard 499
+const StatusClientClosedRequest = 499
+
+// internalError is the response of the storage failures the caller can't
+// fix, running out of time and the client going away are told apart from
+// any other failure
+func internalError(r *http.Request, err error) (int, interface{}, error) {
+	switch {
+	case errors.Is(err, storage.ErrTimeout) || errors.Is(err, context.DeadlineExceeded):
+		return problem(r, ErrTimeout, http.StatusGatewayTimeout)
+	case errors.Is(err, storage.ErrCancelled) || errors.Is(err, context.Canceled):
+		return problem(r, ErrCancelled, StatusClientClosedRequest)
+	default:
+		return problem(r, ErrInternal, http.StatusInternalServerError)
+	}
+}
+
+// crnError is ErrInvalidCRN along with the reason the crn package rejected
+// the value, errors.Is matches both
+type crnError struct {
+	reason error
+}
+
+func (e *crnError) Error() string {
+	return ErrInvalidCRN.Error() + ": " + e.reason.Error()
+}
+
+func (e *crnError) Is(target error) bool {
+	return errors.Is(ErrInvalidCRN, target)
+}
+
+func (e *crnError) Unwrap() error {
+	return e.reason
+}
+
+// parseCRN normalises the company registration number received by any
+// endpoint, the returned error describes why the value is malformed
+func parseCRN(value string) (string, error) {
+	number, err := crn.Parse(value)
+	if err != nil {
+		return "", &crnError{reason: err}
 	}
+	return number, nil
 }
@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -10,11 +11,13 @@ import (
 	"net/url"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/jackc/pgtype"
 	"github.com/stretchr/testify/assert"
 
+	"github.com/cytora/geospatial-lambda/internal/crn"
 	"github.com/cytora/geospatial-lambda/internal/storage"
 	"github.com/cytora/geospatial-lambda/internal/storage/mock"
 	"github.com/cytora/go-platform-utils/common"
@@ -22,6 +25,19 @@ import (
 )
 
 func TestHandler_Retrieve(t *testing.T) {
+	loadedAt := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+	metadata := []storage.GroupMetadata{
+		{
+			Group:    pgtype.Text{String: "base", Status: pgtype.Present},
+			Source:   pgtype.Text{String: "companies_house", Status: pgtype.Present},
+			LoadedAt: pgtype.Timestamptz{Time: loadedAt, Status: pgtype.Present},
+		},
+		{
+			Group:    pgtype.Text{String: "dnb", Status: pgtype.Present},
+			Source:   pgtype.Text{String: "dun_and_bradstreet", Status: pgtype.Present},
+			LoadedAt: pgtype.Timestamptz{Time: loadedAt.Add(-48 * time.Hour), Status: pgtype.Present},
+		},
+	}
 
 	tests := []struct {
 		name   string
@@ -29,38 +45,47 @@ func TestHandler_Retrieve(t *testing.T) {
 		crn    string
 		groups []string
 
-		stgErr     error
-		stgResults *storage.Data
+		stgErr      error
+		stgResults  *storage.Data
+		stgFilings  []storage.Filing
+		stgOfficers []storage.Officer
+		stgSIC      *storage.SIC
+		stgSICErr   error
+		stgMetadata []storage.GroupMetadata
+		params      map[string]string
 
 		expectedStatus  int
+		expectedCRN     string
+		expectedPage    *storage.Page
+		expectedAsOf    time.Time
 		expectedResults *RetrieveResponse
 	}{
 		{
 			name:   "base",
 			auth:   &common.AuthData{PartnerID: "test"},
-			crn:    "000111222",
+			crn:    "00111222",
 			groups: []string{},
 
 			stgErr: nil,
 			stgResults: &storage.Data{
-				CRN: pgtype.Text{String: "000111222", Status: pgtype.Present},
+				CRN: pgtype.Text{String: "00111222", Status: pgtype.Present},
 			},
 
 			expectedStatus: http.StatusOK,
 			expectedResults: &RetrieveResponse{
-				CRN: "000111222",
+				CRN: "00111222",
 			},
 		},
 		{
 			name:   "primary trade",
 			auth:   &common.AuthData{PartnerID: "test"},
-			crn:    "000111222",
+			crn:    "00111222",
 			groups: []string{},
 
 			stgErr: nil,
 			stgResults: &storage.Data{
 				CRN: pgtype.Text{
-					String: "000111222",
+					String: "00111222",
 					Status: pgtype.Present,
 				},
 				PrimaryTrade: pgtype.Text{
@@ -71,23 +96,26 @@ func TestHandler_Retrieve(t *testing.T) {
 
 			expectedStatus: http.StatusOK,
 			expectedResults: &RetrieveResponse{
-				CRN: "000111222",
+				CRN: "00111222",
 				PrimaryTrade: &primaryTrade{
 					Code:        "00001",
 					Description: "best trade ever",
 				},
+				Warnings: []Warning{
+					{Code: WarningUnknownSICCode, Field: "primary_trade.code", Message: `SIC 2007 hierarchy not found for code "00001"`},
+				},
 			},
 		},
 		{
 			name:   "primary trade",
 			auth:   &common.AuthData{PartnerID: "test"},
-			crn:    "000111222",
+			crn:    "00111222",
 			groups: []string{},
 
 			stgErr: nil,
 			stgResults: &storage.Data{
 				CRN: pgtype.Text{
-					String: "000111222",
+					String: "00111222",
 					Status: pgtype.Present,
 				},
 				PrimaryTrade: pgtype.Text{
@@ -98,23 +126,26 @@ func TestHandler_Retrieve(t *testing.T) {
 
 			expectedStatus: http.StatusOK,
 			expectedResults: &RetrieveResponse{
-				CRN: "000111222",
+				CRN: "00111222",
 				PrimaryTrade: &primaryTrade{
 					Code:        "00001",
 					Description: "best trade ever",
 				},
+				Warnings: []Warning{
+					{Code: WarningUnknownSICCode, Field: "primary_trade.code", Message: `SIC 2007 hierarchy not found for code "00001"`},
+				},
 			},
 		},
 		{
 			name:   "broken primary trade",
 			auth:   &common.AuthData{PartnerID: "test"},
-			crn:    "000111222",
+			crn:    "00111222",
 			groups: []string{},
 
 			stgErr: nil,
 			stgResults: &storage.Data{
 				CRN: pgtype.Text{
-					String: "000111222",
+					String: "00111222",
 					Status: pgtype.Present,
 				},
 				PrimaryTrade: pgtype.Text{
@@ -125,19 +156,110 @@ func TestHandler_Retrieve(t *testing.T) {
 
 			expectedStatus: http.StatusOK,
 			expectedResults: &RetrieveResponse{
-				CRN: "000111222",
+				CRN: "00111222",
+				Warnings: []Warning{
+					{
+						Code:    WarningUnparseableField,
+						Field:   "primary_trade",
+						Message: "failed to unmarshal primary trade: neither code nor description found",
+					},
+				},
+			},
+		},
+		{
+			name:   "primary trade with sic hierarchy",
+			auth:   &common.AuthData{PartnerID: "test"},
+			crn:    "00111222",
+			groups: []string{},
+
+			stgResults: &storage.Data{
+				CRN:          pgtype.Text{String: "00111222", Status: pgtype.Present},
+				PrimaryTrade: pgtype.Text{String: `{"code": "62012"}`, Status: pgtype.Present},
+			},
+			stgSIC: &storage.SIC{
+				Code:                pgtype.Text{String: "62012", Status: pgtype.Present},
+				Description:         pgtype.Text{String: "Business and domestic software development", Status: pgtype.Present},
+				SectionCode:         pgtype.Text{String: "J", Status: pgtype.Present},
+				SectionDescription:  pgtype.Text{String: "Information and communication", Status: pgtype.Present},
+				DivisionCode:        pgtype.Text{String: "62", Status: pgtype.Present},
+				DivisionDescription: pgtype.Text{String: "Computer programming, consultancy and related activities", Status: pgtype.Present},
+				GroupCode:           pgtype.Text{String: "620", Status: pgtype.Present},
+				GroupDescription:    pgtype.Text{String: "Computer programming, consultancy and related activities", Status: pgtype.Present},
+				ClassCode:           pgtype.Text{String: "6201", Status: pgtype.Present},
+				ClassDescription:    pgtype.Text{String: "Computer programming activities", Status: pgtype.Present},
+			},
+
+			expectedStatus: http.StatusOK,
+			expectedResults: &RetrieveResponse{
+				CRN: "00111222",
+				PrimaryTrade: &primaryTrade{
+					Code:        "62012",
+					Description: "Business and domestic software development",
+					Section:     &sicLevel{Code: "J", Description: "Information and communication"},
+					Division:    &sicLevel{Code: "62", Description: "Computer programming, consultancy and related activities"},
+					Group:       &sicLevel{Code: "620", Description: "Computer programming, consultancy and related activities"},
+					Class:       &sicLevel{Code: "6201", Description: "Computer programming activities"},
+				},
+			},
+		},
+		{
+			name:   "primary trade with unknown sic code",
+			auth:   &common.AuthData{PartnerID: "test"},
+			crn:    "00111222",
+			groups: []string{},
+
+			stgResults: &storage.Data{
+				CRN:          pgtype.Text{String: "00111222", Status: pgtype.Present},
+				PrimaryTrade: pgtype.Text{String: `{"Code": "99999", "Description": "unknown"}`, Status: pgtype.Present},
+			},
+			stgSICErr: storage.ErrNotFound,
+
+			expectedStatus: http.StatusOK,
+			expectedResults: &RetrieveResponse{
+				CRN: "00111222",
+				PrimaryTrade: &primaryTrade{
+					Code:        "99999",
+					Description: "unknown",
+				},
+				Warnings: []Warning{
+					{Code: WarningUnknownSICCode, Field: "primary_trade.code", Message: `SIC 2007 hierarchy not found for code "99999"`},
+				},
+			},
+		},
+		{
+			name:   "primary trade enrichment unavailable",
+			auth:   &common.AuthData{PartnerID: "test"},
+			crn:    "00111222",
+			groups: []string{},
+
+			stgResults: &storage.Data{
+				CRN:          pgtype.Text{String: "00111222", Status: pgtype.Present},
+				PrimaryTrade: pgtype.Text{String: `{"Code": "62012", "Description": "Business and domestic software development"}`, Status: pgtype.Present},
+			},
+			stgSICErr: storage.ErrTimeout,
+
+			expectedStatus: http.StatusOK,
+			expectedResults: &RetrieveResponse{
+				CRN: "00111222",
+				PrimaryTrade: &primaryTrade{
+					Code:        "62012",
+					Description: "Business and domestic software development",
+				},
+				Warnings: []Warning{
+					{Code: WarningEnrichmentUnavailable, Field: "primary_trade", Message: "SIC 2007 hierarchy could not be retrieved, the primary trade isn't enriched"},
+				},
 			},
 		},
 		{
 			name:   "with dnd",
 			auth:   &common.AuthData{PartnerID: "test"},
-			crn:    "000111222",
+			crn:    "00111222",
 			groups: []string{"dnb"},
 
 			stgErr: nil,
 			stgResults: &storage.Data{
 				CRN: pgtype.Text{
-					String: "000111222",
+					String: "00111222",
 					Status: pgtype.Present,
 				},
 				PrimaryTrade: pgtype.Text{
@@ -150,7 +272,7 @@ func TestHandler_Retrieve(t *testing.T) {
 
 			expectedStatus: http.StatusOK,
 			expectedResults: &RetrieveResponse{
-				CRN: "000111222",
+				CRN: "00111222",
 				DnB: &DnB{
 					BlueCollarEmployees:    1,
 					Employees:              1,
@@ -166,29 +288,392 @@ func TestHandler_Retrieve(t *testing.T) {
 					WageEstimate:           0,
 					WhiteCollarEmployees:   0,
 				},
+				Warnings: []Warning{
+					{
+						Code:    WarningUnparseableField,
+						Field:   "primary_trade",
+						Message: "failed to unmarshal primary trade: neither code nor description found",
+					},
+				},
+			},
+		},
+		{
+			name:   "with companies house",
+			auth:   &common.AuthData{PartnerID: "test"},
+			crn:    "00111222",
+			groups: []string{"companies_house"},
+
+			stgErr: nil,
+			stgResults: &storage.Data{
+				CRN:                 pgtype.Text{String: "00111222", Status: pgtype.Present},
+				CHCompanyStatus:     pgtype.Text{String: "active", Status: pgtype.Present},
+				CHIncorporationDate: pgtype.Date{Time: time.Date(2001, 2, 3, 0, 0, 0, 0, time.UTC), Status: pgtype.Present},
+			},
+			stgFilings: []storage.Filing{
+				{
+					TransactionID: pgtype.Text{String: "tx1", Status: pgtype.Present},
+					Date:          pgtype.Date{Time: time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC), Status: pgtype.Present},
+					Category:      pgtype.Text{String: "accounts", Status: pgtype.Present},
+				},
+			},
+
+			expectedStatus: http.StatusOK,
+			expectedResults: &RetrieveResponse{
+				CRN: "00111222",
+				CompaniesHouse: &CompaniesHouse{
+					Status:            "active",
+					IncorporationDate: "2001-02-03",
+					Filings: &FilingHistory{
+						Items:    []Filing{{TransactionID: "tx1", Date: "2020-01-02", Category: "accounts"}},
+						Page:     1,
+						PageSize: 100,
+					},
+				},
+			},
+		},
+		{
+			name:   "with companies house filings paged",
+			auth:   &common.AuthData{PartnerID: "test"},
+			crn:    "00111222",
+			groups: []string{"companies_house"},
+			params: map[string]string{"filings_page": "3", "filings_page_size": "1"},
+
+			stgResults: &storage.Data{
+				CRN:             pgtype.Text{String: "00111222", Status: pgtype.Present},
+				CHCompanyStatus: pgtype.Text{String: "active", Status: pgtype.Present},
+			},
+			stgFilings: []storage.Filing{
+				{TransactionID: pgtype.Text{String: "tx3", Status: pgtype.Present}},
+				{TransactionID: pgtype.Text{String: "tx4", Status: pgtype.Present}},
+			},
+
+			expectedStatus: http.StatusOK,
+			expectedPage:   &storage.Page{Limit: 2, Offset: 2},
+			expectedResults: &RetrieveResponse{
+				CRN: "00111222",
+				CompaniesHouse: &CompaniesHouse{
+					Status: "active",
+					Filings: &FilingHistory{
+						Items:    []Filing{{TransactionID: "tx3"}},
+						Page:     3,
+						PageSize: 1,
+						HasMore:  true,
+					},
+				},
+			},
+		},
+		{
+			name:           "invalid filings page size",
+			auth:           &common.AuthData{PartnerID: "test"},
+			crn:            "00111222",
+			groups:         []string{"companies_house"},
+			params:         map[string]string{"filings_page_size": "101"},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:   "with officers paged",
+			auth:   &common.AuthData{PartnerID: "test"},
+			crn:    "00111222",
+			groups: []string{"officers"},
+			params: map[string]string{"officers_page": "2", "officers_page_size": "1"},
+
+			stgErr: nil,
+			stgResults: &storage.Data{
+				CRN: pgtype.Text{String: "00111222", Status: pgtype.Present},
+			},
+			stgOfficers: []storage.Officer{
+				{Name: pgtype.Text{String: "Jane Doe", Status: pgtype.Present}, Role: pgtype.Text{String: "director", Status: pgtype.Present}},
+				{Name: pgtype.Text{String: "John Doe", Status: pgtype.Present}},
+			},
+
+			expectedStatus: http.StatusOK,
+			expectedResults: &RetrieveResponse{
+				CRN: "00111222",
+				Officers: &Officers{
+					Items:    []Officer{{Name: "Jane Doe", Role: "director"}},
+					Page:     2,
+					PageSize: 1,
+					HasMore:  true,
+				},
+			},
+		},
+		{
+			name:           "invalid officers page size",
+			auth:           &common.AuthData{PartnerID: "test"},
+			crn:            "00111222",
+			groups:         []string{"officers"},
+			params:         map[string]string{"officers_page_size": "1000"},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:   "as of",
+			auth:   &common.AuthData{PartnerID: "test"},
+			crn:    "00111222",
+			groups: []string{},
+			params: map[string]string{"as_of": "2021-03-04T10:00:00Z"},
+
+			stgErr: nil,
+			stgResults: &storage.Data{
+				CRN:       pgtype.Text{String: "00111222", Status: pgtype.Present},
+				ValidFrom: pgtype.Timestamptz{Time: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC), Status: pgtype.Present},
+				ValidTo:   pgtype.Timestamptz{Status: pgtype.Null},
+			},
+
+			expectedStatus: http.StatusOK,
+			expectedResults: &RetrieveResponse{
+				CRN:     "00111222",
+				Version: &Version{ValidFrom: "2021-01-01T00:00:00Z"},
+			},
+		},
+		{
+			name:   "officers as of",
+			auth:   &common.AuthData{PartnerID: "test"},
+			crn:    "00111222",
+			groups: []string{"officers"},
+			params: map[string]string{"as_of": "2021-03-04"},
+
+			stgResults: &storage.Data{
+				CRN:       pgtype.Text{String: "00111222", Status: pgtype.Present},
+				ValidFrom: pgtype.Timestamptz{Time: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC), Status: pgtype.Present},
+			},
+			stgOfficers: []storage.Officer{{Name: pgtype.Text{String: "Jane Doe", Status: pgtype.Present}}},
+
+			expectedStatus: http.StatusOK,
+			expectedAsOf:   time.Date(2021, 3, 4, 0, 0, 0, 0, time.UTC),
+			expectedResults: &RetrieveResponse{
+				CRN:      "00111222",
+				Version:  &Version{ValidFrom: "2021-01-01T00:00:00Z"},
+				Officers: &Officers{Items: []Officer{{Name: "Jane Doe"}}, Page: 1, PageSize: 25},
+			},
+		},
+		{
+			name:           "invalid as of",
+			auth:           &common.AuthData{PartnerID: "test"},
+			crn:            "00111222",
+			groups:         []string{},
+			params:         map[string]string{"as_of": "yesterday"},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:   "normalised crn",
+			auth:   &common.AuthData{PartnerID: "test"},
+			crn:    "sc123",
+			groups: []string{},
+
+			stgResults: &storage.Data{
+				CRN: pgtype.Text{String: "SC000123", Status: pgtype.Present},
+			},
+
+			expectedStatus: http.StatusOK,
+			expectedCRN:    "SC000123",
+			expectedResults: &RetrieveResponse{
+				CRN: "SC000123",
+			},
+		},
+		{
+			name:           "invalid crn",
+			auth:           &common.AuthData{PartnerID: "test"},
+			crn:            "ZZ12345",
+			groups:         []string{},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:   "unknown groups ignored",
+			auth:   &common.AuthData{PartnerID: "test"},
+			crn:    "00111222",
+			groups: []string{"xxx", "dnb"},
+			params: map[string]string{"ignore_unknown_groups": "true"},
+
+			stgResults: &storage.Data{
+				CRN: pgtype.Text{String: "00111222", Status: pgtype.Present},
+			},
+
+			expectedStatus: http.StatusOK,
+			expectedResults: &RetrieveResponse{
+				CRN: "00111222",
+				DnB: &DnB{},
+				Warnings: []Warning{
+					{Code: WarningUnknownGroup, Field: "xxx", Message: `group "xxx" is not known and has been ignored`},
+					{Code: WarningEmptyGroup, Field: "dnb", Message: "no dnb data available for the company"},
+				},
+			},
+		},
+		{
+			name:        "with metadata",
+			auth:        &common.AuthData{PartnerID: "test"},
+			crn:         "00111222",
+			groups:      []string{},
+			params:      map[string]string{"max_age": "7200"},
+			stgMetadata: metadata,
+
+			stgResults: &storage.Data{
+				CRN: pgtype.Text{String: "00111222", Status: pgtype.Present},
+			},
+
+			expectedStatus: http.StatusOK,
+			expectedResults: &RetrieveResponse{
+				CRN: "00111222",
+				Metadata: map[string]GroupMetadata{
+					"base": {Source: "companies_house", LastUpdated: loadedAt.Format(time.RFC3339)},
+				},
+			},
+		},
+		{
+			name:        "stale data",
+			auth:        &common.AuthData{PartnerID: "test"},
+			crn:         "00111222",
+			groups:      []string{"dnb"},
+			params:      map[string]string{"max_age": "7200"},
+			stgMetadata: metadata,
+
+			stgResults: &storage.Data{
+				CRN: pgtype.Text{String: "00111222", Status: pgtype.Present},
+			},
+
+			expectedStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:        "unknown age",
+			auth:        &common.AuthData{PartnerID: "test"},
+			crn:         "00111222",
+			groups:      []string{"officers"},
+			params:      map[string]string{"max_age": "7200"},
+			stgMetadata: metadata,
+
+			stgResults: &storage.Data{
+				CRN: pgtype.Text{String: "00111222", Status: pgtype.Present},
+			},
+			stgOfficers: []storage.Officer{{Name: pgtype.Text{String: "Jane Doe", Status: pgtype.Present}}},
+
+			expectedStatus: http.StatusOK,
+			expectedResults: &RetrieveResponse{
+				CRN: "00111222",
+				Metadata: map[string]GroupMetadata{
+					"base": {Source: "companies_house", LastUpdated: loadedAt.Format(time.RFC3339)},
+				},
+				Officers: &Officers{Items: []Officer{{Name: "Jane Doe"}}, Page: 1, PageSize: 25},
+			},
+		},
+		{
+			name:        "stale data warning",
+			auth:        &common.AuthData{PartnerID: "test"},
+			crn:         "00111222",
+			groups:      []string{"dnb"},
+			params:      map[string]string{"max_age": "7200", "max_age_policy": "warn"},
+			stgMetadata: metadata,
+
+			stgResults: &storage.Data{
+				CRN:          pgtype.Text{String: "00111222", Status: pgtype.Present},
+				DnBEmployees: pgtype.Float8{Float: 1, Status: pgtype.Present},
+			},
+
+			expectedStatus: http.StatusOK,
+			expectedResults: &RetrieveResponse{
+				CRN: "00111222",
+				DnB: &DnB{Employees: 1},
+				Metadata: map[string]GroupMetadata{
+					"base": {Source: "companies_house", LastUpdated: loadedAt.Format(time.RFC3339)},
+					"dnb":  {Source: "dun_and_bradstreet", LastUpdated: loadedAt.Add(-48 * time.Hour).Format(time.RFC3339)},
+				},
+				Warnings: []Warning{
+					{Code: WarningStaleData, Field: "dnb", Message: "dnb data is older than 7200 seconds"},
+				},
 			},
 		},
 		{
 			name:           "invalid groups",
 			auth:           &common.AuthData{PartnerID: "test"},
 			stgErr:         storage.ErrInvalidGroups,
-			crn:            "000111222",
+			crn:            "00111222",
 			groups:         []string{"xxx"},
 			expectedStatus: http.StatusBadRequest,
 		},
+		{
+			name:           "invalid fields",
+			auth:           &common.AuthData{PartnerID: "test"},
+			stgErr:         storage.ErrInvalidFields,
+			crn:            "00111222",
+			groups:         []string{"dnb"},
+			params:         map[string]string{"fields": "company_name,xxx"},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "field of a group not requested",
+			auth:           &common.AuthData{PartnerID: "test"},
+			crn:            "00111222",
+			groups:         []string{"dnb"},
+			params:         map[string]string{"fields": "ch_company_status"},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:   "fields projection",
+			auth:   &common.AuthData{PartnerID: "test"},
+			crn:    "00111222",
+			groups: []string{"dnb", "companies_house", "officers"},
+			params: map[string]string{"fields": "dnb_employees,ch_company_status"},
+
+			stgResults: &storage.Data{
+				CRN:               pgtype.Text{String: "00111222", Status: pgtype.Present},
+				Name:              pgtype.Text{String: "Acme", Status: pgtype.Present},
+				RegisteredAddress: pgtype.Text{String: "1 High Street", Status: pgtype.Present},
+				DnBEmployees:      pgtype.Float8{Float: 3, Status: pgtype.Present},
+				CHCompanyStatus:   pgtype.Text{String: "active", Status: pgtype.Present},
+			},
+			stgFilings:  []storage.Filing{{TransactionID: pgtype.Text{String: "tx1", Status: pgtype.Present}}},
+			stgOfficers: []storage.Officer{{Name: pgtype.Text{String: "Jane Doe", Status: pgtype.Present}}},
+
+			expectedStatus: http.StatusOK,
+			expectedResults: &RetrieveResponse{
+				CRN:            "00111222",
+				DnB:            &DnB{Employees: 3},
+				CompaniesHouse: &CompaniesHouse{Status: "active"},
+			},
+		},
+		{
+			name:   "filings and officers projected",
+			auth:   &common.AuthData{PartnerID: "test"},
+			crn:    "00111222",
+			groups: []string{"companies_house", "officers"},
+			params: map[string]string{"fields": "company_name,filings,officers"},
+
+			stgResults: &storage.Data{
+				CRN:  pgtype.Text{String: "00111222", Status: pgtype.Present},
+				Name: pgtype.Text{String: "Acme", Status: pgtype.Present},
+			},
+			stgFilings:  []storage.Filing{{TransactionID: pgtype.Text{String: "tx1", Status: pgtype.Present}}},
+			stgOfficers: []storage.Officer{{Name: pgtype.Text{String: "Jane Doe", Status: pgtype.Present}}},
+
+			expectedStatus: http.StatusOK,
+			expectedResults: &RetrieveResponse{
+				CRN:  "00111222",
+				Name: "Acme",
+				CompaniesHouse: &CompaniesHouse{
+					Filings: &FilingHistory{Items: []Filing{{TransactionID: "tx1"}}, Page: 1, PageSize: 100},
+				},
+				Officers: &Officers{Items: []Officer{{Name: "Jane Doe"}}, Page: 1, PageSize: 25},
+			},
+		},
 		{
 			name:           "storage error",
 			auth:           &common.AuthData{PartnerID: "test"},
 			stgErr:         errors.New("oops"),
-			crn:            "000111222",
+			crn:            "00111222",
 			groups:         []string{},
 			expectedStatus: http.StatusInternalServerError,
 		},
+		{
+			name:           "timeout",
+			auth:           &common.AuthData{PartnerID: "test"},
+			stgErr:         storage.ErrTimeout,
+			crn:            "00111222",
+			groups:         []string{},
+			expectedStatus: http.StatusGatewayTimeout,
+		},
 		{
 			name:           "not found",
 			auth:           &common.AuthData{PartnerID: "test"},
 			stgErr:         storage.ErrNotFound,
-			crn:            "000111222",
+			crn:            "00111222",
 			groups:         []string{},
 			expectedStatus: http.StatusNotFound,
 		},
@@ -198,27 +683,44 @@ func TestHandler_Retrieve(t *testing.T) {
 		tt := tests[i]
 		t.Run(tt.name, func(t *testing.T) {
 			stg := &mock.StorageMock{
-				Err:     tt.stgErr,
-				Results: tt.stgResults,
+				Err:             tt.stgErr,
+				Results:         tt.stgResults,
+				FilingsResults:  tt.stgFilings,
+				OfficersResults: tt.stgOfficers,
+				SICResults:      tt.stgSIC,
+				SICErr:          tt.stgSICErr,
+				MetadataResults: tt.stgMetadata,
 			}
 			h := New(stg)
 			router := mux.NewRouter()
+			router.HandleFunc("/v2/company/{crn}", server.ToHTTPHandlerFunc(h.Retrieve))
 			endpoint := fmt.Sprintf("/v2/company/%s", tt.crn)
-			router.HandleFunc(endpoint, server.ToHTTPHandlerFunc(h.Retrieve))
 			u, err := url.Parse(endpoint)
 			assert.Nil(t, err, "unexpected error")
 
+			values := url.Values{}
 			if len(tt.groups) > 0 {
-				values := url.Values{}
 				groups := strings.Join(tt.groups, ",")
 				values.Add("groups", groups)
-				u.RawQuery = values.Encode()
 			}
+			for key, val := range tt.params {
+				values.Add(key, val)
+			}
+			u.RawQuery = values.Encode()
 			req := httptest.NewRequest(http.MethodGet, u.String(), nil)
 			req = req.WithContext(common.SetAuthData(req.Context(), tt.auth))
 			rr := httptest.NewRecorder()
 			router.ServeHTTP(rr, req)
 			assert.Equal(t, tt.expectedStatus, rr.Code, "unexpected status code")
+			if tt.expectedCRN != "" {
+				assert.Equal(t, tt.expectedCRN, stg.CalledWithCRN, "unexpected crn")
+			}
+			if tt.expectedPage != nil {
+				assert.Equal(t, *tt.expectedPage, stg.CalledWithPage, "unexpected page")
+			}
+			if !tt.expectedAsOf.IsZero() {
+				assert.Equal(t, tt.expectedAsOf, stg.CalledWithAsOf, "unexpected as of")
+			}
 			if tt.expectedStatus == http.StatusOK {
 				data, err := ioutil.ReadAll(rr.Body)
 				assert.Nil(t, err, "unexpected error reading response payload")
@@ -230,3 +732,39 @@ func TestHandler_Retrieve(t *testing.T) {
 		})
 	}
 }
+
+type testContextKey struct{}
+
+func TestHandler_Retrieve_requestContext(t *testing.T) {
+	stg := &mock.StorageMock{
+		Results: &storage.Data{
+			CRN: pgtype.Text{String: "00111222", Status: pgtype.Present},
+		},
+	}
+	h := New(stg, WithRetrieveTimeout(time.Minute))
+	router := mux.NewRouter()
+	router.HandleFunc("/v2/company/{crn}", server.ToHTTPHandlerFunc(h.Retrieve))
+	req := httptest.NewRequest(http.MethodGet, "/v2/company/00111222", nil)
+	ctx := context.WithValue(req.Context(), testContextKey{}, "value")
+	req = req.WithContext(common.SetAuthData(ctx, &common.AuthData{PartnerID: "test"}))
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code, "unexpected status code")
+	if assert.NotNil(t, stg.CalledWithCtx, "storage not called") {
+		assert.Equal(t, "value", stg.CalledWithCtx.Value(testContextKey{}), "request context not propagated")
+		deadline, ok := stg.CalledWithCtx.Deadline()
+		assert.True(t, ok, "deadline not set")
+		assert.WithinDuration(t, time.Now().Add(time.Minute), deadline, 5*time.Second, "unexpected deadline")
+	}
+}
+
+func Test_parseCRN(t *testing.T) {
+	_, err := parseCRN("000111222")
+	assert.True(t, errors.Is(err, ErrInvalidCRN), "expected ErrInvalidCRN")
+	assert.True(t, errors.Is(err, ErrHandler), "expected ErrHandler")
+	assert.True(t, errors.Is(err, crn.ErrTooLong), "expected the reason to be kept")
+
+	got, err := parseCRN(" sc123 ")
+	assert.NoError(t, err)
+	assert.Equal(t, "SC000123", got)
+}
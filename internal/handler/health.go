@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/cytora/geospatial-lambda/internal/health"
+	"github.com/cytora/go-platform-utils/logging"
+	"github.com/cytora/go-platform-utils/server"
+)
+
+type healthQueryParams struct {
+	Mode string `schema:"mode" validate:"omitempty,oneof=shallow deep"`
+}
+
+// Health reports the status of the service and its dependencies, the
+// shallow mode used by load balancers only pings the database. The deep mode
+// tells the PostGIS version and the layers' tables so only the internal
+// partners may request it. The service is reported unavailable with a 503,
+// degraded ones still serve requests.
+func (h *Handler) Health(r *http.Request) (int, interface{}, error) {
+	ctx, cancel := requestContext(r, h.opts.healthTimeout)
+	defer cancel()
+	if h.opts.health == nil {
+		return problem(r, ErrNotFound, http.StatusNotFound)
+	}
+	req, err := server.Unmarshal(r, nil)
+	if err != nil {
+		logging.Error(ctx, err, nil, "invalid request")
+		return problem(r, ErrInvalidRequest, http.StatusBadRequest)
+	}
+	params := &healthQueryParams{}
+	if err := req.UnmarshalQueryParams(ctx, params, true); err != nil {
+		logging.Error(ctx, err, nil, "invalid query params")
+		return problem(r, ErrInvalidQueryParams, http.StatusBadRequest)
+	}
+	if err := h.validator.Struct(params); err != nil {
+		return problem(r, fmt.Errorf("%w %s", ErrInvalidQueryParams, err), http.StatusBadRequest, invalidParams(err)...)
+	}
+	mode := params.Mode
+	if mode == "" {
+		mode = health.ModeShallow
+	}
+	if mode == health.ModeDeep && !h.opts.healthInternalPartners[partnerID(ctx)] {
+		return problem(r, fmt.Errorf("%w deep health check", ErrForbidden), http.StatusForbidden)
+	}
+	report := h.opts.health.Check(ctx, mode)
+	if report.Status == health.StatusUnavailable {
+		logging.Info(ctx, logging.Data{"mode": mode, "error": report.Database.Error}, "service unavailable")
+		return http.StatusServiceUnavailable, report, nil
+	}
+	return http.StatusOK, report, nil
+}
@@ -0,0 +1,118 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cytora/geospatial-lambda/internal/health"
+	"github.com/cytora/geospatial-lambda/internal/storage"
+	"github.com/cytora/geospatial-lambda/internal/storage/mock"
+	"github.com/cytora/go-platform-utils/common"
+	"github.com/cytora/go-platform-utils/server"
+)
+
+type healthCheckerMock struct {
+	pingErr error
+}
+
+func (c *healthCheckerMock) Ping(ctx context.Context) error {
+	return c.pingErr
+}
+
+func (c *healthCheckerMock) PostGISVersion(ctx context.Context) (string, error) {
+	return "3.1.4", nil
+}
+
+func (c *healthCheckerMock) Layers(ctx context.Context) ([]storage.Layer, error) {
+	return nil, nil
+}
+
+func TestHandler_Health(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		partner string
+		pingErr error
+
+		expectedStatus       int
+		expectedMode         string
+		expectedHealthStatus string
+		expectedPostGIS      string
+	}{
+		{
+			name:                 "shallow by default",
+			expectedStatus:       http.StatusOK,
+			expectedMode:         "shallow",
+			expectedHealthStatus: "ok",
+		},
+		{
+			name:                 "deep",
+			query:                "?mode=deep",
+			partner:              "ops",
+			expectedStatus:       http.StatusOK,
+			expectedMode:         "deep",
+			expectedHealthStatus: "ok",
+			expectedPostGIS:      "3.1.4",
+		},
+		{
+			name:           "deep external partner",
+			query:          "?mode=deep",
+			partner:        "acme",
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "deep anonymous",
+			query:          "?mode=deep",
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:                 "unavailable",
+			query:                "?mode=deep",
+			partner:              "ops",
+			pingErr:              storage.ErrTimeout,
+			expectedStatus:       http.StatusServiceUnavailable,
+			expectedMode:         "deep",
+			expectedHealthStatus: "unavailable",
+		},
+		{
+			name:           "invalid mode",
+			query:          "?mode=full",
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checks := health.New(&healthCheckerMock{pingErr: tt.pingErr}, "v1.2.3", nil, 0)
+			h := New(&mock.StorageMock{}, WithHealth(checks), WithHealthInternalPartners([]string{"ops"}))
+			router := mux.NewRouter()
+			router.HandleFunc("/health", server.ToHTTPHandlerFunc(h.Health))
+
+			req := httptest.NewRequest(http.MethodGet, "/health"+tt.query, nil)
+			if tt.partner != "" {
+				req = req.WithContext(common.SetAuthData(req.Context(), &common.AuthData{PartnerID: tt.partner}))
+			}
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code, "unexpected status code")
+			if tt.expectedHealthStatus == "" {
+				return
+			}
+			body, err := ioutil.ReadAll(rr.Body)
+			assert.NoError(t, err)
+			report := &health.Report{}
+			assert.NoError(t, json.Unmarshal(body, report))
+			assert.Equal(t, tt.expectedMode, report.Mode, "unexpected mode")
+			assert.Equal(t, tt.expectedHealthStatus, report.Status, "unexpected health status")
+			assert.Equal(t, "v1.2.3", report.Version, "unexpected version")
+			assert.Equal(t, tt.expectedPostGIS, report.Database.PostGISVersion, "unexpected postgis version")
+		})
+	}
+}
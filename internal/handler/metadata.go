@@ -0,0 +1,107 @@
+package handler
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/cytora/geospatial-lambda/internal/storage"
+	"github.com/jackc/pgtype"
+)
+
+const (
+	maxAgePolicyWarn = "warn"
+	// maxMetadataEntries bounds the metadata cache, the combinations of groups
+	// requested are few but a partner can still request many of them
+	maxMetadataEntries = 128
+)
+
+// GroupMetadata tells where a group's data comes from and when it was last
+// loaded
+type GroupMetadata struct {
+	Source      string `json:"source"`
+	LastUpdated string `json:"last_updated"`
+}
+
+// metadataCache keeps the groups' latest loads in memory for the metadata
+// ttl, they only change when the data is loaded so a query per request isn't
+// needed. The loads as of a point in time are left to the storage's cache,
+// as of is picked by the caller and would grow the map without bound
+type metadataCache struct {
+	mu      sync.Mutex
+	entries map[string]metadataEntry
+}
+
+type metadataEntry struct {
+	loads     []storage.GroupMetadata
+	expiresAt time.Time
+}
+
+// groupsLoads returns the latest loads of the base group and of every group
+// requested as they were at asOf
+func (h *Handler) groupsLoads(ctx context.Context, groups []string, asOf time.Time) ([]storage.GroupMetadata, error) {
+	groups = append([]string{storage.GroupBase}, groups...)
+	if h.opts.metadataTTL <= 0 || !asOf.IsZero() {
+		return h.storage.GroupsMetadata(ctx, groups, asOf)
+	}
+	key := storage.GroupsMetadataKey(groups, asOf)
+	h.metadata.mu.Lock()
+	entry, ok := h.metadata.entries[key]
+	h.metadata.mu.Unlock()
+	if ok && h.now().Before(entry.expiresAt) {
+		return entry.loads, nil
+	}
+	loads, err := h.storage.GroupsMetadata(ctx, groups, asOf)
+	if err != nil {
+		return nil, err
+	}
+	h.metadata.set(key, metadataEntry{loads: loads, expiresAt: h.now().Add(h.opts.metadataTTL)}, h.now())
+	return loads, nil
+}
+
+// set adds the entry, the expired entries are dropped when the cache is full
+// and all of them when none has expired
+func (c *metadataCache) set(key string, entry metadataEntry, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= maxMetadataEntries {
+		for k, e := range c.entries {
+			if !now.Before(e.expiresAt) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= maxMetadataEntries {
+			c.entries = make(map[string]metadataEntry, maxMetadataEntries)
+		}
+	}
+	c.entries[key] = entry
+}
+
+// loadMetadata returns the metadata of the groups loaded, keyed by group
+func loadMetadata(loads []storage.GroupMetadata) map[string]GroupMetadata {
+	metadata := make(map[string]GroupMetadata, len(loads))
+	for i := range loads {
+		l := loads[i]
+		if l.LoadedAt.Status != pgtype.Present {
+			continue
+		}
+		metadata[l.Group.String] = GroupMetadata{
+			Source:      l.Source.String,
+			LastUpdated: formatTimestamp(l.LoadedAt),
+		}
+	}
+	return metadata
+}
+
+// staleGroups returns the groups last loaded more than maxAge before now,
+// groups never loaded have an unknown age and aren't reported
+func staleGroups(loads []storage.GroupMetadata, maxAge time.Duration, now time.Time) []string {
+	var stale []string
+	for i := range loads {
+		l := loads[i]
+		if l.LoadedAt.Status == pgtype.Present && now.Sub(l.LoadedAt.Time) > maxAge {
+			stale = append(stale, l.Group.String)
+		}
+	}
+	return stale
+}
@@ -0,0 +1,49 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/cytora/geospatial-lambda/internal/metrics"
+)
+
+// Instrument emits the latency of the requests to endpoint and the class
+// of the errors they fail with. The metrics of a request, its queries' ones
+// included, are batched and written once it's done.
+func (h *Handler) Instrument(endpoint string, next http.HandlerFunc) http.HandlerFunc {
+	if h.opts.metrics == nil {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		ts := time.Now()
+		ctx := metrics.WithBatch(r.Context())
+		defer h.opts.metrics.Flush(ctx)
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next(rec, r.WithContext(ctx))
+		h.opts.metrics.Emit(ctx, metrics.EndpointLatency, metrics.Milliseconds(time.Since(ts)), metrics.UnitMilliseconds, metrics.Dimensions{"Endpoint": endpoint})
+		if errorType := errorClass(rec.status); errorType != "" {
+			h.opts.metrics.Emit(ctx, metrics.EndpointErrors, 1, metrics.UnitCount, metrics.Dimensions{"Endpoint": endpoint, "ErrorType": errorType})
+		}
+	}
+}
+
+// errorClass classifies the responses with an error status, it's empty for
+// the successful ones and the requests the client abandoned
+func errorClass(status int) string {
+	switch {
+	case status < http.StatusBadRequest || status == StatusClientClosedRequest:
+		return ""
+	case status == http.StatusNotFound:
+		return "not_found"
+	case status == http.StatusForbidden:
+		return "forbidden"
+	case status == http.StatusTooManyRequests:
+		return "rate_limited"
+	case status == http.StatusGatewayTimeout:
+		return "timeout"
+	case status < http.StatusInternalServerError:
+		return "client_error"
+	default:
+		return "internal"
+	}
+}
@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cytora/geospatial-lambda/internal/metrics"
+	"github.com/cytora/geospatial-lambda/internal/storage"
+	"github.com/cytora/geospatial-lambda/internal/storage/mock"
+	"github.com/cytora/go-platform-utils/server"
+)
+
+func TestHandler_Instrument(t *testing.T) {
+	tests := []struct {
+		name              string
+		stgErr            error
+		expectedErrorType string
+	}{
+		{
+			name: "success",
+		},
+		{
+			name:              "not found",
+			stgErr:            storage.ErrNotFound,
+			expectedErrorType: "not_found",
+		},
+		{
+			name:              "timeout",
+			stgErr:            storage.ErrTimeout,
+			expectedErrorType: "timeout",
+		},
+		{
+			name:   "cancelled",
+			stgErr: storage.ErrCancelled,
+		},
+		{
+			name:              "internal",
+			stgErr:            storage.ErrStorage,
+			expectedErrorType: "internal",
+		},
+	}
+	for i := range tests {
+		tt := tests[i]
+		t.Run(tt.name, func(t *testing.T) {
+			reg := metrics.NewRegistry()
+			stg := &mock.StorageMock{VersionsResults: []storage.Version{}, Err: tt.stgErr}
+			h := New(stg, WithMetrics(reg))
+			router := mux.NewRouter()
+			router.HandleFunc("/v2/company/{crn}/versions", h.Instrument("CompanyVersions", server.ToHTTPHandlerFunc(h.Versions)))
+
+			req := httptest.NewRequest(http.MethodGet, "/v2/company/00111222/versions", nil)
+			router.ServeHTTP(httptest.NewRecorder(), req)
+
+			latency, ok := reg.Get(metrics.EndpointLatency, metrics.Dimensions{"Endpoint": "CompanyVersions"})
+			assert.True(t, ok, "expected latency to be emitted")
+			assert.Equal(t, 1, latency.Count, "unexpected latency count")
+			assert.Equal(t, metrics.UnitMilliseconds, latency.Unit, "unexpected latency unit")
+			for _, errorType := range []string{"not_found", "timeout", "internal"} {
+				errs, _ := reg.Get(metrics.EndpointErrors, metrics.Dimensions{"Endpoint": "CompanyVersions", "ErrorType": errorType})
+				want := 0
+				if errorType == tt.expectedErrorType {
+					want = 1
+				}
+				assert.Equal(t, want, errs.Count, "unexpected %s errors", errorType)
+			}
+		})
+	}
+}
+
+func TestHandler_Instrument_batch(t *testing.T) {
+	var b bytes.Buffer
+	stg := &mock.StorageMock{VersionsResults: []storage.Version{}, Err: storage.ErrTimeout}
+	h := New(stg, WithMetrics(metrics.NewEMF(&b, "GeospatialLambda", nil)))
+	router := mux.NewRouter()
+	router.HandleFunc("/v2/company/{crn}/versions", h.Instrument("CompanyVersions", server.ToHTTPHandlerFunc(h.Versions)))
+
+	req := httptest.NewRequest(http.MethodGet, "/v2/company/00111222/versions", nil)
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.Equal(t, 1, bytes.Count(b.Bytes(), []byte("\n")), "expected the request's metrics in one document")
+	assert.Contains(t, b.String(), metrics.EndpointLatency)
+	assert.Contains(t, b.String(), metrics.EndpointErrors)
+}
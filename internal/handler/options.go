@@ -1,13 +1,170 @@
 package handler
 
-import "github.com/cytora/geospatial-lambda/internal/storage"
+import (
+	"time"
+
+	"github.com/cytora/geospatial-lambda/internal/audit"
+	"github.com/cytora/geospatial-lambda/internal/entitlements"
+	"github.com/cytora/geospatial-lambda/internal/health"
+	"github.com/cytora/geospatial-lambda/internal/metering"
+	"github.com/cytora/geospatial-lambda/internal/metrics"
+	"github.com/cytora/geospatial-lambda/internal/ratelimit"
+)
 
 type OptionFunc func(opt *Options)
 
+// Options of the handler, the timeouts are the deadlines of the endpoints'
+// requests and 0 disables them. The groups' metadata is queried on every
+// request without a metadata ttl. Partners aren't restricted without an
+// entitlements store, lookups aren't metered without a meter, requests
+// aren't rate limited without a limiter nor audited without a sink,
+// endpoints aren't instrumented without a metrics emitter and spans are left
+// to the provider's batching without a span flusher.
 type Options struct {
-    storage storage.Storage // nolint
+	retrieveTimeout time.Duration
+	versionsTimeout time.Duration
+	changesTimeout  time.Duration
+	usageTimeout    time.Duration
+	healthTimeout   time.Duration
+	// entitlementsTimeout bounds loading the partner's entitlements
+	entitlementsTimeout time.Duration
+	metadataTTL         time.Duration
+	entitlements        entitlements.Store
+	meter               *metering.Meter
+	usage               metering.Summarizer
+	limiter             *ratelimit.Limiter
+	audit               audit.Sink
+	redactor            *audit.Redactor
+	metrics             metrics.Emitter
+	spanFlusher         SpanFlusher
+	spanFlushTimeout    time.Duration
+	health              *health.Health
+	// healthInternalPartners may request the deep health check
+	healthInternalPartners map[string]bool
+	// changesLag holds back the changes more recent than the longest write
+	// transaction, which may still commit rows updated before them
+	changesLag time.Duration
 }
 
 func defaultHandlerOptions() *Options {
 	return &Options{}
 }
+
+func WithRetrieveTimeout(timeout time.Duration) OptionFunc {
+	return func(opt *Options) {
+		opt.retrieveTimeout = timeout
+	}
+}
+
+func WithVersionsTimeout(timeout time.Duration) OptionFunc {
+	return func(opt *Options) {
+		opt.versionsTimeout = timeout
+	}
+}
+
+func WithChangesTimeout(timeout time.Duration) OptionFunc {
+	return func(opt *Options) {
+		opt.changesTimeout = timeout
+	}
+}
+
+func WithUsageTimeout(timeout time.Duration) OptionFunc {
+	return func(opt *Options) {
+		opt.usageTimeout = timeout
+	}
+}
+
+// WithChangesLag only lists the changes older than lag, it has to be at least
+// the longest transaction writing the companies' data
+func WithChangesLag(lag time.Duration) OptionFunc {
+	return func(opt *Options) {
+		opt.changesLag = lag
+	}
+}
+
+// WithMetadataTTL keeps the groups' metadata in memory for ttl
+func WithMetadataTTL(ttl time.Duration) OptionFunc {
+	return func(opt *Options) {
+		opt.metadataTTL = ttl
+	}
+}
+
+func WithEntitlementsTimeout(timeout time.Duration) OptionFunc {
+	return func(opt *Options) {
+		opt.entitlementsTimeout = timeout
+	}
+}
+
+func WithEntitlements(store entitlements.Store) OptionFunc {
+	return func(opt *Options) {
+		opt.entitlements = store
+	}
+}
+
+func WithMeter(meter *metering.Meter) OptionFunc {
+	return func(opt *Options) {
+		opt.meter = meter
+	}
+}
+
+func WithUsageSummarizer(usage metering.Summarizer) OptionFunc {
+	return func(opt *Options) {
+		opt.usage = usage
+	}
+}
+
+func WithRateLimiter(limiter *ratelimit.Limiter) OptionFunc {
+	return func(opt *Options) {
+		opt.limiter = limiter
+	}
+}
+
+// WithAudit records the accesses to sink, with the fields configured in
+// redactor hidden
+func WithAudit(sink audit.Sink, redactor *audit.Redactor) OptionFunc {
+	return func(opt *Options) {
+		opt.audit = sink
+		opt.redactor = redactor
+	}
+}
+
+// WithMetrics emits the endpoints' latency and errors to emitter
+func WithMetrics(emitter metrics.Emitter) OptionFunc {
+	return func(opt *Options) {
+		opt.metrics = emitter
+	}
+}
+
+// WithSpanFlusher flushes the spans at the end of every request, waiting up
+// to timeout which delays every response as much
+func WithSpanFlusher(flusher SpanFlusher, timeout time.Duration) OptionFunc {
+	return func(opt *Options) {
+		opt.spanFlusher = flusher
+		opt.spanFlushTimeout = timeout
+	}
+}
+
+func WithHealthTimeout(timeout time.Duration) OptionFunc {
+	return func(opt *Options) {
+		opt.healthTimeout = timeout
+	}
+}
+
+func WithHealth(checks *health.Health) OptionFunc {
+	return func(opt *Options) {
+		opt.health = checks
+	}
+}
+
+// WithHealthInternalPartners lets partners request the deep health check,
+// nobody can without them
+func WithHealthInternalPartners(partners []string) OptionFunc {
+	return func(opt *Options) {
+		opt.healthInternalPartners = make(map[string]bool, len(partners))
+		for i := range partners {
+			if partners[i] != "" {
+				opt.healthInternalPartners[partners[i]] = true
+			}
+		}
+	}
+}
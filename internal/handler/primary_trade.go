@@ -0,0 +1,104 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/cytora/geospatial-lambda/internal/storage"
+	"github.com/cytora/go-platform-utils/logging"
+)
+
+// sicLevel is one level of the UK SIC 2007 hierarchy
+type sicLevel struct {
+	Code        string `json:"code"`
+	Description string `json:"description,omitempty"`
+}
+
+type primaryTrade struct {
+	Code        string    `json:"code"`
+	Description string    `json:"description"`
+	Section     *sicLevel `json:"section,omitempty"`
+	Division    *sicLevel `json:"division,omitempty"`
+	Group       *sicLevel `json:"group,omitempty"`
+	Class       *sicLevel `json:"class,omitempty"`
+}
+
+// loadPrimaryTrade parses the primary trade stored as a JSON object, keys
+// are matched regardless of their case and codes can be strings or numbers.
+// When several keys only differ by their case the lower case one wins,
+// otherwise the last one in byte order
+func loadPrimaryTrade(data []byte) (*primaryTrade, error) {
+	s := make(map[string]interface{})
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	if err := d.Decode(&s); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal primary trade: %w", err)
+	}
+	keys := make([]string, 0, len(s))
+	for key := range s {
+		keys = append(keys, key)
+	}
+	// upper case letters sort before lower case ones, the lower case key is
+	// the last of the keys differing by their case and overwrites the others
+	sort.Strings(keys)
+	pt := &primaryTrade{}
+	for _, key := range keys {
+		val := s[key]
+		var v string
+		switch t := val.(type) {
+		case string:
+			v = strings.TrimSpace(t)
+		case json.Number:
+			v = t.String()
+		default:
+			continue
+		}
+		switch strings.ToLower(key) {
+		case "code":
+			pt.Code = v
+		case "description":
+			pt.Description = v
+		}
+	}
+	if pt.Code == "" && pt.Description == "" {
+		return nil, fmt.Errorf("failed to unmarshal primary trade: neither code nor description found")
+	}
+	return pt, nil
+}
+
+// enrichPrimaryTrade adds the SIC hierarchy of the primary trade's code, a
+// warning is returned when the code isn't part of SIC 2007 or when the
+// hierarchy can't be retrieved
+func (h *Handler) enrichPrimaryTrade(ctx context.Context, pt *primaryTrade) *Warning {
+	if pt.Code == "" {
+		return nil
+	}
+	sic, err := h.storage.SICHierarchy(ctx, pt.Code)
+	if err == storage.ErrNotFound {
+		return &Warning{
+			Code:    WarningUnknownSICCode,
+			Field:   "primary_trade.code",
+			Message: fmt.Sprintf("SIC 2007 hierarchy not found for code %q", pt.Code),
+		}
+	}
+	if err != nil {
+		logging.Error(ctx, err, logging.Data{"sic_code": pt.Code}, "error retrieving sic hierarchy")
+		return &Warning{
+			Code:    WarningEnrichmentUnavailable,
+			Field:   "primary_trade",
+			Message: "SIC 2007 hierarchy could not be retrieved, the primary trade isn't enriched",
+		}
+	}
+	pt.Section = &sicLevel{Code: sic.SectionCode.String, Description: sic.SectionDescription.String}
+	pt.Division = &sicLevel{Code: sic.DivisionCode.String, Description: sic.DivisionDescription.String}
+	pt.Group = &sicLevel{Code: sic.GroupCode.String, Description: sic.GroupDescription.String}
+	pt.Class = &sicLevel{Code: sic.ClassCode.String, Description: sic.ClassDescription.String}
+	if pt.Description == "" {
+		pt.Description = sic.Description.String
+	}
+	return nil
+}
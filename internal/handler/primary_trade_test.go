@@ -0,0 +1,54 @@
+package handler
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_loadPrimaryTrade(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    *primaryTrade
+		wantErr bool
+	}{
+		{
+			name: "capitalised keys",
+			data: `{"Code": "62012", "Description": "Business and domestic software development"}`,
+			want: &primaryTrade{Code: "62012", Description: "Business and domestic software development"},
+		},
+		{
+			name: "lower case keys",
+			data: `{"code": "62012", "description": "Business and domestic software development"}`,
+			want: &primaryTrade{Code: "62012", Description: "Business and domestic software development"},
+		},
+		{
+			name: "keys differing by their case",
+			data: `{"CODE": "62011", "code": "62012", "Code": "62013", "Description": "Business and domestic software development", "DESCRIPTION": "BUSINESS SOFTWARE"}`,
+			want: &primaryTrade{Code: "62012", Description: "Business and domestic software development"},
+		},
+		{
+			name: "numeric code",
+			data: `{"code": 62012}`,
+			want: &primaryTrade{Code: "62012"},
+		},
+		{
+			name:    "unknown keys",
+			data:    `{"xxxx": "00001", "yyyy":"best trade ever"}`,
+			wantErr: true,
+		},
+		{
+			name:    "not json",
+			data:    `62012`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := loadPrimaryTrade([]byte(tt.data))
+			assert.Equal(t, tt.wantErr, err != nil, "unexpected error %v", err)
+			assert.Equal(t, tt.want, got, "unexpected primary trade")
+		})
+	}
+}
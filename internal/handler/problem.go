@@ -0,0 +1,164 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/go-playground/validator"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/cytora/geospatial-lambda/internal/storage"
+)
+
+// ProblemContentType is the media type of the error responses
+const ProblemContentType = "application/problem+json"
+
+// types of the problems, relative URIs resolved against the API's host
+const (
+	ProblemInvalidRequest     = "/problems/invalid-request"
+	ProblemInvalidQueryParams = "/problems/invalid-query-params"
+	ProblemInvalidCRN         = "/problems/invalid-crn"
+	ProblemInvalidGroups      = "/problems/invalid-groups"
+	ProblemInvalidFields      = "/problems/invalid-fields"
+	ProblemNotFound           = "/problems/not-found"
+	ProblemStaleData          = "/problems/stale-data"
+	ProblemForbidden          = "/problems/forbidden"
+	ProblemRateLimited        = "/problems/rate-limited"
+	ProblemTimeout            = "/problems/timeout"
+	ProblemCancelled          = "/problems/cancelled"
+	ProblemInternal           = "/problems/internal"
+)
+
+// InvalidParam tells why a parameter of the request was rejected
+type InvalidParam struct {
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+// Problem is the body of the error responses, as defined by RFC 7807. It's
+// an error so clients can return it once decoded, problems of the same
+// type match with errors.Is.
+type Problem struct {
+	Type          string         `json:"type"`
+	Title         string         `json:"title"`
+	Status        int            `json:"status"`
+	Detail        string         `json:"detail,omitempty"`
+	Instance      string         `json:"instance,omitempty"`
+	InvalidParams []InvalidParam `json:"invalid_params,omitempty"`
+	RequestID     string         `json:"request_id,omitempty"`
+}
+
+func (p *Problem) Error() string {
+	if p.Detail == "" {
+		return p.Title
+	}
+	return p.Title + ": " + p.Detail
+}
+
+func (p *Problem) Is(target error) bool {
+	t, ok := target.(*Problem)
+	return ok && t.Type == p.Type
+}
+
+type problemType struct {
+	err   error
+	uri   string
+	title string
+}
+
+// problemTypes maps the sentinels of the handler and storage packages to
+// the problem types, the first one err matches is used
+var problemTypes = []problemType{
+	{err: ErrInvalidCRN, uri: ProblemInvalidCRN, title: "Invalid company registration number"},
+	{err: storage.ErrInvalidGroups, uri: ProblemInvalidGroups, title: "Invalid groups"},
+	{err: storage.ErrInvalidFields, uri: ProblemInvalidFields, title: "Invalid fields"},
+	{err: ErrInvalidQueryParams, uri: ProblemInvalidQueryParams, title: "Invalid query parameters"},
+	{err: ErrInvalidRequest, uri: ProblemInvalidRequest, title: "Invalid request"},
+	{err: ErrNotFound, uri: ProblemNotFound, title: "Not found"},
+	{err: storage.ErrNotFound, uri: ProblemNotFound, title: "Not found"},
+	{err: ErrStaleData, uri: ProblemStaleData, title: "Stale data"},
+	{err: ErrForbidden, uri: ProblemForbidden, title: "Forbidden"},
+	{err: ErrRateLimited, uri: ProblemRateLimited, title: "Too many requests"},
+	{err: ErrTimeout, uri: ProblemTimeout, title: "Timeout"},
+	{err: storage.ErrTimeout, uri: ProblemTimeout, title: "Timeout"},
+	{err: ErrCancelled, uri: ProblemCancelled, title: "Client closed request"},
+	{err: storage.ErrCancelled, uri: ProblemCancelled, title: "Client closed request"},
+	{err: ErrInternal, uri: ProblemInternal, title: "Internal error"},
+}
+
+// newProblem describes err, the detail is what err adds to its sentinel.
+// Errors without a problem type get about:blank and the status' text.
+func newProblem(r *http.Request, err error, status int, params []InvalidParam) *Problem {
+	p := &Problem{
+		Type:          "about:blank",
+		Title:         http.StatusText(status),
+		Status:        status,
+		Instance:      r.URL.Path,
+		InvalidParams: params,
+		RequestID:     requestID(r),
+	}
+	for _, t := range problemTypes {
+		if errors.Is(err, t.err) {
+			p.Type = t.uri
+			p.Title = t.title
+			p.Detail = strings.TrimLeft(strings.TrimPrefix(err.Error(), t.err.Error()), ": ")
+			return p
+		}
+	}
+	return p
+}
+
+// problem is the response of the requests failing with err
+func problem(r *http.Request, err error, status int, params ...InvalidParam) (int, interface{}, error) {
+	return status, newProblem(r, err, status, params), nil
+}
+
+// requestID identifies the request in the problems, the id set by the API
+// gateway or the trace's id otherwise
+func requestID(r *http.Request) string {
+	if id := r.Header.Get("X-Request-Id"); id != "" {
+		return id
+	}
+	if sc := trace.SpanContextFromContext(r.Context()); sc.HasTraceID() {
+		return sc.TraceID().String()
+	}
+	return ""
+}
+
+// invalidParams lists the parameters the validator rejected, named after
+// their query parameter
+func invalidParams(err error) []InvalidParam {
+	var errs validator.ValidationErrors
+	if !errors.As(err, &errs) {
+		return nil
+	}
+	params := make([]InvalidParam, 0, len(errs))
+	for _, e := range errs {
+		reason := "failed " + e.Tag()
+		if e.Param() != "" {
+			reason += "=" + e.Param()
+		}
+		params = append(params, InvalidParam{Name: e.Field(), Reason: reason})
+	}
+	return params
+}
+
+// ProblemJSON sets the media type of the error responses to
+// ProblemContentType
+func ProblemJSON(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		next(&problemWriter{ResponseWriter: w}, r)
+	}
+}
+
+type problemWriter struct {
+	http.ResponseWriter
+}
+
+func (w *problemWriter) WriteHeader(status int) {
+	if status >= http.StatusBadRequest {
+		w.Header().Set("Content-Type", ProblemContentType)
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
@@ -0,0 +1,157 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cytora/geospatial-lambda/internal/storage"
+	"github.com/cytora/geospatial-lambda/internal/storage/mock"
+	"github.com/cytora/go-platform-utils/common"
+	"github.com/cytora/go-platform-utils/server"
+)
+
+func Test_newProblem(t *testing.T) {
+	tests := []struct {
+		name           string
+		err            error
+		status         int
+		expectedType   string
+		expectedTitle  string
+		expectedDetail string
+	}{
+		{
+			name:          "handler sentinel",
+			err:           ErrInvalidRequest,
+			status:        http.StatusBadRequest,
+			expectedType:  ProblemInvalidRequest,
+			expectedTitle: "Invalid request",
+		},
+		{
+			name:           "wrapped handler sentinel",
+			err:            fmt.Errorf("%w invalid as_of %s", ErrInvalidQueryParams, "yesterday"),
+			status:         http.StatusBadRequest,
+			expectedType:   ProblemInvalidQueryParams,
+			expectedTitle:  "Invalid query parameters",
+			expectedDetail: "invalid as_of yesterday",
+		},
+		{
+			name:           "invalid crn",
+			err:            fmt.Errorf("%w: %s", ErrInvalidCRN, "too long"),
+			status:         http.StatusBadRequest,
+			expectedType:   ProblemInvalidCRN,
+			expectedTitle:  "Invalid company registration number",
+			expectedDetail: "too long",
+		},
+		{
+			name:          "storage sentinel",
+			err:           storage.ErrInvalidGroups,
+			status:        http.StatusBadRequest,
+			expectedType:  ProblemInvalidGroups,
+			expectedTitle: "Invalid groups",
+		},
+		{
+			name:          "storage timeout",
+			err:           storage.ErrTimeout,
+			status:        http.StatusGatewayTimeout,
+			expectedType:  ProblemTimeout,
+			expectedTitle: "Timeout",
+		},
+		{
+			name:          "client gone",
+			err:           storage.ErrCancelled,
+			status:        StatusClientClosedRequest,
+			expectedType:  ProblemCancelled,
+			expectedTitle: "Client closed request",
+		},
+		{
+			name:          "unknown error",
+			err:           errors.New("connection refused"),
+			status:        http.StatusInternalServerError,
+			expectedType:  "about:blank",
+			expectedTitle: "Internal Server Error",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/v2/company/00111222", nil)
+			r.Header.Set("X-Request-Id", "req-1")
+			p := newProblem(r, tt.err, tt.status, nil)
+			assert.Equal(t, tt.expectedType, p.Type, "unexpected type")
+			assert.Equal(t, tt.expectedTitle, p.Title, "unexpected title")
+			assert.Equal(t, tt.expectedDetail, p.Detail, "unexpected detail")
+			assert.Equal(t, tt.status, p.Status, "unexpected status")
+			assert.Equal(t, "/v2/company/00111222", p.Instance, "unexpected instance")
+			assert.Equal(t, "req-1", p.RequestID, "unexpected request id")
+			assert.True(t, errors.Is(p, &Problem{Type: tt.expectedType}), "expected problems of the same type to match")
+		})
+	}
+}
+
+func TestHandler_Retrieve_problems(t *testing.T) {
+	tests := []struct {
+		name                  string
+		path                  string
+		stgErr                error
+		expectedStatus        int
+		expectedType          string
+		expectedInvalidParams []InvalidParam
+	}{
+		{
+			name:                  "invalid groups",
+			path:                  "/v2/company/00111222?groups=xxx",
+			stgErr:                storage.ErrInvalidGroups,
+			expectedStatus:        http.StatusBadRequest,
+			expectedType:          ProblemInvalidGroups,
+			expectedInvalidParams: []InvalidParam{{Name: "groups", Reason: "unknown group"}},
+		},
+		{
+			name:                  "invalid query params",
+			path:                  "/v2/company/00111222?groups=dnb&max_age_policy=ignore",
+			expectedStatus:        http.StatusBadRequest,
+			expectedType:          ProblemInvalidQueryParams,
+			expectedInvalidParams: []InvalidParam{{Name: "max_age_policy", Reason: "failed oneof=error warn"}},
+		},
+		{
+			name:           "invalid crn",
+			path:           "/v2/company/XX?groups=dnb",
+			expectedStatus: http.StatusBadRequest,
+			expectedType:   ProblemInvalidCRN,
+		},
+		{
+			name:           "not found",
+			path:           "/v2/company/00111222?groups=dnb",
+			stgErr:         storage.ErrNotFound,
+			expectedStatus: http.StatusNotFound,
+			expectedType:   ProblemNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := New(&mock.StorageMock{Err: tt.stgErr})
+			router := mux.NewRouter()
+			router.HandleFunc("/v2/company/{crn}", ProblemJSON(server.ToHTTPHandlerFunc(h.Retrieve)))
+
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req = req.WithContext(common.SetAuthData(req.Context(), &common.AuthData{PartnerID: "test"}))
+			req.Header.Set("X-Request-Id", "req-1")
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code, "unexpected status code")
+			assert.Equal(t, ProblemContentType, rr.Header().Get("Content-Type"), "unexpected content type")
+			p := &Problem{}
+			assert.NoError(t, json.NewDecoder(rr.Body).Decode(p))
+			assert.Equal(t, tt.expectedType, p.Type, "unexpected type")
+			assert.Equal(t, tt.expectedStatus, p.Status, "unexpected problem status")
+			assert.Equal(t, "req-1", p.RequestID, "unexpected request id")
+			assert.Equal(t, tt.expectedInvalidParams, p.InvalidParams, "unexpected invalid params")
+		})
+	}
+}
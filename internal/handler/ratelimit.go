@@ -0,0 +1,44 @@
+package handler
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/cytora/go-platform-utils/logging"
+	"github.com/cytora/go-platform-utils/server"
+)
+
+// RateLimit rejects the requests to endpoint of the partners over their
+// rate or daily quota, or whose bucket is too contended to be counted, with a
+// 429 telling when to retry. Requests are let through when the limiter's
+// store fails, the limits protect the database but aren't worth an outage.
+func (h *Handler) RateLimit(endpoint string, next http.HandlerFunc) http.HandlerFunc {
+	if h.opts.limiter == nil {
+		return next
+	}
+	rejected := server.ToHTTPHandlerFunc(func(r *http.Request) (int, interface{}, error) {
+		return problem(r, ErrRateLimited, http.StatusTooManyRequests)
+	})
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		partner := partnerID(ctx)
+		decision, err := h.opts.limiter.Allow(ctx, partner, endpoint)
+		if err != nil {
+			logging.Error(ctx, err, logging.Data{"partner_id": partner, "endpoint": endpoint}, "rate limiter error")
+			next(w, r)
+			return
+		}
+		if !decision.Allowed {
+			logging.Info(ctx, logging.Data{"partner_id": partner, "endpoint": endpoint, "reason": decision.Reason, "retry_after": decision.RetryAfter}, "request rate limited")
+			retryAfter := int(math.Ceil(decision.RetryAfter.Seconds()))
+			if retryAfter < 1 {
+				retryAfter = 1
+			}
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+			rejected(w, r)
+			return
+		}
+		next(w, r)
+	}
+}
@@ -0,0 +1,106 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cytora/geospatial-lambda/internal/ratelimit"
+	"github.com/cytora/geospatial-lambda/internal/storage"
+	"github.com/cytora/geospatial-lambda/internal/storage/mock"
+	"github.com/cytora/go-platform-utils/common"
+	"github.com/cytora/go-platform-utils/server"
+)
+
+func TestHandler_RateLimit(t *testing.T) {
+	limiter := ratelimit.New(ratelimit.NewMemory(), ratelimit.Limits{
+		"CompanyVersions": {Rate: 1, Burst: 1},
+	})
+	stg := &mock.StorageMock{VersionsResults: []storage.Version{}}
+	h := New(stg, WithRateLimiter(limiter))
+	router := mux.NewRouter()
+	router.HandleFunc("/v2/company/{crn}/versions", h.RateLimit("CompanyVersions", server.ToHTTPHandlerFunc(h.Versions)))
+
+	call := func(partner string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/v2/company/00111222/versions", nil)
+		req = req.WithContext(common.SetAuthData(req.Context(), &common.AuthData{PartnerID: partner}))
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := call("acme")
+	assert.NotEqual(t, http.StatusTooManyRequests, rr.Code, "expected first request to be allowed")
+	rr = call("acme")
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code, "expected second request to be limited")
+	assert.Equal(t, "1", rr.Header().Get("Retry-After"), "unexpected Retry-After")
+	rr = call("initech")
+	assert.NotEqual(t, http.StatusTooManyRequests, rr.Code, "expected other partners not to be limited")
+}
+
+// failingStore fails every read or every write of the buckets
+type failingStore struct {
+	*ratelimit.Memory
+	getErr error
+	putErr error
+}
+
+func (s *failingStore) Get(ctx context.Context, key string) (ratelimit.State, int64, error) {
+	if s.getErr != nil {
+		return ratelimit.State{}, 0, s.getErr
+	}
+	return s.Memory.Get(ctx, key)
+}
+
+func (s *failingStore) Put(ctx context.Context, key string, state ratelimit.State, version int64) error {
+	if s.putErr != nil {
+		return s.putErr
+	}
+	return s.Memory.Put(ctx, key, state, version)
+}
+
+func TestHandler_RateLimit_storeFailures(t *testing.T) {
+	tests := []struct {
+		name  string
+		store *failingStore
+
+		expectedStatus     int
+		expectedRetryAfter string
+	}{
+		{
+			name:               "every write conflicts",
+			store:              &failingStore{Memory: ratelimit.NewMemory(), putErr: ratelimit.ErrConflict},
+			expectedStatus:     http.StatusTooManyRequests,
+			expectedRetryAfter: "1",
+		},
+		{
+			name:           "store outage",
+			store:          &failingStore{Memory: ratelimit.NewMemory(), getErr: errors.New("oops")},
+			expectedStatus: http.StatusOK,
+		},
+	}
+	for i := range tests {
+		tt := tests[i]
+		t.Run(tt.name, func(t *testing.T) {
+			limiter := ratelimit.New(tt.store, ratelimit.Limits{
+				"CompanyVersions": {Rate: 1, Burst: 1},
+			})
+			stg := &mock.StorageMock{VersionsResults: []storage.Version{}}
+			h := New(stg, WithRateLimiter(limiter))
+			router := mux.NewRouter()
+			router.HandleFunc("/v2/company/{crn}/versions", h.RateLimit("CompanyVersions", server.ToHTTPHandlerFunc(h.Versions)))
+
+			req := httptest.NewRequest(http.MethodGet, "/v2/company/00111222/versions", nil)
+			req = req.WithContext(common.SetAuthData(req.Context(), &common.AuthData{PartnerID: "acme"}))
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+			assert.Equal(t, tt.expectedStatus, rr.Code, "unexpected status code")
+			assert.Equal(t, tt.expectedRetryAfter, rr.Header().Get("Retry-After"), "unexpected Retry-After")
+		})
+	}
+}
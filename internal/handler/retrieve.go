@@ -1,12 +1,13 @@
 package handler
 
 import (
-	"context"
-	"encoding/json"
 	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
+	"github.com/cytora/geospatial-lambda/internal"
+	"github.com/cytora/geospatial-lambda/internal/metering"
 	"github.com/cytora/geospatial-lambda/internal/storage"
 	"github.com/cytora/go-platform-utils/logging"
 	"github.com/cytora/go-platform-utils/server"
@@ -14,40 +15,106 @@ import (
 )
 
 type retrieveQueryParams struct {
-	Groups string `schema:"groups"`
+	Groups              string `schema:"groups"`
+	Fields              string `schema:"fields"`
+	AsOf                string `schema:"as_of"`
+	IgnoreUnknownGroups bool   `schema:"ignore_unknown_groups"`
+	MaxAge              int    `schema:"max_age" validate:"omitempty,min=1"`
+	MaxAgePolicy        string `schema:"max_age_policy" validate:"omitempty,oneof=error warn"`
+	FilingsPage         int    `schema:"filings_page" validate:"omitempty,min=1"`
+	FilingsPageSize     int    `schema:"filings_page_size" validate:"omitempty,min=1,max=100"`
+	OfficersPage        int    `schema:"officers_page" validate:"omitempty,min=1"`
+	OfficersPageSize    int    `schema:"officers_page_size" validate:"omitempty,min=1,max=100"`
 }
 
+// NormalizeGroups returns the groups requested once each, without the base
+// group which is always returned, so none is queried or billed twice
 func (p *retrieveQueryParams) NormalizeGroups() []string {
-	groups := strings.Split(p.Groups, ",")
-	var normalisedGroups []string
-	for i := range groups {
-		group := groups[i]
-		g := strings.ToLower(strings.TrimSpace(group))
-		if len(g) > 0 {
-			normalisedGroups = append(normalisedGroups, g)
+	var groups []string
+	for _, group := range normalizeList(p.Groups) {
+		if group != storage.GroupBase {
+			groups = append(groups, group)
 		}
 	}
-	return normalisedGroups
+	return groups
+}
+
+func (p *retrieveQueryParams) NormalizeFields() []string {
+	return normalizeList(p.Fields)
 }
 
-type primaryTrade struct {
-	Code        string `json:"code"`
-	Description string `json:"description"`
+// ParseAsOf returns the point in time the data is requested at, zero when the
+// current data is requested. Both RFC3339 timestamps and plain dates are accepted.
+func (p *retrieveQueryParams) ParseAsOf() (time.Time, error) {
+	asOf := strings.TrimSpace(p.AsOf)
+	if asOf == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, asOf); err == nil {
+		return t, nil
+	}
+	return time.Parse(dateLayout, asOf)
+}
+
+// splitUnknownGroups returns the groups storage knows and the unknown ones
+func splitUnknownGroups(groups []string) ([]string, []string) {
+	var known, unknown []string
+	for i := range groups {
+		if storage.IsGroup(groups[i]) {
+			known = append(known, groups[i])
+		} else {
+			unknown = append(unknown, groups[i])
+		}
+	}
+	return known, unknown
 }
 
-func loadPrimaryTrade(data []byte) (*primaryTrade, error) {
-	s := make(map[string]string)
-	if err := json.Unmarshal(data, &s); err != nil {
-		return nil, err
+// projection is the set of fields requested, every field is projected when
+// none is listed
+type projection map[string]bool
+
+func newProjection(fields []string) projection {
+	if len(fields) == 0 {
+		return nil
 	}
-	pt := &primaryTrade{
-		Code:        s["Code"],
-		Description: s["Description"],
+	p := make(projection, len(fields))
+	for i := range fields {
+		p[fields[i]] = true
 	}
-	if pt.Code == "" && pt.Description == "" {
-		return nil, fmt.Errorf("failed to unmarshal primary trade")
+	return p
+}
+
+// has tells whether field is returned
+func (p projection) has(field string) bool {
+	return p == nil || p[field]
+}
+
+// hasGroup tells whether any field of group is returned, the groups
+// without any aren't loaded
+func (p projection) hasGroup(group string) bool {
+	for _, field := range storage.GroupFields(group) {
+		if p.has(field) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeList returns the items of a comma separated list lower cased,
+// without blanks and duplicates, in the order they were first given
+func normalizeList(list string) []string {
+	items := strings.Split(list, ",")
+	seen := make(map[string]bool, len(items))
+	var normalisedItems []string
+	for i := range items {
+		item := items[i]
+		v := strings.ToLower(strings.TrimSpace(item))
+		if len(v) > 0 && !seen[v] {
+			seen[v] = true
+			normalisedItems = append(normalisedItems, v)
+		}
 	}
-	return pt, nil
+	return normalisedItems
 }
 
 type DnB struct {
@@ -67,59 +134,139 @@ type DnB struct {
 }
 
 type RetrieveResponse struct {
-	CRN               string        `json:"crn"`
-	Name              string        `json:"company_name"`
-	PrimaryTrade      *primaryTrade `json:"primary_trade"`
-	RegisteredAddress string        `json:"registered_address"`
-	DnB               *DnB          `json:"dnb,omitempty"`
+	CRN               string                   `json:"crn"`
+	Name              string                   `json:"company_name,omitempty"`
+	PrimaryTrade      *primaryTrade            `json:"primary_trade,omitempty"`
+	RegisteredAddress string                   `json:"registered_address,omitempty"`
+	DnB               *DnB                     `json:"dnb,omitempty"`
+	CompaniesHouse    *CompaniesHouse          `json:"companies_house,omitempty"`
+	Version           *Version                 `json:"version,omitempty"`
+	Metadata          map[string]GroupMetadata `json:"metadata,omitempty"`
+	Officers          *Officers                `json:"officers,omitempty"`
+	Warnings          []Warning                `json:"warnings,omitempty"`
 }
 
 func (h *Handler) Retrieve(r *http.Request) (int, interface{}, error) {
-	ctx := context.Background()
+	ctx, cancel := requestContext(r, h.opts.retrieveTimeout)
+	defer cancel()
 	req, err := server.Unmarshal(r, nil)
 	if err != nil {
 		logging.Error(ctx, err, nil, "invalid request")
-		return server.ErrorToResponse(ErrInvalidRequest, http.StatusBadRequest)
+		return problem(r, ErrInvalidRequest, http.StatusBadRequest)
 	}
 	params := &retrieveQueryParams{}
 	if err := req.UnmarshalQueryParams(ctx, params, true); err != nil {
 		logging.Error(ctx, err, nil, "invalid query params")
-		return server.ErrorToResponse(ErrInvalidQueryParams, http.StatusBadRequest)
+		return problem(r, ErrInvalidQueryParams, http.StatusBadRequest)
 	}
 	if err := h.validator.Struct(params); err != nil {
-		return server.ErrorToResponse(fmt.Errorf("%w %s", ErrInvalidQueryParams, err), http.StatusBadRequest)
+		return problem(r, fmt.Errorf("%w %s", ErrInvalidQueryParams, err), http.StatusBadRequest, invalidParams(err)...)
+	}
+	asOf, err := params.ParseAsOf()
+	if err != nil {
+		return problem(r, fmt.Errorf("%w invalid as_of %s", ErrInvalidQueryParams, err), http.StatusBadRequest)
+	}
+	crn, err := parseCRN(req.PathParams["crn"])
+	if err != nil {
+		return problem(r, err, http.StatusBadRequest)
 	}
-	crn := req.PathParams["crn"]
 	groups := params.NormalizeGroups()
-	data, err := h.storage.CompanyData(ctx, crn, groups)
+	var warnings []Warning
+	if params.IgnoreUnknownGroups {
+		var unknown []string
+		groups, unknown = splitUnknownGroups(groups)
+		for i := range unknown {
+			warnings = append(warnings, unknownGroupWarning(unknown[i]))
+		}
+	}
+	fields := params.NormalizeFields()
+	if !storage.ValidateGroups(groups) {
+		return problem(r, storage.ErrInvalidGroups, http.StatusBadRequest, InvalidParam{Name: "groups", Reason: "unknown group"})
+	}
+	if !storage.ValidateFields(groups, fields) {
+		return problem(r, storage.ErrInvalidFields, http.StatusBadRequest, InvalidParam{Name: "fields", Reason: "unknown field or field of a group not requested"})
+	}
+	projected := newProjection(fields)
+	partnerEntitlements, err := h.partnerEntitlements(ctx)
+	if err == nil {
+		err = checkEntitlements(partnerEntitlements, groups, fields)
+	}
+	if err != nil {
+		logging.Error(ctx, err, logging.Data{"crn": crn}, "request not entitled")
+		return entitlementsError(r, err)
+	}
+	data, err := h.storage.CompanyData(ctx, crn, groups, fields, asOf)
 	if err != nil {
 		logging.Error(ctx, err, logging.Data{"crn": crn}, "error retrieving company's data")
 		switch err {
 		case storage.ErrNotFound:
-			return server.ErrorToResponse(ErrNotFound, http.StatusNotFound)
+			return problem(r, ErrNotFound, http.StatusNotFound)
 		case storage.ErrInvalidGroups:
-			return server.ErrorToResponse(ErrInvalidRequest, http.StatusBadRequest)
+			return problem(r, err, http.StatusBadRequest, InvalidParam{Name: "groups", Reason: "unknown group"})
+		case storage.ErrInvalidFields:
+			return problem(r, err, http.StatusBadRequest, InvalidParam{Name: "fields", Reason: "unknown field or field of a group not requested"})
 		default:
-			return server.ErrorToResponse(ErrInternal, http.StatusInternalServerError)
+			return internalError(r, err)
 		}
 	}
 	payload := &RetrieveResponse{
-		CRN:               data.CRN.String,
-		Name:              data.Name.String,
-		RegisteredAddress: data.RegisteredAddress.String,
+		CRN:      data.CRN.String,
+		Warnings: warnings,
 	}
-	if data.PrimaryTrade.Status == pgtype.Present {
+	if projected.has("company_name") {
+		payload.Name = data.Name.String
+	}
+	if projected.has("registered_address") {
+		payload.RegisteredAddress = data.RegisteredAddress.String
+	}
+	if !asOf.IsZero() {
+		payload.Version = loadVersion(data.ValidFrom, data.ValidTo)
+	}
+	loads, err := h.groupsLoads(ctx, groups, asOf)
+	if err != nil {
+		logging.Error(ctx, err, logging.Data{"crn": crn}, "error retrieving groups' metadata")
+		return internalError(r, err)
+	}
+	payload.Metadata = loadMetadata(loads)
+	if params.MaxAge > 0 {
+		// the data requested as of a point in time is as old as it was then
+		now := h.now()
+		if !asOf.IsZero() {
+			now = asOf
+		}
+		stale := staleGroups(loads, time.Duration(params.MaxAge)*time.Second, now)
+		if len(stale) > 0 && params.MaxAgePolicy != maxAgePolicyWarn {
+			return problem(r, fmt.Errorf("%w %s older than max_age", ErrStaleData, strings.Join(stale, ",")), http.StatusUnprocessableEntity)
+		}
+		for i := range stale {
+			payload.Warnings = append(payload.Warnings, staleDataWarning(stale[i], params.MaxAge))
+		}
+	}
+	if projected.has("primary_trade") && data.PrimaryTrade.Status == pgtype.Present {
 		pt, err := loadPrimaryTrade([]byte(data.PrimaryTrade.String))
 		if err != nil {
 			logging.Error(ctx, err, logging.Data{"crn": crn, "primary_trade": data.PrimaryTrade.String}, "failed to parse primary trade")
+			payload.Warnings = append(payload.Warnings, Warning{
+				Code:    WarningUnparseableField,
+				Field:   "primary_trade",
+				Message: err.Error(),
+			})
 		} else {
+			if warning := h.enrichPrimaryTrade(ctx, pt); warning != nil {
+				payload.Warnings = append(payload.Warnings, *warning)
+			}
 			payload.PrimaryTrade = pt
 		}
 	}
+	billed := []string{storage.GroupBase}
 	for i := range groups {
 		group := groups[i]
+		if !projected.hasGroup(group) {
+			continue
+		}
+		billed = append(billed, group)
 		switch group {
-		case "dnb":
+		case storage.GroupDnB:
 			dnb := &DnB{}
 			dnb.BlueCollarEmployees = data.DnBBlueCollarEmployees.Float
 			dnb.DelinquencyScore = data.DnBDelinquencyScore
@@ -132,7 +279,31 @@ func (h *Handler) Retrieve(r *http.Request) (int, interface{}, error) {
 			dnb.WageEstimate = data.DnBWageEstimate.Float
 			dnb.WhiteCollarEmployees = data.DnBWhiteCollarEmployees.Float
 			payload.DnB = dnb
+			if len(fields) == 0 && *dnb == (DnB{}) {
+				payload.Warnings = append(payload.Warnings, emptyGroupWarning(group))
+			}
+		case storage.GroupCompaniesHouse:
+			ch, err := h.loadCompaniesHouse(ctx, crn, data, asOf, projected.has(storage.FieldFilings), params.FilingsPage, params.FilingsPageSize)
+			if err != nil {
+				logging.Error(ctx, err, logging.Data{"crn": crn}, "error retrieving company's filings")
+				return internalError(r, err)
+			}
+			payload.CompaniesHouse = ch
+			if len(fields) == 0 && ch.isEmpty() {
+				payload.Warnings = append(payload.Warnings, emptyGroupWarning(group))
+			}
+		case storage.GroupOfficers:
+			officers, err := h.loadOfficers(ctx, crn, asOf, params.OfficersPage, params.OfficersPageSize)
+			if err != nil {
+				logging.Error(ctx, err, logging.Data{"crn": crn}, "error retrieving company's officers")
+				return internalError(r, err)
+			}
+			payload.Officers = officers
+			if officers.Page == 1 && len(officers.Items) == 0 {
+				payload.Warnings = append(payload.Warnings, emptyGroupWarning(group))
+			}
 		}
 	}
+	h.meter(r, internal.CompanyDataEndpoint, metering.ResourceGroup, billed, 1)
 	return http.StatusOK, payload, nil
 }
@@ -1,8 +1,16 @@
 package handler
 
 import (
+	"context"
 	"reflect"
+	"strconv"
 	"testing"
+	"time"
+
+	"github.com/cytora/geospatial-lambda/internal/storage"
+	"github.com/cytora/geospatial-lambda/internal/storage/mock"
+	"github.com/jackc/pgtype"
+	"github.com/stretchr/testify/assert"
 )
 
 func Test_retrieveQueryParams_NormalizeGroups(t *testing.T) {
@@ -47,3 +55,129 @@ func Test_retrieveQueryParams_NormalizeGroups(t *testing.T) {
 		})
 	}
 }
+
+func Test_retrieveQueryParams_NormalizeFields(t *testing.T) {
+	tests := []struct {
+		name   string
+		fields string
+		want   []string
+	}{
+		{
+			name:   "empty",
+			fields: "",
+			want:   nil,
+		},
+		{
+			name:   "spaces and case",
+			fields: " DnB_Employees , ,dnb_max_credit",
+			want:   []string{"dnb_employees", "dnb_max_credit"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &retrieveQueryParams{
+				Fields: tt.fields,
+			}
+			if got := p.NormalizeFields(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("retrieveQueryParams.NormalizeFields() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_staleGroups(t *testing.T) {
+	now := time.Date(2021, 1, 2, 0, 0, 0, 0, time.UTC)
+	load := func(group string, loadedAt time.Time) storage.GroupMetadata {
+		return storage.GroupMetadata{
+			Group:    pgtype.Text{String: group, Status: pgtype.Present},
+			LoadedAt: pgtype.Timestamptz{Time: loadedAt, Status: pgtype.Present},
+		}
+	}
+	base := load("base", time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC))
+	dnb := load("dnb", time.Date(2020, 12, 1, 0, 0, 0, 0, time.UTC))
+	tests := []struct {
+		name   string
+		loads  []storage.GroupMetadata
+		maxAge time.Duration
+		want   []string
+	}{
+		{
+			name:   "fresh",
+			loads:  []storage.GroupMetadata{base},
+			maxAge: 24 * time.Hour,
+			want:   nil,
+		},
+		{
+			name:   "stale group",
+			loads:  []storage.GroupMetadata{base, dnb},
+			maxAge: 24 * time.Hour,
+			want:   []string{"dnb"},
+		},
+		{
+			name:   "unknown age",
+			loads:  []storage.GroupMetadata{base, {Group: pgtype.Text{String: "officers", Status: pgtype.Present}}},
+			maxAge: 24 * time.Hour,
+			want:   nil,
+		},
+		{
+			name:   "all stale",
+			loads:  []storage.GroupMetadata{base, dnb},
+			maxAge: time.Hour,
+			want:   []string{"base", "dnb"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := staleGroups(tt.loads, tt.maxAge, now); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("staleGroups() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandler_groupsLoads(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2021, 1, 2, 0, 0, 0, 0, time.UTC)
+	stg := &mock.StorageMock{
+		MetadataResults: []storage.GroupMetadata{
+			{Group: pgtype.Text{String: "base", Status: pgtype.Present}},
+			{Group: pgtype.Text{String: "dnb", Status: pgtype.Present}},
+		},
+	}
+	h := New(stg, WithMetadataTTL(time.Minute))
+	h.now = func() time.Time { return now }
+
+	loads, err := h.groupsLoads(ctx, []string{"dnb"}, time.Time{})
+	assert.Nil(t, err, "unexpected error")
+	assert.Len(t, loads, 2, "unexpected loads")
+	_, _ = h.groupsLoads(ctx, []string{"dnb"}, time.Time{})
+	assert.Equal(t, 1, stg.MetadataCalls, "metadata should be cached")
+
+	asOf := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	_, _ = h.groupsLoads(ctx, []string{"dnb"}, asOf)
+	_, _ = h.groupsLoads(ctx, []string{"dnb"}, asOf)
+	assert.Equal(t, 3, stg.MetadataCalls, "metadata as of a point in time shouldn't be cached")
+	assert.Len(t, h.metadata.entries, 1, "unexpected entries")
+
+	now = now.Add(2 * time.Minute)
+	_, _ = h.groupsLoads(ctx, []string{"dnb"}, time.Time{})
+	assert.Equal(t, 4, stg.MetadataCalls, "metadata should expire")
+}
+
+func Test_metadataCache_set(t *testing.T) {
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := metadataCache{entries: make(map[string]metadataEntry)}
+	c.set("expired", metadataEntry{expiresAt: now}, now)
+	for i := 1; i < maxMetadataEntries; i++ {
+		c.set(strconv.Itoa(i), metadataEntry{expiresAt: now.Add(time.Minute)}, now)
+	}
+	assert.Len(t, c.entries, maxMetadataEntries, "unexpected entries")
+
+	c.set("new", metadataEntry{expiresAt: now.Add(time.Minute)}, now)
+	assert.Len(t, c.entries, maxMetadataEntries, "the expired entry should be dropped")
+	assert.NotContains(t, c.entries, "expired", "the expired entry should be dropped")
+
+	c.set("newer", metadataEntry{expiresAt: now.Add(time.Minute)}, now)
+	assert.Len(t, c.entries, 1, "the full cache should be reset")
+	assert.Contains(t, c.entries, "newer", "unexpected entries")
+}
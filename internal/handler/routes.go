@@ -0,0 +1,189 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/cytora/geospatial-lambda/internal"
+	"github.com/cytora/geospatial-lambda/internal/health"
+	"github.com/cytora/geospatial-lambda/internal/openapi"
+)
+
+// APIVersion is the version of the API in the OpenAPI document
+const APIVersion = "2.0.0"
+
+// Route is an endpoint served by the handler with its documentation. Every
+// route serving partners is entitled, the ones of their lookups are rate
+// limited and audited too.
+type Route struct {
+	openapi.Route
+	Handle      func(r *http.Request) (int, interface{}, error)
+	Entitled    bool
+	RateLimited bool
+	Audited     bool
+}
+
+// responses documents body as the response of a success and the statuses
+// of the problems
+func responses(body interface{}, problems ...int) map[int]interface{} {
+	res := map[int]interface{}{http.StatusOK: body}
+	for _, status := range problems {
+		res[status] = nil
+	}
+	return res
+}
+
+// Routes lists the endpoints served, they're registered and documented
+// from this list so the OpenAPI document can't miss any
+func (h *Handler) Routes() []Route {
+	return []Route{
+		{
+			Route: openapi.Route{
+				OperationID: internal.CompanyDataEndpoint,
+				Method:      http.MethodGet,
+				Path:        "/v2/company/{crn}",
+				Summary:     "Retrieve a company's data by group, as of a point in time when requested",
+				Query:       &retrieveQueryParams{},
+				Responses: responses(RetrieveResponse{},
+					http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound, http.StatusUnprocessableEntity,
+					http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusGatewayTimeout),
+			},
+			Handle:      h.Retrieve,
+			Entitled:    true,
+			RateLimited: true,
+			Audited:     true,
+		},
+		{
+			Route: openapi.Route{
+				OperationID: internal.CompanyChangesEndpoint,
+				Method:      http.MethodGet,
+				Path:        "/v2/companies/changes",
+				Summary:     "List the companies updated since a point in time or a cursor",
+				Query:       &changesQueryParams{},
+				Responses: responses(ChangesResponse{},
+					http.StatusBadRequest, http.StatusForbidden, http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusGatewayTimeout),
+			},
+			Handle:      h.Changes,
+			Entitled:    true,
+			RateLimited: true,
+			Audited:     true,
+		},
+		{
+			Route: openapi.Route{
+				OperationID: internal.CompanyVersionsEndpoint,
+				Method:      http.MethodGet,
+				Path:        "/v2/company/{crn}/versions",
+				Summary:     "List the snapshots available for a company, newest first",
+				Responses: responses(VersionsResponse{},
+					http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound, http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusGatewayTimeout),
+			},
+			Handle:      h.Versions,
+			Entitled:    true,
+			RateLimited: true,
+			Audited:     true,
+		},
+		{
+			Route: openapi.Route{
+				OperationID: internal.EntitlementsEndpoint,
+				Method:      http.MethodGet,
+				Path:        "/v2/entitlements",
+				Summary:     "List the groups, fields and layers the calling partner can request",
+				Responses: responses(EntitlementsResponse{},
+					http.StatusForbidden, http.StatusInternalServerError, http.StatusGatewayTimeout),
+			},
+			Handle:   h.Entitlements,
+			Entitled: true,
+			Audited:  true,
+		},
+		{
+			Route: openapi.Route{
+				OperationID: internal.UsageEndpoint,
+				Method:      http.MethodGet,
+				Path:        "/v2/usage",
+				Summary:     "Summarise the company data lookups of the calling partner by period",
+				Query:       &usageQueryParams{},
+				Responses: responses(UsageResponse{},
+					http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound, http.StatusInternalServerError, http.StatusGatewayTimeout),
+			},
+			Handle:   h.Usage,
+			Entitled: true,
+			Audited:  true,
+		},
+		{
+			Route: openapi.Route{
+				OperationID: internal.HealthEndpoint,
+				Method:      http.MethodGet,
+				Path:        "/health",
+				Summary:     "Report the status of the service and its dependencies",
+				Query:       &healthQueryParams{},
+				Responses: map[int]interface{}{
+					http.StatusOK:                 health.Report{},
+					http.StatusBadRequest:         nil,
+					http.StatusForbidden:          nil,
+					http.StatusNotFound:           nil,
+					http.StatusServiceUnavailable: health.Report{},
+				},
+			},
+			Handle: h.Health,
+		},
+		{
+			Route: openapi.Route{
+				OperationID: internal.OpenAPIEndpoint,
+				Method:      http.MethodGet,
+				Path:        "/openapi.json",
+				Summary:     "This OpenAPI document",
+				Responses:   responses(map[string]interface{}{}),
+			},
+			Handle: h.OpenAPI,
+		},
+	}
+}
+
+// GeospatialRoutes documents the routes of the Python function in api/,
+// scripts/check_openapi.py fails when the FastAPI app serves one they miss
+func GeospatialRoutes() []openapi.Route {
+	return []openapi.Route{
+		{
+			OperationID: internal.DataDiscovery,
+			Method:      http.MethodGet,
+			Path:        "/v1/discovery/layers",
+			Summary:     "List the geospatial layers the calling partner can intersect",
+			Responses: map[int]interface{}{
+				http.StatusOK:        DiscoveryResponse{},
+				http.StatusForbidden: nil,
+			},
+		},
+		{
+			OperationID: internal.IntersectsWithLatLon,
+			Method:      http.MethodGet,
+			Path:        "/v1/intersect/",
+			Summary:     "List the features of a layer a point falls in",
+			Query:       &intersectQueryParams{},
+			Responses: map[int]interface{}{
+				http.StatusOK:                  IntersectResponse{},
+				http.StatusForbidden:           nil,
+				http.StatusUnprocessableEntity: ValidationError{},
+			},
+		},
+	}
+}
+
+// Spec generates the OpenAPI document of routes and of the geospatial
+// function's ones
+func Spec(routes []Route) *openapi.Document {
+	docs := make([]openapi.Route, 0, len(routes))
+	for i := range routes {
+		docs = append(docs, routes[i].Route)
+	}
+	docs = append(docs, GeospatialRoutes()...)
+	info := openapi.Info{
+		Title:       internal.ServiceName,
+		Description: "Company data and geospatial lookups for partners. The errors are RFC 7807 problem details, except the /v1 geospatial routes reject invalid parameters with FastAPI's validation errors.",
+		Version:     APIVersion,
+	}
+	return openapi.Generate(info, Problem{}, docs)
+}
+
+// OpenAPI serves the OpenAPI document of the handler's routes
+func (h *Handler) OpenAPI(r *http.Request) (int, interface{}, error) {
+	return http.StatusOK, Spec(h.Routes()), nil
+}
@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"flag"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgtype"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cytora/geospatial-lambda/internal/health"
+	"github.com/cytora/geospatial-lambda/internal/metering"
+	"github.com/cytora/geospatial-lambda/internal/storage"
+	"github.com/cytora/geospatial-lambda/internal/storage/mock"
+	"github.com/cytora/go-platform-utils/common"
+)
+
+var update = flag.Bool("update", false, "rewrite docs/openapi.json from the routes")
+
+var specPath = filepath.Join("..", "..", "docs", "openapi.json")
+
+// TestSpec fails when the committed OpenAPI document isn't the one the
+// routes and their types generate
+func TestSpec(t *testing.T) {
+	got, err := Spec(New(&mock.StorageMock{}).Routes()).JSON()
+	if !assert.NoError(t, err) {
+		return
+	}
+	if *update {
+		assert.NoError(t, ioutil.WriteFile(specPath, got, 0644))
+	}
+	want, err := ioutil.ReadFile(specPath)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, string(want), string(got), "docs/openapi.json is out of date, run go test ./internal/handler -run TestSpec -update")
+}
+
+// TestHandler_Routes_documented fails when a handler returns a status or a
+// body its route doesn't document
+func TestHandler_Routes_documented(t *testing.T) {
+	stg := &mock.StorageMock{
+		Results:         &storage.Data{CRN: pgtype.Text{String: "00111222", Status: pgtype.Present}},
+		VersionsResults: []storage.Version{{ValidFrom: pgtype.Timestamptz{Time: time.Now(), Status: pgtype.Present}}},
+		ChangesResults:  []storage.Change{},
+	}
+	h := New(stg,
+		WithUsageSummarizer(metering.NewMemory()),
+		WithHealth(health.New(&healthCheckerMock{}, "v1.2.3", nil, 0)),
+	)
+	queries := map[string]string{
+		"CompanyChanges": "since=2021-01-01T00:00:00Z",
+		"Usage":          "from=2021-01-01T00:00:00Z",
+	}
+	ids := make(map[string]bool)
+	for _, rt := range h.Routes() {
+		rt := rt
+		t.Run(rt.OperationID, func(t *testing.T) {
+			assert.False(t, ids[rt.OperationID], "duplicate operation id")
+			ids[rt.OperationID] = true
+			if !assert.NotNil(t, rt.Handle, "expected a handler") {
+				return
+			}
+			var status int
+			var payload interface{}
+			router := mux.NewRouter()
+			router.HandleFunc(rt.Path, func(w http.ResponseWriter, r *http.Request) {
+				status, payload, _ = rt.Handle(r)
+			}).Methods(rt.Method)
+
+			target := strings.ReplaceAll(rt.Path, "{crn}", "00111222")
+			if q, ok := queries[rt.OperationID]; ok {
+				target += "?" + q
+			}
+			req := httptest.NewRequest(rt.Method, target, nil)
+			req = req.WithContext(common.SetAuthData(req.Context(), &common.AuthData{PartnerID: "acme"}))
+			router.ServeHTTP(httptest.NewRecorder(), req)
+
+			documented, ok := rt.Responses[status]
+			if !assert.True(t, ok, "status %d isn't documented", status) {
+				return
+			}
+			if documented == nil {
+				assert.IsType(t, &Problem{}, payload, "expected a problem")
+				return
+			}
+			want := reflect.TypeOf(documented)
+			if want.Kind() != reflect.Struct {
+				return
+			}
+			got := reflect.TypeOf(payload)
+			if got != nil && got.Kind() == reflect.Ptr {
+				got = got.Elem()
+			}
+			assert.Equal(t, want, got, "the body isn't the one documented")
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/cytora/geospatial-lambda/internal/tracing"
+	"github.com/cytora/go-platform-utils/logging"
+)
+
+// SpanFlusher exports the spans ended so far, e.g. the tracer provider
+type SpanFlusher interface {
+	ForceFlush(ctx context.Context) error
+}
+
+// Trace starts the server span of the requests to endpoint, joining the
+// trace of the caller when the request carries its context. The spans are
+// flushed once the request is handled, Lambda freezes the process between
+// invocations and may end it without the provider being shut down.
+func (h *Handler) Trace(endpoint string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := tracing.Propagator.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := tracing.Start(ctx, endpoint, trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(
+			semconv.HTTPMethodKey.String(r.Method),
+			semconv.HTTPTargetKey.String(r.URL.Path),
+		))
+		defer func() {
+			span.End()
+			h.flushSpans(ctx)
+		}()
+		query := r.URL.Query()
+		if groups := query.Get("groups"); groups != "" {
+			span.SetAttributes(attribute.String("geospatial.groups", groups))
+		}
+		if layer := query.Get("layer"); layer != "" {
+			span.SetAttributes(attribute.String("geospatial.layer", layer))
+		}
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next(rec, r.WithContext(ctx))
+		if partner := partnerID(ctx); partner != "" {
+			span.SetAttributes(semconv.EnduserIDKey.String(partner))
+		}
+		span.SetAttributes(semconv.HTTPStatusCodeKey.Int(rec.status))
+		if rec.status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(rec.status))
+		}
+	}
+}
+
+// flushSpans exports the spans of the request. It runs before the handler
+// returns, so before the response is handed back to Lambda, and delays it:
+// the export is bound by the span flush timeout rather than the request's
+// deadline, which may have passed already, and a slow collector loses spans
+// instead of holding the response.
+func (h *Handler) flushSpans(ctx context.Context) {
+	if h.opts.spanFlusher == nil {
+		return
+	}
+	flushCtx, cancel := context.WithTimeout(context.Background(), h.opts.spanFlushTimeout)
+	defer cancel()
+	if err := h.opts.spanFlusher.ForceFlush(flushCtx); err != nil {
+		logging.Error(ctx, err, nil, "failed to flush spans")
+	}
+}
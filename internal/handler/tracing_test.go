@@ -0,0 +1,133 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+
+	"github.com/cytora/geospatial-lambda/internal/storage"
+	"github.com/cytora/geospatial-lambda/internal/storage/mock"
+	"github.com/cytora/go-platform-utils/server"
+)
+
+func TestHandler_Trace(t *testing.T) {
+	tests := []struct {
+		name           string
+		stgErr         error
+		expectedStatus codes.Code
+	}{
+		{
+			name:           "success",
+			expectedStatus: codes.Unset,
+		},
+		{
+			name:           "not found",
+			stgErr:         storage.ErrNotFound,
+			expectedStatus: codes.Unset,
+		},
+		{
+			name:           "internal",
+			stgErr:         storage.ErrStorage,
+			expectedStatus: codes.Error,
+		},
+	}
+	for i := range tests {
+		tt := tests[i]
+		t.Run(tt.name, func(t *testing.T) {
+			rec := tracetest.NewSpanRecorder()
+			otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec)))
+			stg := &mock.StorageMock{VersionsResults: []storage.Version{}, Err: tt.stgErr}
+			h := New(stg)
+			router := mux.NewRouter()
+			router.HandleFunc("/v2/company/{crn}/versions", h.Trace("CompanyVersions", server.ToHTTPHandlerFunc(h.Versions)))
+
+			req := httptest.NewRequest(http.MethodGet, "/v2/company/00111222/versions", nil)
+			req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			spans := rec.Ended()
+			if assert.Len(t, spans, 1, "expected a span per request") {
+				span := spans[0]
+				assert.Equal(t, "CompanyVersions", span.Name(), "unexpected span name")
+				assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.SpanContext().TraceID().String(), "expected the caller's trace to be joined")
+				assert.Equal(t, "00f067aa0ba902b7", span.Parent().SpanID().String(), "expected the caller's span as parent")
+				assert.Contains(t, span.Attributes(), semconv.HTTPStatusCodeKey.Int(rr.Code), "expected the status code")
+				assert.Equal(t, tt.expectedStatus, span.Status().Code, "unexpected span status")
+			}
+		})
+	}
+}
+
+// spanFlusher records how many spans had ended when it was flushed
+type spanFlusher struct {
+	rec   *tracetest.SpanRecorder
+	ended []int
+}
+
+func (f *spanFlusher) ForceFlush(ctx context.Context) error {
+	f.ended = append(f.ended, len(f.rec.Ended()))
+	return nil
+}
+
+func TestHandler_Trace_flush(t *testing.T) {
+	rec := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec)))
+	flusher := &spanFlusher{rec: rec}
+	stg := &mock.StorageMock{VersionsResults: []storage.Version{}}
+	h := New(stg, WithSpanFlusher(flusher, time.Second))
+	router := mux.NewRouter()
+	router.HandleFunc("/v2/company/{crn}/versions", h.Trace("CompanyVersions", server.ToHTTPHandlerFunc(h.Versions)))
+
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/v2/company/00111222/versions", nil)
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	assert.Equal(t, []int{1, 2}, flusher.ended, "expected the spans to be flushed once each request's span ended")
+}
+
+// slowFlusher stands for a collector that doesn't answer, it waits for the
+// flush to time out
+type slowFlusher struct {
+	timeout time.Duration
+}
+
+func (f *slowFlusher) ForceFlush(ctx context.Context) error {
+	if deadline, ok := ctx.Deadline(); ok {
+		f.timeout = time.Until(deadline)
+	}
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestHandler_Trace_slowFlush(t *testing.T) {
+	flusher := &slowFlusher{}
+	stg := &mock.StorageMock{VersionsResults: []storage.Version{}}
+	h := New(stg, WithSpanFlusher(flusher, 20*time.Millisecond))
+	router := mux.NewRouter()
+	router.HandleFunc("/v2/company/{crn}/versions", h.Trace("CompanyVersions", server.ToHTTPHandlerFunc(h.Versions)))
+
+	req := httptest.NewRequest(http.MethodGet, "/v2/company/00111222/versions", nil)
+	// the request's deadline passed, the flush gets its own timeout anyway
+	ctx, cancel := context.WithTimeout(req.Context(), time.Hour)
+	cancel()
+	rr := httptest.NewRecorder()
+	ts := time.Now()
+	router.ServeHTTP(rr, req.WithContext(ctx))
+	elapsed := time.Since(ts)
+
+	assert.LessOrEqual(t, flusher.timeout, 20*time.Millisecond, "expected the flush bound by the flush timeout")
+	assert.Greater(t, flusher.timeout, time.Duration(0), "expected the flush not bound by the request's deadline")
+	assert.Less(t, elapsed, 500*time.Millisecond, "expected a slow flush not to hold the response longer than the timeout")
+}
@@ -0,0 +1,120 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/cytora/geospatial-lambda/internal/metering"
+	"github.com/cytora/go-platform-utils/logging"
+	"github.com/cytora/go-platform-utils/server"
+)
+
+type usageQueryParams struct {
+	From   string `schema:"from"`
+	To     string `schema:"to"`
+	Period string `schema:"period" validate:"omitempty,oneof=day week month"`
+}
+
+// Range returns the time range usage is summarised for, to defaults to now
+func (p *usageQueryParams) Range(now time.Time) (time.Time, time.Time, error) {
+	if p.From == "" {
+		return time.Time{}, time.Time{}, errors.New("from is required")
+	}
+	from, err := time.Parse(time.RFC3339, p.From)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	to := now
+	if p.To != "" {
+		if to, err = time.Parse(time.RFC3339, p.To); err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+	}
+	if !from.Before(to) {
+		return time.Time{}, time.Time{}, errors.New("from must be before to")
+	}
+	return from, to, nil
+}
+
+type Usage struct {
+	PeriodStart  string `json:"period_start"`
+	ResourceType string `json:"resource_type"`
+	Resource     string `json:"resource"`
+	Lookups      int    `json:"lookups"`
+}
+
+type UsageResponse struct {
+	PartnerID string  `json:"partner_id"`
+	Period    string  `json:"period"`
+	From      string  `json:"from"`
+	To        string  `json:"to"`
+	Usage     []Usage `json:"usage"`
+}
+
+// meter records the lookup of the resources by the calling partner
+func (h *Handler) meter(r *http.Request, endpoint, resourceType string, resources []string, lookups int) {
+	if h.opts.meter == nil {
+		return
+	}
+	h.opts.meter.Record(r.Context(), partnerID(r.Context()), endpoint, resourceType, resources, lookups)
+}
+
+// Usage summarises the lookups of the calling partner by period. Only the
+// company data lookups are metered, the geospatial lookups served by the
+// Python function in api/ aren't.
+func (h *Handler) Usage(r *http.Request) (int, interface{}, error) {
+	ctx, cancel := requestContext(r, h.opts.usageTimeout)
+	defer cancel()
+	if h.opts.usage == nil {
+		return problem(r, ErrNotFound, http.StatusNotFound)
+	}
+	partner := partnerID(ctx)
+	if partner == "" {
+		return problem(r, fmt.Errorf("%w missing partner", ErrForbidden), http.StatusForbidden)
+	}
+	req, err := server.Unmarshal(r, nil)
+	if err != nil {
+		logging.Error(ctx, err, nil, "invalid request")
+		return problem(r, ErrInvalidRequest, http.StatusBadRequest)
+	}
+	params := &usageQueryParams{}
+	if err := req.UnmarshalQueryParams(ctx, params, true); err != nil {
+		logging.Error(ctx, err, nil, "invalid query params")
+		return problem(r, ErrInvalidQueryParams, http.StatusBadRequest)
+	}
+	if err := h.validator.Struct(params); err != nil {
+		return problem(r, fmt.Errorf("%w %s", ErrInvalidQueryParams, err), http.StatusBadRequest, invalidParams(err)...)
+	}
+	from, to, err := params.Range(time.Now())
+	if err != nil {
+		return problem(r, fmt.Errorf("%w %s", ErrInvalidQueryParams, err), http.StatusBadRequest, invalidParams(err)...)
+	}
+	period := params.Period
+	if period == "" {
+		period = metering.PeriodDay
+	}
+	summaries, err := h.opts.usage.Summary(ctx, partner, from, to, period)
+	if err != nil {
+		logging.Error(ctx, err, logging.Data{"partner_id": partner}, "error retrieving partner's usage")
+		return internalError(r, err)
+	}
+	payload := &UsageResponse{
+		PartnerID: partner,
+		Period:    period,
+		From:      from.UTC().Format(time.RFC3339),
+		To:        to.UTC().Format(time.RFC3339),
+		Usage:     make([]Usage, 0, len(summaries)),
+	}
+	for i := range summaries {
+		s := summaries[i]
+		payload.Usage = append(payload.Usage, Usage{
+			PeriodStart:  s.PeriodStart.UTC().Format(time.RFC3339),
+			ResourceType: s.ResourceType,
+			Resource:     s.Resource,
+			Lookups:      s.Lookups,
+		})
+	}
+	return http.StatusOK, payload, nil
+}
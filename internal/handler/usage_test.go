@@ -0,0 +1,188 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgtype"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cytora/geospatial-lambda/internal/metering"
+	"github.com/cytora/geospatial-lambda/internal/storage"
+	"github.com/cytora/geospatial-lambda/internal/storage/mock"
+	"github.com/cytora/go-platform-utils/common"
+	"github.com/cytora/go-platform-utils/server"
+)
+
+func TestHandler_Retrieve_metering(t *testing.T) {
+	sink := metering.NewMemory()
+	stg := &mock.StorageMock{
+		Results: &storage.Data{
+			CRN: pgtype.Text{String: "00111222", Status: pgtype.Present},
+		},
+	}
+	h := New(stg, WithMeter(metering.New(sink, time.Second)))
+	router := mux.NewRouter()
+	router.HandleFunc("/v2/company/{crn}", server.ToHTTPHandlerFunc(h.Retrieve))
+
+	for _, crn := range []string{"00111222", "XX"} {
+		req := httptest.NewRequest(http.MethodGet, "/v2/company/"+crn+"?groups=base,dnb,DNB", nil)
+		req = req.WithContext(common.SetAuthData(req.Context(), &common.AuthData{PartnerID: "acme"}))
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	assert.Equal(t, []string{"dnb"}, stg.CalledWithGroups, "expected every group to be queried once")
+	events := sink.Events()
+	if assert.Len(t, events, 2, "expected only the successful lookup to be metered, once per group") {
+		assert.Equal(t, "acme", events[0].PartnerID)
+		assert.Equal(t, "CompanyData", events[0].Endpoint)
+		assert.Equal(t, "base", events[0].Resource)
+		assert.Equal(t, "dnb", events[1].Resource)
+		assert.Equal(t, 1, events[1].Lookups)
+	}
+}
+
+func TestHandler_Usage(t *testing.T) {
+	day := time.Date(2021, 3, 10, 0, 0, 0, 0, time.UTC)
+	sink := metering.NewMemory()
+	err := sink.Record(context.Background(), []metering.Event{
+		{PartnerID: "acme", ResourceType: metering.ResourceGroup, Resource: "dnb", Lookups: 2, OccurredAt: day.Add(time.Hour)},
+		{PartnerID: "initech", ResourceType: metering.ResourceGroup, Resource: "dnb", Lookups: 1, OccurredAt: day.Add(time.Hour)},
+	})
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		auth    *common.AuthData
+		params  map[string]string
+		noUsage bool
+
+		expectedStatus  int
+		expectedResults *UsageResponse
+	}{
+		{
+			name:           "usage",
+			auth:           &common.AuthData{PartnerID: "acme"},
+			params:         map[string]string{"from": "2021-03-01T00:00:00Z", "to": "2021-04-01T00:00:00Z"},
+			expectedStatus: http.StatusOK,
+			expectedResults: &UsageResponse{
+				PartnerID: "acme",
+				Period:    "day",
+				From:      "2021-03-01T00:00:00Z",
+				To:        "2021-04-01T00:00:00Z",
+				Usage: []Usage{
+					{PeriodStart: "2021-03-10T00:00:00Z", ResourceType: "group", Resource: "dnb", Lookups: 2},
+				},
+			},
+		},
+		{
+			name:           "by month",
+			auth:           &common.AuthData{PartnerID: "acme"},
+			params:         map[string]string{"from": "2021-03-01T00:00:00Z", "to": "2021-04-01T00:00:00Z", "period": "month"},
+			expectedStatus: http.StatusOK,
+			expectedResults: &UsageResponse{
+				PartnerID: "acme",
+				Period:    "month",
+				From:      "2021-03-01T00:00:00Z",
+				To:        "2021-04-01T00:00:00Z",
+				Usage: []Usage{
+					{PeriodStart: "2021-03-01T00:00:00Z", ResourceType: "group", Resource: "dnb", Lookups: 2},
+				},
+			},
+		},
+		{
+			name:           "missing from",
+			auth:           &common.AuthData{PartnerID: "acme"},
+			params:         map[string]string{},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "invalid range",
+			auth:           &common.AuthData{PartnerID: "acme"},
+			params:         map[string]string{"from": "2021-04-01T00:00:00Z", "to": "2021-03-01T00:00:00Z"},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "invalid period",
+			auth:           &common.AuthData{PartnerID: "acme"},
+			params:         map[string]string{"from": "2021-03-01T00:00:00Z", "period": "year"},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "missing partner",
+			auth:           &common.AuthData{},
+			params:         map[string]string{"from": "2021-03-01T00:00:00Z"},
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "not summarised",
+			auth:           &common.AuthData{PartnerID: "acme"},
+			params:         map[string]string{"from": "2021-03-01T00:00:00Z"},
+			noUsage:        true,
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for i := range tests {
+		tt := tests[i]
+		t.Run(tt.name, func(t *testing.T) {
+			var opts []OptionFunc
+			if !tt.noUsage {
+				opts = append(opts, WithUsageSummarizer(sink))
+			}
+			h := New(&mock.StorageMock{}, opts...)
+			router := mux.NewRouter()
+			router.HandleFunc("/v2/usage", server.ToHTTPHandlerFunc(h.Usage))
+			values := url.Values{}
+			for key, val := range tt.params {
+				values.Add(key, val)
+			}
+			req := httptest.NewRequest(http.MethodGet, "/v2/usage?"+values.Encode(), nil)
+			req = req.WithContext(common.SetAuthData(req.Context(), tt.auth))
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+			assert.Equal(t, tt.expectedStatus, rr.Code, "unexpected status code")
+			if tt.expectedStatus == http.StatusOK {
+				data, err := ioutil.ReadAll(rr.Body)
+				assert.Nil(t, err, "unexpected error reading response payload")
+				resp := &UsageResponse{}
+				err = json.Unmarshal(data, resp)
+				assert.Nil(t, err, "unexpected error unmarshaling json data")
+				assert.Equal(t, tt.expectedResults, resp, "unexpected results")
+			}
+		})
+	}
+}
+
+// blockingSummarizer waits for the request's deadline
+type blockingSummarizer struct{}
+
+func (blockingSummarizer) Summary(ctx context.Context, partnerID string, from, to time.Time, period string) ([]metering.Summary, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestHandler_Usage_timeout(t *testing.T) {
+	h := New(&mock.StorageMock{}, WithUsageSummarizer(blockingSummarizer{}), WithUsageTimeout(10*time.Millisecond))
+	req := httptest.NewRequest(http.MethodGet, "/v2/usage?from=2021-03-01T00:00:00Z", nil)
+	req = req.WithContext(common.SetAuthData(req.Context(), &common.AuthData{PartnerID: "acme"}))
+	rr := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		server.ToHTTPHandlerFunc(h.Usage)(rr, req)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("usage not bound by its timeout")
+	}
+	assert.Equal(t, http.StatusGatewayTimeout, rr.Code, "unexpected status code")
+}
@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/cytora/geospatial-lambda/internal/storage"
+	"github.com/cytora/go-platform-utils/logging"
+	"github.com/cytora/go-platform-utils/server"
+	"github.com/jackc/pgtype"
+)
+
+type Version struct {
+	ValidFrom string `json:"valid_from"`
+	ValidTo   string `json:"valid_to,omitempty"`
+}
+
+type VersionsResponse struct {
+	CRN      string    `json:"crn"`
+	Versions []Version `json:"versions"`
+}
+
+func formatTimestamp(t pgtype.Timestamptz) string {
+	if t.Status != pgtype.Present {
+		return ""
+	}
+	return t.Time.UTC().Format(time.RFC3339)
+}
+
+func loadVersion(validFrom, validTo pgtype.Timestamptz) *Version {
+	return &Version{
+		ValidFrom: formatTimestamp(validFrom),
+		ValidTo:   formatTimestamp(validTo),
+	}
+}
+
+// Versions lists the snapshots available for a company, newest first
+func (h *Handler) Versions(r *http.Request) (int, interface{}, error) {
+	ctx, cancel := requestContext(r, h.opts.versionsTimeout)
+	defer cancel()
+	req, err := server.Unmarshal(r, nil)
+	if err != nil {
+		logging.Error(ctx, err, nil, "invalid request")
+		return problem(r, ErrInvalidRequest, http.StatusBadRequest)
+	}
+	crn, err := parseCRN(req.PathParams["crn"])
+	if err != nil {
+		return problem(r, err, http.StatusBadRequest)
+	}
+	versions, err := h.storage.CompanyVersions(ctx, crn)
+	if err != nil {
+		logging.Error(ctx, err, logging.Data{"crn": crn}, "error retrieving company's versions")
+		switch err {
+		case storage.ErrNotFound:
+			return problem(r, ErrNotFound, http.StatusNotFound)
+		default:
+			return internalError(r, err)
+		}
+	}
+	payload := &VersionsResponse{
+		CRN:      crn,
+		Versions: make([]Version, 0, len(versions)),
+	}
+	for i := range versions {
+		v := versions[i]
+		payload.Versions = append(payload.Versions, *loadVersion(v.ValidFrom, v.ValidTo))
+	}
+	return http.StatusOK, payload, nil
+}
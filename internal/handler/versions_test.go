@@ -0,0 +1,107 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgtype"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cytora/geospatial-lambda/internal/storage"
+	"github.com/cytora/geospatial-lambda/internal/storage/mock"
+	"github.com/cytora/go-platform-utils/common"
+	"github.com/cytora/go-platform-utils/server"
+)
+
+func TestHandler_Versions(t *testing.T) {
+	tests := []struct {
+		name string
+		crn  string
+
+		stgErr     error
+		stgResults []storage.Version
+
+		expectedStatus  int
+		expectedResults *VersionsResponse
+	}{
+		{
+			name: "versions",
+			crn:  "00111222",
+			stgResults: []storage.Version{
+				{
+					ValidFrom: pgtype.Timestamptz{Time: time.Date(2021, 2, 1, 0, 0, 0, 0, time.UTC), Status: pgtype.Present},
+					ValidTo:   pgtype.Timestamptz{Status: pgtype.Null},
+				},
+				{
+					ValidFrom: pgtype.Timestamptz{Time: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC), Status: pgtype.Present},
+					ValidTo:   pgtype.Timestamptz{Time: time.Date(2021, 2, 1, 0, 0, 0, 0, time.UTC), Status: pgtype.Present},
+				},
+			},
+			expectedStatus: http.StatusOK,
+			expectedResults: &VersionsResponse{
+				CRN: "00111222",
+				Versions: []Version{
+					{ValidFrom: "2021-02-01T00:00:00Z"},
+					{ValidFrom: "2021-01-01T00:00:00Z", ValidTo: "2021-02-01T00:00:00Z"},
+				},
+			},
+		},
+		{
+			name:           "invalid crn",
+			crn:            "XX0001112",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "not found",
+			crn:            "00111222",
+			stgErr:         storage.ErrNotFound,
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "storage error",
+			crn:            "00111222",
+			stgErr:         errors.New("oops"),
+			expectedStatus: http.StatusInternalServerError,
+		},
+		{
+			name:           "timeout",
+			crn:            "00111222",
+			stgErr:         storage.ErrTimeout,
+			expectedStatus: http.StatusGatewayTimeout,
+		},
+	}
+
+	for i := range tests {
+		tt := tests[i]
+		t.Run(tt.name, func(t *testing.T) {
+			stg := &mock.StorageMock{
+				Err:             tt.stgErr,
+				VersionsResults: tt.stgResults,
+			}
+			h := New(stg)
+			router := mux.NewRouter()
+			router.HandleFunc("/v2/company/{crn}/versions", server.ToHTTPHandlerFunc(h.Versions))
+			endpoint := fmt.Sprintf("/v2/company/%s/versions", tt.crn)
+			req := httptest.NewRequest(http.MethodGet, endpoint, nil)
+			req = req.WithContext(common.SetAuthData(req.Context(), &common.AuthData{PartnerID: "test"}))
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+			assert.Equal(t, tt.expectedStatus, rr.Code, "unexpected status code")
+			if tt.expectedStatus == http.StatusOK {
+				data, err := ioutil.ReadAll(rr.Body)
+				assert.Nil(t, err, "unexpected error reading response payload")
+				resp := &VersionsResponse{}
+				err = json.Unmarshal(data, resp)
+				assert.Nil(t, err, "unexpected error unmarshaling json data")
+				assert.Equal(t, tt.expectedResults, resp, "unexpected results")
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package handler
+
+import "fmt"
+
+// Codes of the warnings returned along with the data
+const (
+	// a stored value could not be parsed, it's left out of the response
+	WarningUnparseableField = "unparseable_field"
+	// the SIC code of the primary trade is not part of UK SIC 2007
+	WarningUnknownSICCode = "unknown_sic_code"
+	// the data a field is enriched with could not be retrieved, the field is
+	// returned as stored
+	WarningEnrichmentUnavailable = "enrichment_unavailable"
+	// a requested group is not known and has been ignored
+	WarningUnknownGroup = "unknown_group"
+	// a requested group has no data for the company
+	WarningEmptyGroup = "empty_group"
+	// a group's data is older than the max_age requested
+	WarningStaleData = "stale_data"
+)
+
+// Warning reports a data quality problem found while building a response.
+// Field is the name of the response's field or group affected.
+type Warning struct {
+	Code    string `json:"code"`
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+func unknownGroupWarning(group string) Warning {
+	return Warning{
+		Code:    WarningUnknownGroup,
+		Field:   group,
+		Message: fmt.Sprintf("group %q is not known and has been ignored", group),
+	}
+}
+
+func staleDataWarning(group string, maxAge int) Warning {
+	return Warning{
+		Code:    WarningStaleData,
+		Field:   group,
+		Message: fmt.Sprintf("%s data is older than %d seconds", group, maxAge),
+	}
+}
+
+func emptyGroupWarning(group string) Warning {
+	return Warning{
+		Code:    WarningEmptyGroup,
+		Field:   group,
+		Message: fmt.Sprintf("no %s data available for the company", group),
+	}
+}
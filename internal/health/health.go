@@ -0,0 +1,212 @@
+// Package health reports whether the service can serve requests: the
+// shallow check only pings the database, the deep one diagnoses PostGIS
+// and the layers the service relies on
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/cytora/geospatial-lambda/internal/storage"
+)
+
+// statuses of the service and of its dependencies, a degraded service
+// serves some requests only
+const (
+	StatusOK          = "ok"
+	StatusDegraded    = "degraded"
+	StatusUnavailable = "unavailable"
+)
+
+// modes of the checks
+const (
+	ModeShallow = "shallow"
+	ModeDeep    = "deep"
+)
+
+// Checker runs the queries of the checks on the database
+type Checker interface {
+	Ping(ctx context.Context) error
+	PostGISVersion(ctx context.Context) (string, error)
+	Layers(ctx context.Context) ([]storage.Layer, error)
+}
+
+type Database struct {
+	Status         string  `json:"status"`
+	LatencyMS      float64 `json:"latency_ms"`
+	PostGISVersion string  `json:"postgis_version,omitempty"`
+	Error          string  `json:"error,omitempty"`
+}
+
+// Layer is the availability of a layer, a layer without a spatial index is
+// available but degraded as its lookups scan the whole table
+type Layer struct {
+	Name      string `json:"name"`
+	Status    string `json:"status"`
+	Available bool   `json:"available"`
+	Indexed   bool   `json:"indexed"`
+	Type      string `json:"type,omitempty"`
+	SRID      int    `json:"srid,omitempty"`
+}
+
+type Report struct {
+	Status   string    `json:"status"`
+	Mode     string    `json:"mode"`
+	Version  string    `json:"version"`
+	Database *Database `json:"database"`
+	Layers   []Layer   `json:"layers,omitempty"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// Health checks the database, layers lists the layers that must be
+// registered, every layer found is reported when it's empty. The shallow
+// report is reused for shallowTTL as load balancers probe every few seconds
+// from each of their nodes.
+type Health struct {
+	checker    Checker
+	version    string
+	layers     []string
+	shallowTTL time.Duration
+	now        func() time.Time
+
+	mu             sync.Mutex
+	shallow        *Report
+	shallowExpires time.Time
+}
+
+func New(checker Checker, version string, layers []string, shallowTTL time.Duration) *Health {
+	return &Health{
+		checker:    checker,
+		version:    version,
+		layers:     layers,
+		shallowTTL: shallowTTL,
+		now:        time.Now,
+	}
+}
+
+// Check runs the checks of mode, the report's status is the worst status
+// of the checks
+func (h *Health) Check(ctx context.Context, mode string) *Report {
+	if mode == ModeDeep || h.shallowTTL <= 0 {
+		return h.check(ctx, mode)
+	}
+	h.mu.Lock()
+	cached, expires := h.shallow, h.shallowExpires
+	h.mu.Unlock()
+	if cached != nil && h.now().Before(expires) {
+		return cached.clone()
+	}
+	report := h.check(ctx, mode)
+	h.mu.Lock()
+	h.shallow, h.shallowExpires = report.clone(), h.now().Add(h.shallowTTL)
+	h.mu.Unlock()
+	return report
+}
+
+func (h *Health) check(ctx context.Context, mode string) *Report {
+	report := &Report{
+		Status:   StatusOK,
+		Mode:     mode,
+		Version:  h.version,
+		Database: &Database{Status: StatusOK},
+	}
+	ts := time.Now()
+	err := h.checker.Ping(ctx)
+	report.Database.LatencyMS = float64(time.Since(ts)) / float64(time.Millisecond)
+	if err != nil {
+		report.Status = StatusUnavailable
+		report.Database.Status = StatusUnavailable
+		report.Database.Error = err.Error()
+		return report
+	}
+	if mode != ModeDeep {
+		return report
+	}
+	version, err := h.checker.PostGISVersion(ctx)
+	if err != nil {
+		report.Database.Status = StatusDegraded
+		report.Database.Error = err.Error()
+		report.degrade()
+	}
+	report.Database.PostGISVersion = version
+	layers, err := h.checker.Layers(ctx)
+	if err != nil {
+		report.Error = err.Error()
+		report.degrade()
+		return report
+	}
+	report.Layers = h.checkLayers(layers)
+	for i := range report.Layers {
+		if report.Layers[i].Status != StatusOK {
+			report.degrade()
+		}
+	}
+	return report
+}
+
+// clone copies a shallow report, which has no layers
+func (r *Report) clone() *Report {
+	report := *r
+	database := *r.Database
+	report.Database = &database
+	return &report
+}
+
+func (r *Report) degrade() {
+	if r.Status == StatusOK {
+		r.Status = StatusDegraded
+	}
+}
+
+// checkLayers reports the layers expected, or the layers found when none
+// is expected. Layers are named after their table, qualified by the schema
+// outside of public.
+func (h *Health) checkLayers(found []storage.Layer) []Layer {
+	byName := make(map[string]Layer, len(found))
+	names := make([]string, 0, len(found))
+	for i := range found {
+		l := found[i]
+		name := layerName(l.Schema.String, l.Table.String)
+		layer := Layer{
+			Name:      name,
+			Available: true,
+			Indexed:   l.Indexed.Bool,
+			Type:      l.Type.String,
+			SRID:      int(l.SRID.Int),
+		}
+		layer.Status = StatusOK
+		if !layer.Indexed {
+			layer.Status = StatusDegraded
+		}
+		// tables with several geometry columns are available when one is
+		// indexed
+		if existing, ok := byName[name]; ok {
+			if existing.Indexed {
+				continue
+			}
+		} else {
+			names = append(names, name)
+		}
+		byName[name] = layer
+	}
+	if len(h.layers) > 0 {
+		names = h.layers
+	}
+	layers := make([]Layer, 0, len(names))
+	for _, name := range names {
+		layer, ok := byName[name]
+		if !ok {
+			layer = Layer{Name: name, Status: StatusUnavailable}
+		}
+		layers = append(layers, layer)
+	}
+	return layers
+}
+
+func layerName(schema, table string) string {
+	if schema == "" || schema == "public" {
+		return table
+	}
+	return schema + "." + table
+}
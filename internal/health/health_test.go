@@ -0,0 +1,163 @@
+package health
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgtype"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cytora/geospatial-lambda/internal/storage"
+)
+
+type checkerMock struct {
+	pingErr    error
+	version    string
+	versionErr error
+	layers     []storage.Layer
+	layersErr  error
+	calls      int
+}
+
+func (c *checkerMock) Ping(ctx context.Context) error {
+	c.calls++
+	return c.pingErr
+}
+
+func (c *checkerMock) PostGISVersion(ctx context.Context) (string, error) {
+	c.calls++
+	return c.version, c.versionErr
+}
+
+func (c *checkerMock) Layers(ctx context.Context) ([]storage.Layer, error) {
+	c.calls++
+	return c.layers, c.layersErr
+}
+
+func layer(schema, table string, indexed bool) storage.Layer {
+	return storage.Layer{
+		Schema:  pgtype.Text{String: schema, Status: pgtype.Present},
+		Table:   pgtype.Text{String: table, Status: pgtype.Present},
+		Column:  pgtype.Text{String: "geom", Status: pgtype.Present},
+		Type:    pgtype.Text{String: "MULTIPOLYGON", Status: pgtype.Present},
+		SRID:    pgtype.Int4{Int: 4326, Status: pgtype.Present},
+		Indexed: pgtype.Bool{Bool: indexed, Status: pgtype.Present},
+	}
+}
+
+func TestHealth_Check(t *testing.T) {
+	tests := []struct {
+		name           string
+		checker        *checkerMock
+		layers         []string
+		mode           string
+		expectedStatus string
+		expectedCalls  int
+		expectedLayers map[string]string
+	}{
+		{
+			name:           "shallow",
+			checker:        &checkerMock{},
+			mode:           ModeShallow,
+			expectedStatus: StatusOK,
+			expectedCalls:  1,
+		},
+		{
+			name:           "shallow unreachable",
+			checker:        &checkerMock{pingErr: storage.ErrStorage},
+			mode:           ModeShallow,
+			expectedStatus: StatusUnavailable,
+			expectedCalls:  1,
+		},
+		{
+			name:           "deep unreachable",
+			checker:        &checkerMock{pingErr: storage.ErrTimeout},
+			mode:           ModeDeep,
+			expectedStatus: StatusUnavailable,
+			expectedCalls:  1,
+		},
+		{
+			name: "deep every layer found",
+			checker: &checkerMock{
+				version: "3.1.4",
+				layers:  []storage.Layer{layer("public", "flood_zones", true), layer("os", "buildings", true)},
+			},
+			mode:           ModeDeep,
+			expectedStatus: StatusOK,
+			expectedCalls:  3,
+			expectedLayers: map[string]string{"flood_zones": StatusOK, "os.buildings": StatusOK},
+		},
+		{
+			name: "deep layer not indexed",
+			checker: &checkerMock{
+				version: "3.1.4",
+				layers:  []storage.Layer{layer("public", "flood_zones", false)},
+			},
+			mode:           ModeDeep,
+			expectedStatus: StatusDegraded,
+			expectedCalls:  3,
+			expectedLayers: map[string]string{"flood_zones": StatusDegraded},
+		},
+		{
+			name: "deep registered layer missing",
+			checker: &checkerMock{
+				version: "3.1.4",
+				layers:  []storage.Layer{layer("public", "flood_zones", true), layer("public", "subsidence", true)},
+			},
+			layers:         []string{"flood_zones", "crime"},
+			mode:           ModeDeep,
+			expectedStatus: StatusDegraded,
+			expectedCalls:  3,
+			expectedLayers: map[string]string{"flood_zones": StatusOK, "crime": StatusUnavailable},
+		},
+		{
+			name: "deep postgis missing",
+			checker: &checkerMock{
+				versionErr: storage.ErrStorage,
+				layersErr:  storage.ErrStorage,
+			},
+			mode:           ModeDeep,
+			expectedStatus: StatusDegraded,
+			expectedCalls:  3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := New(tt.checker, "v1.2.3", tt.layers, 0)
+			report := h.Check(context.Background(), tt.mode)
+			assert.Equal(t, tt.expectedStatus, report.Status, "unexpected status")
+			assert.Equal(t, "v1.2.3", report.Version, "unexpected version")
+			assert.Equal(t, tt.expectedCalls, tt.checker.calls, "unexpected checks")
+			layers := make(map[string]string, len(report.Layers))
+			for _, l := range report.Layers {
+				layers[l.Name] = l.Status
+			}
+			if tt.expectedLayers == nil {
+				tt.expectedLayers = map[string]string{}
+			}
+			assert.Equal(t, tt.expectedLayers, layers, "unexpected layers")
+		})
+	}
+}
+
+func TestHealth_Check_shallowTTL(t *testing.T) {
+	now := time.Date(2021, 3, 10, 12, 0, 0, 0, time.UTC)
+	checker := &checkerMock{}
+	h := New(checker, "v1.2.3", nil, 5*time.Second)
+	h.now = func() time.Time { return now }
+
+	h.Check(context.Background(), ModeShallow)
+	report := h.Check(context.Background(), ModeShallow)
+	assert.Equal(t, StatusOK, report.Status)
+	assert.Equal(t, 1, checker.calls, "expected the shallow report to be reused")
+
+	h.Check(context.Background(), ModeDeep)
+	assert.Equal(t, 4, checker.calls, "expected the deep report not to be cached")
+
+	now = now.Add(5 * time.Second)
+	checker.pingErr = storage.ErrTimeout
+	report = h.Check(context.Background(), ModeShallow)
+	assert.Equal(t, StatusUnavailable, report.Status, "expected the shallow report to expire")
+	assert.Equal(t, 5, checker.calls)
+}
@@ -0,0 +1,67 @@
+package metering
+
+import (
+	"context"
+	"time"
+
+	"github.com/cytora/geospatial-lambda/internal/storage"
+)
+
+// Store persists the usage events in the usage_events table and totals them
+type Store interface {
+	RecordUsage(ctx context.Context, events []storage.UsageEvent) error
+	UsageSummary(ctx context.Context, partnerID string, from, to time.Time, period string) ([]storage.UsageSummary, error)
+}
+
+// DB records the usage events in the database
+type DB struct {
+	store Store
+}
+
+var (
+	_ Sink       = (*DB)(nil)
+	_ Summarizer = (*DB)(nil)
+)
+
+func NewDB(store Store) *DB {
+	return &DB{
+		store: store,
+	}
+}
+
+func (d *DB) Record(ctx context.Context, events []Event) error {
+	rows := make([]storage.UsageEvent, 0, len(events))
+	for i := range events {
+		e := events[i]
+		rows = append(rows, storage.UsageEvent{
+			PartnerID:    e.PartnerID,
+			Endpoint:     e.Endpoint,
+			ResourceType: e.ResourceType,
+			Resource:     e.Resource,
+			Lookups:      e.Lookups,
+			OccurredAt:   e.OccurredAt,
+		})
+	}
+	return d.store.RecordUsage(ctx, rows)
+}
+
+func (d *DB) Summary(ctx context.Context, partnerID string, from, to time.Time, period string) ([]Summary, error) {
+	if !ValidPeriod(period) {
+		return nil, ErrInvalidPeriod
+	}
+	rows, err := d.store.UsageSummary(ctx, partnerID, from, to, period)
+	if err != nil {
+		return nil, err
+	}
+	summaries := make([]Summary, 0, len(rows))
+	for i := range rows {
+		r := rows[i]
+		summaries = append(summaries, Summary{
+			PeriodStart:  r.PeriodStart.Time.UTC(),
+			ResourceType: r.ResourceType.String,
+			Resource:     r.Resource.String,
+			Lookups:      int(r.Lookups.Int),
+		})
+	}
+	return summaries, nil
+}
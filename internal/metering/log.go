@@ -0,0 +1,29 @@
+package metering
+
+import (
+	"context"
+
+	"github.com/cytora/go-platform-utils/logging"
+)
+
+// Log writes the usage events to the structured logs, where they can be
+// picked up by a subscription
+type Log struct{}
+
+var _ Sink = Log{}
+
+func (Log) Record(ctx context.Context, events []Event) error {
+	for i := range events {
+		e := events[i]
+		logging.Info(ctx, logging.Data{
+			"usage_event":   true,
+			"partner_id":    e.PartnerID,
+			"endpoint":      e.Endpoint,
+			"resource_type": e.ResourceType,
+			"resource":      e.Resource,
+			"lookups":       e.Lookups,
+			"occurred_at":   e.OccurredAt,
+		}, "usage")
+	}
+	return nil
+}
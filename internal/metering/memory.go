@@ -0,0 +1,79 @@
+package metering
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Memory keeps the usage events in process, it stands in for the other
+// sinks in local runs and tests
+type Memory struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+var (
+	_ Sink       = (*Memory)(nil)
+	_ Summarizer = (*Memory)(nil)
+)
+
+func NewMemory() *Memory {
+	return &Memory{}
+}
+
+func (m *Memory) Record(ctx context.Context, events []Event) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.events = append(m.events, events...)
+	return nil
+}
+
+// Events returns a copy of the events recorded
+func (m *Memory) Events() []Event {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Event(nil), m.events...)
+}
+
+func (m *Memory) Summary(ctx context.Context, partnerID string, from, to time.Time, period string) ([]Summary, error) {
+	if !ValidPeriod(period) {
+		return nil, ErrInvalidPeriod
+	}
+	type key struct {
+		start        time.Time
+		resourceType string
+		resource     string
+	}
+	totals := make(map[key]int)
+	m.mu.Lock()
+	for i := range m.events {
+		e := m.events[i]
+		if e.PartnerID != partnerID || e.OccurredAt.Before(from) || !e.OccurredAt.Before(to) {
+			continue
+		}
+		totals[key{truncate(e.OccurredAt, period), e.ResourceType, e.Resource}] += e.Lookups
+	}
+	m.mu.Unlock()
+	summaries := make([]Summary, 0, len(totals))
+	for k, lookups := range totals {
+		summaries = append(summaries, Summary{
+			PeriodStart:  k.start,
+			ResourceType: k.resourceType,
+			Resource:     k.resource,
+			Lookups:      lookups,
+		})
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		a, b := summaries[i], summaries[j]
+		if !a.PeriodStart.Equal(b.PeriodStart) {
+			return a.PeriodStart.Before(b.PeriodStart)
+		}
+		if a.ResourceType != b.ResourceType {
+			return a.ResourceType < b.ResourceType
+		}
+		return a.Resource < b.Resource
+	})
+	return summaries, nil
+}
@@ -0,0 +1,144 @@
+// Package metering records the lookups partners make, per group and layer,
+// so licensed data can be recharged. Only the groups are metered for now:
+// the layers are looked up through the Python function in api/, which
+// doesn't record its lookups.
+package metering
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cytora/go-platform-utils/logging"
+)
+
+// resource types metered
+const (
+	ResourceGroup = "group"
+	// ResourceLayer is reserved for the geospatial lookups, not metered yet
+	ResourceLayer = "layer"
+)
+
+// periods usage can be summarised by
+const (
+	PeriodDay   = "day"
+	PeriodWeek  = "week"
+	PeriodMonth = "month"
+)
+
+var (
+	ErrMetering      = errors.New("metering error")
+	ErrInvalidPeriod = fmt.Errorf("%w invalid period", ErrMetering)
+)
+
+// Event is the usage of a resource by a partner, Lookups is above 1 for
+// the items of batch requests
+type Event struct {
+	PartnerID    string    `json:"partner_id"`
+	Endpoint     string    `json:"endpoint"`
+	ResourceType string    `json:"resource_type"`
+	Resource     string    `json:"resource"`
+	Lookups      int       `json:"lookups"`
+	OccurredAt   time.Time `json:"occurred_at"`
+}
+
+// Sink stores or forwards usage events
+type Sink interface {
+	Record(ctx context.Context, events []Event) error
+}
+
+// Summary is the number of lookups of a resource by a partner in a period
+type Summary struct {
+	PeriodStart  time.Time
+	ResourceType string
+	Resource     string
+	Lookups      int
+}
+
+// Summarizer totals the usage of a partner between from, inclusive, and to,
+// exclusive, by period
+type Summarizer interface {
+	Summary(ctx context.Context, partnerID string, from, to time.Time, period string) ([]Summary, error)
+}
+
+// Meter turns successful lookups into usage events
+type Meter struct {
+	sink    Sink
+	timeout time.Duration
+	now     func() time.Time
+}
+
+// New returns a meter recording to sink, every record is given up to timeout
+// whatever the deadline of the request metered
+func New(sink Sink, timeout time.Duration) *Meter {
+	return &Meter{
+		sink:    sink,
+		timeout: timeout,
+		now:     time.Now,
+	}
+}
+
+// detachedContext keeps the values of the request, e.g. its logger, but not
+// its deadline or cancellation: the lookup was served so it must be billed
+// even if the caller went away or the request ran out of time
+type detachedContext struct {
+	context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (detachedContext) Err() error {
+	return nil
+}
+
+// Record meters a lookup of the given resources, failures are logged rather
+// than returned as the lookup was already served
+func (m *Meter) Record(ctx context.Context, partnerID, endpoint, resourceType string, resources []string, lookups int) {
+	if len(resources) == 0 || lookups <= 0 {
+		return
+	}
+	now := m.now().UTC()
+	events := make([]Event, 0, len(resources))
+	for i := range resources {
+		events = append(events, Event{
+			PartnerID:    partnerID,
+			Endpoint:     endpoint,
+			ResourceType: resourceType,
+			Resource:     resources[i],
+			Lookups:      lookups,
+			OccurredAt:   now,
+		})
+	}
+	ctx, cancel := context.WithTimeout(detachedContext{ctx}, m.timeout)
+	defer cancel()
+	if err := m.sink.Record(ctx, events); err != nil {
+		logging.Error(ctx, err, logging.Data{"partner_id": partnerID, "endpoint": endpoint, "resources": resources}, "failed to record usage")
+	}
+}
+
+// ValidPeriod tells whether usage can be summarised by period
+func ValidPeriod(period string) bool {
+	return period == PeriodDay || period == PeriodWeek || period == PeriodMonth
+}
+
+// truncate returns the start of the period t belongs to, weeks start on
+// Monday as in Postgres' date_trunc
+func truncate(t time.Time, period string) time.Time {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	switch period {
+	case PeriodWeek:
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	case PeriodMonth:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return day
+	}
+}
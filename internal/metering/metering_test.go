@@ -0,0 +1,113 @@
+package metering
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type failingSink struct{}
+
+func (failingSink) Record(ctx context.Context, events []Event) error {
+	return errors.New("oops")
+}
+
+func TestMeter_Record(t *testing.T) {
+	now := time.Date(2021, 3, 10, 12, 0, 0, 0, time.UTC)
+	sink := NewMemory()
+	m := New(sink, time.Second)
+	m.now = func() time.Time { return now }
+
+	m.Record(context.Background(), "acme", "CompanyData", ResourceGroup, []string{"base", "dnb"}, 1)
+	m.Record(context.Background(), "acme", "CompanyData", ResourceGroup, nil, 1)
+	m.Record(context.Background(), "acme", "CompanyData", ResourceGroup, []string{"base"}, 0)
+
+	assert.Equal(t, []Event{
+		{PartnerID: "acme", Endpoint: "CompanyData", ResourceType: ResourceGroup, Resource: "base", Lookups: 1, OccurredAt: now},
+		{PartnerID: "acme", Endpoint: "CompanyData", ResourceType: ResourceGroup, Resource: "dnb", Lookups: 1, OccurredAt: now},
+	}, sink.Events())
+
+	// failures don't reach the caller
+	New(failingSink{}, time.Second).Record(context.Background(), "acme", "CompanyData", ResourceGroup, []string{"base"}, 1)
+}
+
+type deadlineSink struct {
+	err      error
+	deadline time.Time
+}
+
+func (s *deadlineSink) Record(ctx context.Context, events []Event) error {
+	s.err = ctx.Err()
+	s.deadline, _ = ctx.Deadline()
+	return nil
+}
+
+func TestMeter_Record_detached(t *testing.T) {
+	sink := &deadlineSink{}
+	m := New(sink, time.Second)
+
+	// the request was cancelled once it was served
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	start := time.Now()
+	m.Record(ctx, "acme", "CompanyData", ResourceGroup, []string{"base"}, 1)
+
+	assert.NoError(t, sink.err)
+	assert.WithinDuration(t, start.Add(time.Second), sink.deadline, 100*time.Millisecond)
+}
+
+func Test_truncate(t *testing.T) {
+	// a Wednesday
+	ts := time.Date(2021, 3, 10, 12, 30, 0, 0, time.UTC)
+	tests := []struct {
+		period string
+		ts     time.Time
+		want   time.Time
+	}{
+		{period: PeriodDay, ts: ts, want: time.Date(2021, 3, 10, 0, 0, 0, 0, time.UTC)},
+		{period: PeriodWeek, ts: ts, want: time.Date(2021, 3, 8, 0, 0, 0, 0, time.UTC)},
+		{period: PeriodWeek, ts: time.Date(2021, 3, 14, 23, 0, 0, 0, time.UTC), want: time.Date(2021, 3, 8, 0, 0, 0, 0, time.UTC)},
+		{period: PeriodWeek, ts: time.Date(2021, 3, 8, 0, 0, 0, 0, time.UTC), want: time.Date(2021, 3, 8, 0, 0, 0, 0, time.UTC)},
+		{period: PeriodMonth, ts: ts, want: time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.period+" "+tt.ts.Format(time.RFC3339), func(t *testing.T) {
+			assert.Equal(t, tt.want, truncate(tt.ts, tt.period))
+		})
+	}
+}
+
+func TestMemory_Summary(t *testing.T) {
+	day := time.Date(2021, 3, 10, 0, 0, 0, 0, time.UTC)
+	m := NewMemory()
+	err := m.Record(context.Background(), []Event{
+		{PartnerID: "acme", ResourceType: ResourceGroup, Resource: "dnb", Lookups: 1, OccurredAt: day.Add(time.Hour)},
+		{PartnerID: "acme", ResourceType: ResourceGroup, Resource: "dnb", Lookups: 3, OccurredAt: day.Add(2 * time.Hour)},
+		{PartnerID: "acme", ResourceType: ResourceGroup, Resource: "base", Lookups: 1, OccurredAt: day.Add(2 * time.Hour)},
+		{PartnerID: "acme", ResourceType: ResourceGroup, Resource: "dnb", Lookups: 1, OccurredAt: day.Add(25 * time.Hour)},
+		{PartnerID: "acme", ResourceType: ResourceGroup, Resource: "dnb", Lookups: 1, OccurredAt: day.Add(-time.Hour)},
+		{PartnerID: "initech", ResourceType: ResourceGroup, Resource: "dnb", Lookups: 1, OccurredAt: day.Add(time.Hour)},
+	})
+	assert.NoError(t, err)
+
+	got, err := m.Summary(context.Background(), "acme", day, day.AddDate(0, 0, 2), PeriodDay)
+	assert.NoError(t, err)
+	assert.Equal(t, []Summary{
+		{PeriodStart: day, ResourceType: ResourceGroup, Resource: "base", Lookups: 1},
+		{PeriodStart: day, ResourceType: ResourceGroup, Resource: "dnb", Lookups: 4},
+		{PeriodStart: day.AddDate(0, 0, 1), ResourceType: ResourceGroup, Resource: "dnb", Lookups: 1},
+	}, got)
+
+	got, err = m.Summary(context.Background(), "acme", day, day.AddDate(0, 0, 2), PeriodMonth)
+	assert.NoError(t, err)
+	assert.Equal(t, []Summary{
+		{PeriodStart: time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC), ResourceType: ResourceGroup, Resource: "base", Lookups: 1},
+		{PeriodStart: time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC), ResourceType: ResourceGroup, Resource: "dnb", Lookups: 5},
+	}, got)
+
+	_, err = m.Summary(context.Background(), "acme", day, day.AddDate(0, 0, 2), "year")
+	assert.Equal(t, ErrInvalidPeriod, err)
+}
@@ -0,0 +1,62 @@
+package metering
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+)
+
+// sqsBatchSize is the maximum number of messages sent at once by SQS
+const sqsBatchSize = 10
+
+// SQS sends every usage event as a JSON message to a queue, locally the
+// queue is served by Localstack
+type SQS struct {
+	client   sqsiface.SQSAPI
+	queueURL string
+}
+
+var _ Sink = (*SQS)(nil)
+
+func NewSQS(client sqsiface.SQSAPI, queueURL string) *SQS {
+	return &SQS{
+		client:   client,
+		queueURL: queueURL,
+	}
+}
+
+func (s *SQS) Record(ctx context.Context, events []Event) error {
+	for start := 0; start < len(events); start += sqsBatchSize {
+		end := start + sqsBatchSize
+		if end > len(events) {
+			end = len(events)
+		}
+		entries := make([]*sqs.SendMessageBatchRequestEntry, 0, end-start)
+		for i := start; i < end; i++ {
+			body, err := json.Marshal(events[i])
+			if err != nil {
+				return err
+			}
+			entries = append(entries, &sqs.SendMessageBatchRequestEntry{
+				Id:          aws.String(strconv.Itoa(i)),
+				MessageBody: aws.String(string(body)),
+			})
+		}
+		out, err := s.client.SendMessageBatchWithContext(ctx, &sqs.SendMessageBatchInput{
+			QueueUrl: aws.String(s.queueURL),
+			Entries:  entries,
+		})
+		if err != nil {
+			return err
+		}
+		if len(out.Failed) > 0 {
+			return fmt.Errorf("%w %d usage events not sent: %s", ErrMetering, len(out.Failed), aws.StringValue(out.Failed[0].Message))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package metering
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+	"github.com/stretchr/testify/assert"
+)
+
+type sqsMock struct {
+	sqsiface.SQSAPI
+	inputs []*sqs.SendMessageBatchInput
+	failed []*sqs.BatchResultErrorEntry
+}
+
+func (m *sqsMock) SendMessageBatchWithContext(ctx aws.Context, input *sqs.SendMessageBatchInput, opts ...request.Option) (*sqs.SendMessageBatchOutput, error) {
+	m.inputs = append(m.inputs, input)
+	return &sqs.SendMessageBatchOutput{Failed: m.failed}, nil
+}
+
+func TestSQS_Record(t *testing.T) {
+	now := time.Date(2021, 3, 10, 12, 0, 0, 0, time.UTC)
+	events := make([]Event, 12)
+	for i := range events {
+		events[i] = Event{PartnerID: "acme", ResourceType: ResourceLayer, Resource: "flood", Lookups: 1, OccurredAt: now}
+	}
+	client := &sqsMock{}
+	s := NewSQS(client, "http://localhost:4566/000000000000/usage")
+	assert.NoError(t, s.Record(context.Background(), events))
+
+	if assert.Len(t, client.inputs, 2, "expected the events to be sent in batches of 10") {
+		assert.Len(t, client.inputs[0].Entries, 10)
+		assert.Len(t, client.inputs[1].Entries, 2)
+		assert.Equal(t, "http://localhost:4566/000000000000/usage", aws.StringValue(client.inputs[0].QueueUrl))
+		got := Event{}
+		assert.NoError(t, json.Unmarshal([]byte(aws.StringValue(client.inputs[1].Entries[1].MessageBody)), &got))
+		assert.Equal(t, events[11], got)
+	}
+
+	client = &sqsMock{failed: []*sqs.BatchResultErrorEntry{{Id: aws.String("0"), Message: aws.String("throttled")}}}
+	s = NewSQS(client, "http://localhost:4566/000000000000/usage")
+	assert.ErrorIs(t, s.Record(context.Background(), events[:1]), ErrMetering)
+}
@@ -0,0 +1,57 @@
+package metrics
+
+import (
+	"context"
+	"sync"
+)
+
+// value of a metric held by a batch
+type value struct {
+	name  string
+	value float64
+	unit  Unit
+	dims  Dimensions
+}
+
+// batch holds the metrics of a request until it's done, so they are written
+// together rather than as a line each
+type batch struct {
+	mu      sync.Mutex
+	values  []value
+	flushed bool
+}
+
+type batchKey struct{}
+
+// WithBatch returns a context the metrics emitted with are batched in, until
+// the emitter is flushed with it
+func WithBatch(ctx context.Context) context.Context {
+	return context.WithValue(ctx, batchKey{}, &batch{})
+}
+
+func batchFrom(ctx context.Context) *batch {
+	b, _ := ctx.Value(batchKey{}).(*batch)
+	return b
+}
+
+// add holds v, it's false once the batch was flushed, e.g. for the queries
+// shared with a request which returned before they did
+func (b *batch) add(v value) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.flushed {
+		return false
+	}
+	b.values = append(b.values, v)
+	return true
+}
+
+// flush returns the values held and stops holding new ones
+func (b *batch) flush() []value {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.flushed = true
+	values := b.values
+	b.values = nil
+	return values
+}
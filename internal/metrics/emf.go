@@ -0,0 +1,175 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxValues is the most values of a metric an EMF document can hold
+const maxValues = 100
+
+// EMF writes the metrics as CloudWatch Embedded Metric Format lines, the
+// default dimensions are added to all of them. The metrics of a batch are
+// written in as few documents as their dimensions allow, usually one.
+type EMF struct {
+	mu        sync.Mutex
+	w         io.Writer
+	namespace string
+	defaults  Dimensions
+	now       func() time.Time
+}
+
+var _ Emitter = (*EMF)(nil)
+
+type emfMetric struct {
+	Name string `json:"Name"`
+	Unit Unit   `json:"Unit"`
+}
+
+type emfDirective struct {
+	Namespace  string      `json:"Namespace"`
+	Dimensions [][]string  `json:"Dimensions"`
+	Metrics    []emfMetric `json:"Metrics"`
+}
+
+type emfMetadata struct {
+	Timestamp         int64          `json:"Timestamp"`
+	CloudWatchMetrics []emfDirective `json:"CloudWatchMetrics"`
+}
+
+func NewEMF(w io.Writer, namespace string, defaults Dimensions) *EMF {
+	return &EMF{
+		w:         w,
+		namespace: namespace,
+		defaults:  defaults,
+		now:       time.Now,
+	}
+}
+
+func (e *EMF) Emit(ctx context.Context, name string, v float64, unit Unit, dims Dimensions) {
+	all := make(Dimensions, len(e.defaults)+len(dims))
+	for k, v := range e.defaults {
+		all[k] = v
+	}
+	for k, v := range dims {
+		all[k] = v
+	}
+	val := value{name: name, value: v, unit: unit, dims: all}
+	if b := batchFrom(ctx); b != nil && b.add(val) {
+		return
+	}
+	e.write([]value{val})
+}
+
+// Flush writes the metrics batched in ctx
+func (e *EMF) Flush(ctx context.Context) {
+	if b := batchFrom(ctx); b != nil {
+		e.write(b.flush())
+	}
+}
+
+func (e *EMF) write(values []value) {
+	var docs []*emfDocument
+	for i := range values {
+		added := false
+		for _, doc := range docs {
+			if added = doc.add(values[i]); added {
+				break
+			}
+		}
+		if !added {
+			doc := newEMFDocument(e.namespace)
+			doc.add(values[i])
+			docs = append(docs, doc)
+		}
+	}
+	timestamp := e.now().UnixNano() / int64(time.Millisecond)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, doc := range docs {
+		b, err := doc.marshal(timestamp)
+		if err != nil {
+			continue
+		}
+		_, _ = e.w.Write(append(b, '\n'))
+	}
+}
+
+// emfDocument is a line of metrics whose dimensions agree: the dimensions'
+// values and the metrics' values share the root of the document, so two
+// values of a dimension or two series of a metric need their own documents
+type emfDocument struct {
+	namespace  string
+	fields     map[string]interface{}
+	dims       map[string]string
+	series     map[string]string
+	directives []emfDirective
+	byDims     map[string]int
+}
+
+func newEMFDocument(namespace string) *emfDocument {
+	return &emfDocument{
+		namespace: namespace,
+		fields:    make(map[string]interface{}),
+		dims:      make(map[string]string),
+		series:    make(map[string]string),
+		byDims:    make(map[string]int),
+	}
+}
+
+// add tells whether v could be added to the document
+func (d *emfDocument) add(v value) bool {
+	key := seriesKey(v.name, v.dims)
+	if series, ok := d.series[v.name]; ok {
+		if series != key {
+			return false
+		}
+		switch values := d.fields[v.name].(type) {
+		case float64:
+			d.fields[v.name] = []float64{values, v.value}
+		case []float64:
+			if len(values) >= maxValues {
+				return false
+			}
+			d.fields[v.name] = append(values, v.value)
+		}
+		return true
+	}
+	for k, val := range v.dims {
+		if current, ok := d.dims[k]; ok && current != val {
+			return false
+		}
+	}
+	for k, val := range v.dims {
+		d.dims[k] = val
+		d.fields[k] = val
+	}
+	d.fields[v.name] = v.value
+	d.series[v.name] = key
+	keys := v.dims.keys()
+	signature := strings.Join(keys, ",")
+	i, ok := d.byDims[signature]
+	if !ok {
+		i = len(d.directives)
+		d.byDims[signature] = i
+		d.directives = append(d.directives, emfDirective{Namespace: d.namespace, Dimensions: [][]string{keys}})
+	}
+	d.directives[i].Metrics = append(d.directives[i].Metrics, emfMetric{Name: v.name, Unit: v.unit})
+	return true
+}
+
+func (d *emfDocument) marshal(timestamp int64) ([]byte, error) {
+	line := make(map[string]interface{}, len(d.fields)+1)
+	for k, v := range d.fields {
+		line[k] = v
+	}
+	line["_aws"] = emfMetadata{
+		Timestamp:         timestamp,
+		CloudWatchMetrics: d.directives,
+	}
+	return json.Marshal(line)
+}
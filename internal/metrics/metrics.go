@@ -0,0 +1,65 @@
+// Package metrics emits the service's metrics, as CloudWatch Embedded Metric
+// Format log lines in production and to an in-memory registry in tests
+package metrics
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// names of the metrics emitted
+const (
+	EndpointLatency   = "EndpointLatency"
+	EndpointErrors    = "EndpointErrors"
+	QueryTime         = "QueryTime"
+	QueryErrors       = "QueryErrors"
+	QueryRetries      = "QueryRetries"
+	Reconnects        = "Reconnects"
+	PoolAcquiredConns = "PoolAcquiredConns"
+	PoolSaturation    = "PoolSaturation"
+	CacheHits         = "CacheHits"
+	CacheNegativeHits = "CacheNegativeHits"
+	CacheMisses       = "CacheMisses"
+)
+
+type Unit string
+
+const (
+	UnitMilliseconds Unit = "Milliseconds"
+	UnitCount        Unit = "Count"
+	UnitPercent      Unit = "Percent"
+)
+
+// Dimensions qualify a metric, e.g. the endpoint or the operation measured
+type Dimensions map[string]string
+
+// Emitter records a value of a metric, the values emitted with a context
+// carrying a batch may be held until the batch is flushed
+type Emitter interface {
+	Emit(ctx context.Context, name string, value float64, unit Unit, dims Dimensions)
+	Flush(ctx context.Context)
+}
+
+// Nop discards the metrics
+type Nop struct{}
+
+var _ Emitter = Nop{}
+
+func (Nop) Emit(ctx context.Context, name string, value float64, unit Unit, dims Dimensions) {}
+
+func (Nop) Flush(ctx context.Context) {}
+
+// Milliseconds converts a duration to the value of a latency metric
+func Milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+func (d Dimensions) keys() []string {
+	keys := make([]string, 0, len(d))
+	for k := range d {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
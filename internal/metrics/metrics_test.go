@@ -0,0 +1,101 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEMF_Emit(t *testing.T) {
+	var b bytes.Buffer
+	e := NewEMF(&b, "GeospatialLambda", Dimensions{"Service": "geospatial"})
+	e.now = func() time.Time { return time.Unix(1615377600, 0) }
+	e.Emit(context.Background(), QueryTime, 12.5, UnitMilliseconds, Dimensions{"Operation": "company_data"})
+
+	got := map[string]interface{}{}
+	assert.NoError(t, json.Unmarshal(b.Bytes(), &got))
+	assert.Equal(t, 12.5, got[QueryTime])
+	assert.Equal(t, "geospatial", got["Service"])
+	assert.Equal(t, "company_data", got["Operation"])
+	want := map[string]interface{}{
+		"Timestamp": float64(1615377600000),
+		"CloudWatchMetrics": []interface{}{
+			map[string]interface{}{
+				"Namespace":  "GeospatialLambda",
+				"Dimensions": []interface{}{[]interface{}{"Operation", "Service"}},
+				"Metrics":    []interface{}{map[string]interface{}{"Name": QueryTime, "Unit": "Milliseconds"}},
+			},
+		},
+	}
+	assert.Equal(t, want, got["_aws"])
+}
+
+func TestEMF_Flush(t *testing.T) {
+	var b bytes.Buffer
+	e := NewEMF(&b, "GeospatialLambda", Dimensions{"Service": "geospatial"})
+	e.now = func() time.Time { return time.Unix(1615377600, 0) }
+
+	ctx := WithBatch(context.Background())
+	e.Emit(ctx, QueryTime, 10, UnitMilliseconds, Dimensions{"Operation": "company_data"})
+	e.Emit(ctx, QueryTime, 30, UnitMilliseconds, Dimensions{"Operation": "company_data"})
+	e.Emit(ctx, PoolAcquiredConns, 2, UnitCount, nil)
+	e.Emit(ctx, EndpointLatency, 45, UnitMilliseconds, Dimensions{"Endpoint": "CompanyData"})
+	assert.Zero(t, b.Len(), "expected batched metrics to be held until flushed")
+
+	e.Flush(ctx)
+	lines := bytes.Split(bytes.TrimSpace(b.Bytes()), []byte("\n"))
+	if assert.Len(t, lines, 1, "expected the batch to be written as one document") {
+		got := map[string]interface{}{}
+		assert.NoError(t, json.Unmarshal(lines[0], &got))
+		assert.Equal(t, []interface{}{10.0, 30.0}, got[QueryTime])
+		assert.Equal(t, 2.0, got[PoolAcquiredConns])
+		assert.Equal(t, 45.0, got[EndpointLatency])
+		assert.Equal(t, "company_data", got["Operation"])
+		assert.Equal(t, "CompanyData", got["Endpoint"])
+		directives := got["_aws"].(map[string]interface{})["CloudWatchMetrics"].([]interface{})
+		assert.Len(t, directives, 3, "expected a directive per set of dimensions")
+	}
+
+	// the batch is done with, later metrics are written straight away
+	b.Reset()
+	e.Emit(ctx, QueryTime, 5, UnitMilliseconds, Dimensions{"Operation": "company_data"})
+	assert.Equal(t, 1, bytes.Count(b.Bytes(), []byte("\n")))
+}
+
+func TestEMF_write_conflictingDimensions(t *testing.T) {
+	var b bytes.Buffer
+	e := NewEMF(&b, "GeospatialLambda", nil)
+	e.write([]value{
+		{name: QueryTime, value: 10, unit: UnitMilliseconds, dims: Dimensions{"Operation": "company_data"}},
+		{name: QueryTime, value: 5, unit: UnitMilliseconds, dims: Dimensions{"Operation": "company_filings"}},
+		{name: QueryErrors, value: 1, unit: UnitCount, dims: Dimensions{"Operation": "company_filings", "ErrorType": "timeout"}},
+	})
+
+	lines := bytes.Split(bytes.TrimSpace(b.Bytes()), []byte("\n"))
+	if assert.Len(t, lines, 2, "expected a document per value of the operation") {
+		second := map[string]interface{}{}
+		assert.NoError(t, json.Unmarshal(lines[1], &second))
+		assert.Equal(t, "company_filings", second["Operation"])
+		assert.Equal(t, 5.0, second[QueryTime])
+		assert.Equal(t, 1.0, second[QueryErrors])
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	r.Emit(context.Background(), QueryTime, 10, UnitMilliseconds, Dimensions{"Operation": "company_data"})
+	r.Emit(context.Background(), QueryTime, 30, UnitMilliseconds, Dimensions{"Operation": "company_data"})
+	r.Emit(context.Background(), QueryTime, 5, UnitMilliseconds, Dimensions{"Operation": "sic_hierarchy"})
+
+	s, ok := r.Get(QueryTime, Dimensions{"Operation": "company_data"})
+	assert.True(t, ok)
+	assert.Equal(t, Series{Count: 2, Sum: 40, Last: 30, Unit: UnitMilliseconds}, s)
+
+	_, ok = r.Get(QueryTime, nil)
+	assert.False(t, ok, "expected series to be told apart by dimensions")
+	assert.Equal(t, `QueryTime{Group="dnb",Operation="company_data"}`, seriesKey(QueryTime, Dimensions{"Operation": "company_data", "Group": "dnb"}))
+}
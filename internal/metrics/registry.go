@@ -0,0 +1,77 @@
+package metrics
+
+import (
+	"context"
+	"strings"
+	"sync"
+)
+
+// Registry aggregates the metrics in memory by name and dimensions, like a
+// Prometheus registry, so tests can assert on them
+type Registry struct {
+	mu     sync.Mutex
+	series map[string]*Series
+}
+
+// Series aggregates the values of a metric with the same dimensions
+type Series struct {
+	Count int
+	Sum   float64
+	Last  float64
+	Unit  Unit
+}
+
+var _ Emitter = (*Registry)(nil)
+
+func NewRegistry() *Registry {
+	return &Registry{
+		series: make(map[string]*Series),
+	}
+}
+
+// Emit aggregates the value straight away, batched or not
+func (r *Registry) Emit(ctx context.Context, name string, value float64, unit Unit, dims Dimensions) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := seriesKey(name, dims)
+	s, ok := r.series[key]
+	if !ok {
+		s = &Series{Unit: unit}
+		r.series[key] = s
+	}
+	s.Count++
+	s.Sum += value
+	s.Last = value
+}
+
+func (r *Registry) Flush(ctx context.Context) {}
+
+// Get returns the series of a metric with exactly the dimensions given
+func (r *Registry) Get(name string, dims Dimensions) (Series, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.series[seriesKey(name, dims)]
+	if !ok {
+		return Series{}, false
+	}
+	return *s, true
+}
+
+// seriesKey identifies a series as in the Prometheus exposition format,
+// e.g. QueryTime{Operation="company_data"}
+func seriesKey(name string, dims Dimensions) string {
+	var b strings.Builder
+	b.WriteString(name)
+	b.WriteString("{")
+	for i, k := range dims.keys() {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		b.WriteString(k)
+		b.WriteString(`="`)
+		b.WriteString(dims[k])
+		b.WriteString(`"`)
+	}
+	b.WriteString("}")
+	return b.String()
+}
@@ -0,0 +1,294 @@
+// Package openapi generates the OpenAPI 3 document of the service from its
+// routes and the Go types of their query parameters and bodies
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Version of the OpenAPI specification the documents follow
+const Version = "3.0.3"
+
+// media types of the responses
+const (
+	ContentJSON    = "application/json"
+	ContentProblem = "application/problem+json"
+)
+
+// Route documents an operation. Query is a struct whose fields tagged with
+// schema are the query parameters, their validate tags give the enums and
+// bounds. Responses are the bodies by status, nil for the problems.
+type Route struct {
+	OperationID string
+	Method      string
+	Path        string
+	Summary     string
+	Query       interface{}
+	Responses   map[int]interface{}
+}
+
+type Document struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       Info                `json:"info"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components Components          `json:"components"`
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+// PathItem holds the operations of a path by lower case method
+type PathItem map[string]*Operation
+
+type Operation struct {
+	OperationID string               `json:"operationId"`
+	Summary     string               `json:"summary,omitempty"`
+	Parameters  []*Parameter         `json:"parameters,omitempty"`
+	Responses   map[string]*Response `json:"responses"`
+}
+
+type Parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required,omitempty"`
+	Schema   *Schema `json:"schema"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type Components struct {
+	Schemas map[string]*Schema `json:"schemas"`
+}
+
+// Schema is the subset of the OpenAPI schema object the Go types map to
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	AllOf                []*Schema          `json:"allOf,omitempty"`
+}
+
+// JSON encodes the document indented, as it's committed
+func (d *Document) JSON() ([]byte, error) {
+	b, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
+
+var pathParams = regexp.MustCompile(`\{(\w+)\}`)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// Generate documents routes, problem is the body of the error responses
+func Generate(info Info, problem interface{}, routes []Route) *Document {
+	g := &generator{
+		schemas: make(map[string]*Schema),
+		names:   make(map[reflect.Type]string),
+	}
+	doc := &Document{
+		OpenAPI:    Version,
+		Info:       info,
+		Paths:      make(map[string]PathItem),
+		Components: Components{Schemas: g.schemas},
+	}
+	for i := range routes {
+		rt := routes[i]
+		op := &Operation{
+			OperationID: rt.OperationID,
+			Summary:     rt.Summary,
+			Responses:   make(map[string]*Response, len(rt.Responses)),
+		}
+		for _, m := range pathParams.FindAllStringSubmatch(rt.Path, -1) {
+			op.Parameters = append(op.Parameters, &Parameter{Name: m[1], In: "path", Required: true, Schema: &Schema{Type: "string"}})
+		}
+		if rt.Query != nil {
+			op.Parameters = append(op.Parameters, g.queryParams(reflect.TypeOf(rt.Query))...)
+		}
+		for status, body := range rt.Responses {
+			res := &Response{Description: http.StatusText(status)}
+			if body == nil {
+				res.Content = map[string]MediaType{ContentProblem: {Schema: g.schema(reflect.TypeOf(problem))}}
+			} else {
+				res.Content = map[string]MediaType{ContentJSON: {Schema: g.schema(reflect.TypeOf(body))}}
+			}
+			op.Responses[strconv.Itoa(status)] = res
+		}
+		item, ok := doc.Paths[rt.Path]
+		if !ok {
+			item = make(PathItem)
+			doc.Paths[rt.Path] = item
+		}
+		item[strings.ToLower(rt.Method)] = op
+	}
+	return doc
+}
+
+type generator struct {
+	schemas map[string]*Schema
+	names   map[reflect.Type]string
+}
+
+// schema returns the schema of t, structs are referenced from the
+// components
+func (g *generator) schema(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return &Schema{Type: "string", Format: "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: g.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schema(t.Elem())}
+	case reflect.Struct:
+		return &Schema{Ref: "#/components/schemas/" + g.component(t)}
+	default:
+		// interfaces can hold any value
+		return &Schema{}
+	}
+}
+
+// component registers the schema of a struct once, it's named after the
+// type, qualified by its package when the name is taken
+func (g *generator) component(t reflect.Type) string {
+	if name, ok := g.names[t]; ok {
+		return name
+	}
+	name := strings.ToUpper(t.Name()[:1]) + t.Name()[1:]
+	if _, taken := g.schemas[name]; taken {
+		pkg := t.PkgPath()[strings.LastIndex(t.PkgPath(), "/")+1:]
+		name = strings.ToUpper(pkg[:1]) + pkg[1:] + name
+	}
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	// registered before the fields so recursive types terminate
+	g.names[t] = name
+	g.schemas[name] = s
+	g.fields(t, s)
+	return name
+}
+
+// fields adds the fields of t to s as encoding/json encodes them, embedded
+// structs are flattened
+func (g *generator) fields(t reflect.Type, s *Schema) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			g.fields(f.Type, s)
+			continue
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+		name, opts := parseTag(f.Tag.Get("json"))
+		if name == "-" && opts == "" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		omitempty := strings.Contains(opts, "omitempty")
+		fs := g.schema(f.Type)
+		if f.Type.Kind() == reflect.Ptr && !omitempty {
+			// siblings of $ref are ignored
+			if fs.Ref != "" {
+				fs = &Schema{AllOf: []*Schema{fs}}
+			}
+			fs.Nullable = true
+		}
+		s.Properties[name] = fs
+		if !omitempty {
+			s.Required = append(s.Required, name)
+		}
+	}
+}
+
+// queryParams documents the fields of a query params struct
+func (g *generator) queryParams(t reflect.Type) []*Parameter {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	var params []*Parameter
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _ := parseTag(f.Tag.Get("schema"))
+		if name == "" || name == "-" {
+			continue
+		}
+		p := &Parameter{Name: name, In: "query", Schema: g.schema(f.Type)}
+		for _, rule := range strings.Split(f.Tag.Get("validate"), ",") {
+			key, value := rule, ""
+			if j := strings.Index(rule, "="); j >= 0 {
+				key, value = rule[:j], rule[j+1:]
+			}
+			switch key {
+			case "required":
+				p.Required = true
+			case "oneof":
+				p.Schema.Enum = strings.Fields(value)
+			case "min", "max":
+				if p.Schema.Type != "integer" && p.Schema.Type != "number" {
+					continue
+				}
+				bound, err := strconv.ParseFloat(value, 64)
+				if err != nil {
+					continue
+				}
+				if key == "min" {
+					p.Schema.Minimum = &bound
+				} else {
+					p.Schema.Maximum = &bound
+				}
+			}
+		}
+		params = append(params, p)
+	}
+	return params
+}
+
+func parseTag(tag string) (string, string) {
+	if i := strings.Index(tag, ","); i >= 0 {
+		return tag[:i], tag[i+1:]
+	}
+	return tag, ""
+}
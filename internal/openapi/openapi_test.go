@@ -0,0 +1,79 @@
+package openapi
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testQuery struct {
+	Period string `schema:"period" validate:"omitempty,oneof=day week"`
+	Limit  int    `schema:"limit" validate:"omitempty,min=1,max=10"`
+	Cursor string `schema:"cursor" validate:"required"`
+	ignore string
+}
+
+type testItem struct {
+	Name string `json:"name"`
+}
+
+type testProblem struct {
+	Title string `json:"title"`
+}
+
+type testBody struct {
+	ID        string             `json:"id"`
+	Note      string             `json:"note,omitempty"`
+	Items     []testItem         `json:"items"`
+	Main      *testItem          `json:"main"`
+	Extra     *testItem          `json:"extra,omitempty"`
+	Scores    map[string]float64 `json:"scores,omitempty"`
+	At        time.Time          `json:"at"`
+	Any       interface{}        `json:"any,omitempty"`
+	Children  []*testBody        `json:"children,omitempty"`
+	Skipped   string             `json:"-"`
+	unexposed string
+}
+
+func TestGenerate(t *testing.T) {
+	doc := Generate(Info{Title: "test", Version: "1"}, testProblem{}, []Route{
+		{
+			OperationID: "Get",
+			Method:      http.MethodGet,
+			Path:        "/items/{id}",
+			Query:       &testQuery{},
+			Responses:   map[int]interface{}{http.StatusOK: testBody{}, http.StatusNotFound: nil},
+		},
+	})
+
+	op := doc.Paths["/items/{id}"]["get"]
+	if !assert.NotNil(t, op, "expected the operation") {
+		return
+	}
+	assert.Equal(t, "Get", op.OperationID)
+	min, max := 1.0, 10.0
+	assert.Equal(t, []*Parameter{
+		{Name: "id", In: "path", Required: true, Schema: &Schema{Type: "string"}},
+		{Name: "period", In: "query", Schema: &Schema{Type: "string", Enum: []string{"day", "week"}}},
+		{Name: "limit", In: "query", Schema: &Schema{Type: "integer", Format: "int32", Minimum: &min, Maximum: &max}},
+		{Name: "cursor", In: "query", Required: true, Schema: &Schema{Type: "string"}},
+	}, op.Parameters, "unexpected parameters")
+	assert.Equal(t, &Schema{Ref: "#/components/schemas/TestBody"}, op.Responses["200"].Content[ContentJSON].Schema)
+	assert.Equal(t, &Schema{Ref: "#/components/schemas/TestProblem"}, op.Responses["404"].Content[ContentProblem].Schema)
+	assert.Equal(t, "Not Found", op.Responses["404"].Description)
+
+	body := doc.Components.Schemas["TestBody"]
+	if !assert.NotNil(t, body, "expected the body's schema") {
+		return
+	}
+	assert.Equal(t, []string{"id", "items", "main", "at"}, body.Required, "unexpected required properties")
+	assert.Len(t, body.Properties, 9, "unexpected properties")
+	assert.Equal(t, &Schema{Type: "array", Items: &Schema{Ref: "#/components/schemas/TestItem"}}, body.Properties["items"])
+	assert.Equal(t, &Schema{Nullable: true, AllOf: []*Schema{{Ref: "#/components/schemas/TestItem"}}}, body.Properties["main"])
+	assert.Equal(t, &Schema{Ref: "#/components/schemas/TestItem"}, body.Properties["extra"])
+	assert.Equal(t, &Schema{Type: "object", AdditionalProperties: &Schema{Type: "number"}}, body.Properties["scores"])
+	assert.Equal(t, &Schema{Type: "string", Format: "date-time"}, body.Properties["at"])
+	assert.Equal(t, &Schema{Type: "array", Items: &Schema{Ref: "#/components/schemas/TestBody"}}, body.Properties["children"], "expected recursive types to be referenced")
+}
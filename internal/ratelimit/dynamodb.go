@@ -0,0 +1,102 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// itemTTL is how long a bucket is kept after its last update, long enough
+// for the quota of the day to be counted
+const itemTTL = 48 * time.Hour
+
+// DynamoDB keeps the buckets in a table keyed by the string attribute pk,
+// the expires_at attribute is meant to be the table's TTL
+type DynamoDB struct {
+	client dynamodbiface.DynamoDBAPI
+	table  string
+}
+
+var _ Store = (*DynamoDB)(nil)
+
+type dynamoItem struct {
+	PK        string  `dynamodbav:"pk"`
+	Tokens    float64 `dynamodbav:"tokens"`
+	UpdatedAt int64   `dynamodbav:"updated_at"`
+	Day       string  `dynamodbav:"day"`
+	Used      int     `dynamodbav:"used"`
+	Version   int64   `dynamodbav:"version"`
+	ExpiresAt int64   `dynamodbav:"expires_at"`
+}
+
+func NewDynamoDB(client dynamodbiface.DynamoDBAPI, table string) *DynamoDB {
+	return &DynamoDB{
+		client: client,
+		table:  table,
+	}
+}
+
+func (d *DynamoDB) Get(ctx context.Context, key string) (State, int64, error) {
+	out, err := d.client.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(d.table),
+		Key:            map[string]*dynamodb.AttributeValue{"pk": {S: aws.String(key)}},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return State{}, 0, err
+	}
+	if len(out.Item) == 0 {
+		return State{}, 0, nil
+	}
+	item := dynamoItem{}
+	if err := dynamodbattribute.UnmarshalMap(out.Item, &item); err != nil {
+		return State{}, 0, err
+	}
+	return State{
+		Tokens:    item.Tokens,
+		UpdatedAt: time.Unix(0, item.UpdatedAt).UTC(),
+		Day:       item.Day,
+		Used:      item.Used,
+	}, item.Version, nil
+}
+
+func (d *DynamoDB) Put(ctx context.Context, key string, state State, version int64) error {
+	item, err := dynamodbattribute.MarshalMap(dynamoItem{
+		PK:        key,
+		Tokens:    state.Tokens,
+		UpdatedAt: state.UpdatedAt.UnixNano(),
+		Day:       state.Day,
+		Used:      state.Used,
+		Version:   version + 1,
+		ExpiresAt: state.UpdatedAt.Add(itemTTL).Unix(),
+	})
+	if err != nil {
+		return err
+	}
+	input := &dynamodb.PutItemInput{
+		TableName:           aws.String(d.table),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(pk)"),
+	}
+	if version > 0 {
+		input.ConditionExpression = aws.String("version = :version")
+		input.ExpressionAttributeValues = map[string]*dynamodb.AttributeValue{
+			":version": {N: aws.String(strconv.FormatInt(version, 10))},
+		}
+	}
+	if _, err := d.client.PutItemWithContext(ctx, input); err != nil {
+		var awsErr awserr.Error
+		if errors.As(err, &awsErr) && awsErr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+			return ErrConflict
+		}
+		return err
+	}
+	return nil
+}
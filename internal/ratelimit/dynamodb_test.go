@@ -0,0 +1,62 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/stretchr/testify/assert"
+)
+
+type dynamoMock struct {
+	dynamodbiface.DynamoDBAPI
+	item map[string]*dynamodb.AttributeValue
+	puts []*dynamodb.PutItemInput
+	fail bool
+}
+
+func (m *dynamoMock) GetItemWithContext(ctx aws.Context, input *dynamodb.GetItemInput, opts ...request.Option) (*dynamodb.GetItemOutput, error) {
+	return &dynamodb.GetItemOutput{Item: m.item}, nil
+}
+
+func (m *dynamoMock) PutItemWithContext(ctx aws.Context, input *dynamodb.PutItemInput, opts ...request.Option) (*dynamodb.PutItemOutput, error) {
+	m.puts = append(m.puts, input)
+	if m.fail {
+		return nil, awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "conditional check failed", nil)
+	}
+	m.item = input.Item
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func TestDynamoDB(t *testing.T) {
+	ctx := context.Background()
+	client := &dynamoMock{}
+	d := NewDynamoDB(client, "rate-limits")
+
+	state, version, err := d.Get(ctx, "acme|CompanyData")
+	assert.NoError(t, err)
+	assert.Equal(t, State{}, state)
+	assert.Equal(t, int64(0), version)
+
+	updated := time.Date(2021, 3, 10, 12, 0, 0, 0, time.UTC)
+	want := State{Tokens: 1.5, UpdatedAt: updated, Day: "2021-03-10", Used: 3}
+	assert.NoError(t, d.Put(ctx, "acme|CompanyData", want, 0))
+	assert.Equal(t, "attribute_not_exists(pk)", aws.StringValue(client.puts[0].ConditionExpression))
+
+	state, version, err = d.Get(ctx, "acme|CompanyData")
+	assert.NoError(t, err)
+	assert.Equal(t, want, state)
+	assert.Equal(t, int64(1), version)
+
+	assert.NoError(t, d.Put(ctx, "acme|CompanyData", want, version))
+	assert.Equal(t, "version = :version", aws.StringValue(client.puts[1].ConditionExpression))
+	assert.Equal(t, "1", aws.StringValue(client.puts[1].ExpressionAttributeValues[":version"].N))
+
+	client.fail = true
+	assert.Equal(t, ErrConflict, d.Put(ctx, "acme|CompanyData", want, version))
+}
@@ -0,0 +1,40 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+)
+
+// Memory keeps the buckets in process, a lambda instance only sees its own
+// requests so it's meant for tests and local runs
+type Memory struct {
+	mu      sync.Mutex
+	states  map[string]State
+	version map[string]int64
+}
+
+var _ Store = (*Memory)(nil)
+
+func NewMemory() *Memory {
+	return &Memory{
+		states:  make(map[string]State),
+		version: make(map[string]int64),
+	}
+}
+
+func (m *Memory) Get(ctx context.Context, key string) (State, int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.states[key], m.version[key], nil
+}
+
+func (m *Memory) Put(ctx context.Context, key string, state State, version int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.version[key] != version {
+		return ErrConflict
+	}
+	m.states[key] = state
+	m.version[key] = version + 1
+	return nil
+}
@@ -0,0 +1,178 @@
+// Package ratelimit limits the requests of each partner to an endpoint with
+// a token bucket and a daily quota
+package ratelimit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// DefaultEndpoint holds the limit of the endpoints without their own
+const DefaultEndpoint = "default"
+
+// conflictAttempts is how many times a bucket updated concurrently is read
+// and written again, conflictRetryAfter is how long the partner is asked to
+// wait once every attempt conflicted
+const (
+	conflictAttempts   = 3
+	conflictRetryAfter = time.Second
+)
+
+// dayLayout identifies the day a quota is counted for
+const dayLayout = "2006-01-02"
+
+var (
+	ErrRateLimit     = errors.New("rate limit error")
+	ErrConflict      = fmt.Errorf("%w bucket updated concurrently", ErrRateLimit)
+	ErrInvalidLimits = fmt.Errorf("%w invalid limits", ErrRateLimit)
+)
+
+// Limit of the requests to an endpoint: Rate tokens are added to a bucket
+// holding at most Burst tokens every second, DailyQuota caps the requests of
+// a UTC day. 0 disables either.
+type Limit struct {
+	Rate       float64 `json:"rate"`
+	Burst      int     `json:"burst"`
+	DailyQuota int     `json:"daily_quota"`
+}
+
+// Limits are keyed by endpoint, DefaultEndpoint applies to the others
+type Limits map[string]Limit
+
+// ParseLimits reads the limits from a JSON object keyed by endpoint, e.g.
+// {"default":{"rate":5,"burst":10},"CompanyData":{"rate":2,"burst":5,"daily_quota":10000}}
+func ParseLimits(data string) (Limits, error) {
+	limits := Limits{}
+	if data == "" {
+		return limits, nil
+	}
+	if err := json.Unmarshal([]byte(data), &limits); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidLimits, err)
+	}
+	for endpoint, l := range limits {
+		if l.Rate < 0 || l.DailyQuota < 0 || (l.Rate > 0 && l.Burst < 1) {
+			return nil, fmt.Errorf("%w: endpoint %s needs a positive rate and burst", ErrInvalidLimits, endpoint)
+		}
+	}
+	return limits, nil
+}
+
+func (l Limits) forEndpoint(endpoint string) (Limit, bool) {
+	if limit, ok := l[endpoint]; ok {
+		return limit, true
+	}
+	limit, ok := l[DefaultEndpoint]
+	return limit, ok
+}
+
+// State of a partner's bucket and quota for an endpoint
+type State struct {
+	Tokens    float64
+	UpdatedAt time.Time
+	Day       string
+	Used      int
+}
+
+// Store keeps the states of the buckets. Put fails with ErrConflict when the
+// state was written since it was read with version, 0 being a new state.
+type Store interface {
+	Get(ctx context.Context, key string) (state State, version int64, err error)
+	Put(ctx context.Context, key string, state State, version int64) error
+}
+
+// Decision tells whether a request is allowed, and when not how long the
+// partner should wait before retrying
+type Decision struct {
+	Allowed    bool
+	RetryAfter time.Duration
+	Reason     string
+}
+
+// reasons a request is rejected
+const (
+	ReasonRate      = "rate"
+	ReasonQuota     = "quota"
+	ReasonContended = "contended"
+)
+
+type Limiter struct {
+	store  Store
+	limits Limits
+	now    func() time.Time
+}
+
+func New(store Store, limits Limits) *Limiter {
+	return &Limiter{
+		store:  store,
+		limits: limits,
+		now:    time.Now,
+	}
+}
+
+// Allow takes a token from the partner's bucket for the endpoint. A bucket
+// still updated concurrently after conflictAttempts is a partner sending
+// requests faster than they can be counted, they are rejected rather than
+// let through uncounted; errors are left to the store's failures.
+func (l *Limiter) Allow(ctx context.Context, partnerID, endpoint string) (Decision, error) {
+	limit, ok := l.limits.forEndpoint(endpoint)
+	if !ok || (limit.Rate == 0 && limit.DailyQuota == 0) {
+		return Decision{Allowed: true}, nil
+	}
+	key := partnerID + "|" + endpoint
+	var err error
+	for attempt := 0; attempt < conflictAttempts; attempt++ {
+		var state State
+		var version int64
+		state, version, err = l.store.Get(ctx, key)
+		if err != nil {
+			return Decision{}, err
+		}
+		decision, next := take(limit, state, version == 0, l.now().UTC())
+		if !decision.Allowed {
+			return decision, nil
+		}
+		err = l.store.Put(ctx, key, next, version)
+		if err == nil {
+			return decision, nil
+		}
+		if !errors.Is(err, ErrConflict) {
+			return Decision{}, err
+		}
+	}
+	return Decision{RetryAfter: conflictRetryAfter, Reason: ReasonContended}, nil
+}
+
+// take refills the bucket up to now and takes a token from it, the state
+// returned is only meant to be stored when the request is allowed
+func take(limit Limit, state State, isNew bool, now time.Time) (Decision, State) {
+	day := now.Format(dayLayout)
+	if isNew {
+		state = State{Tokens: float64(limit.Burst), UpdatedAt: now, Day: day}
+	}
+	if state.Day != day {
+		state.Day = day
+		state.Used = 0
+	}
+	if limit.DailyQuota > 0 && state.Used >= limit.DailyQuota {
+		midnight := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+		return Decision{RetryAfter: midnight.Sub(now), Reason: ReasonQuota}, state
+	}
+	if limit.Rate > 0 {
+		elapsed := now.Sub(state.UpdatedAt).Seconds()
+		if elapsed > 0 {
+			state.Tokens = math.Min(float64(limit.Burst), state.Tokens+elapsed*limit.Rate)
+		}
+		state.UpdatedAt = now
+		if state.Tokens < 1 {
+			wait := time.Duration((1 - state.Tokens) / limit.Rate * float64(time.Second))
+			return Decision{RetryAfter: wait, Reason: ReasonRate}, state
+		}
+		state.Tokens--
+	}
+	state.Used++
+	return Decision{Allowed: true}, state
+}
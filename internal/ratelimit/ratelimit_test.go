@@ -0,0 +1,129 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLimits(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    Limits
+		wantErr bool
+	}{
+		{name: "empty", data: "", want: Limits{}},
+		{
+			name: "limits",
+			data: `{"default":{"rate":5,"burst":10},"CompanyData":{"rate":0.5,"burst":2,"daily_quota":100}}`,
+			want: Limits{
+				"default":     {Rate: 5, Burst: 10},
+				"CompanyData": {Rate: 0.5, Burst: 2, DailyQuota: 100},
+			},
+		},
+		{name: "quota only", data: `{"default":{"daily_quota":100}}`, want: Limits{"default": {DailyQuota: 100}}},
+		{name: "missing burst", data: `{"default":{"rate":5}}`, wantErr: true},
+		{name: "negative quota", data: `{"default":{"daily_quota":-1}}`, wantErr: true},
+		{name: "malformed", data: `{"default":5}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseLimits(tt.data)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidLimits)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestLimiter_Allow_rate(t *testing.T) {
+	now := time.Date(2021, 3, 10, 12, 0, 0, 0, time.UTC)
+	l := New(NewMemory(), Limits{DefaultEndpoint: {Rate: 2, Burst: 2}})
+	l.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		d, err := l.Allow(ctx, "acme", "CompanyData")
+		assert.NoError(t, err)
+		assert.True(t, d.Allowed, "expected burst to be allowed")
+	}
+	d, err := l.Allow(ctx, "acme", "CompanyData")
+	assert.NoError(t, err)
+	assert.False(t, d.Allowed, "expected empty bucket to reject")
+	assert.Equal(t, ReasonRate, d.Reason)
+	assert.Equal(t, 500*time.Millisecond, d.RetryAfter)
+
+	// buckets are per partner and endpoint
+	d, _ = l.Allow(ctx, "initech", "CompanyData")
+	assert.True(t, d.Allowed)
+	d, _ = l.Allow(ctx, "acme", "CompanyVersions")
+	assert.True(t, d.Allowed)
+
+	now = now.Add(500 * time.Millisecond)
+	d, _ = l.Allow(ctx, "acme", "CompanyData")
+	assert.True(t, d.Allowed, "expected refilled token to be allowed")
+	d, _ = l.Allow(ctx, "acme", "CompanyData")
+	assert.False(t, d.Allowed)
+}
+
+func TestLimiter_Allow_quota(t *testing.T) {
+	now := time.Date(2021, 3, 10, 18, 0, 0, 0, time.UTC)
+	l := New(NewMemory(), Limits{"CompanyData": {DailyQuota: 2}})
+	l.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		d, err := l.Allow(ctx, "acme", "CompanyData")
+		assert.NoError(t, err)
+		assert.True(t, d.Allowed)
+	}
+	d, err := l.Allow(ctx, "acme", "CompanyData")
+	assert.NoError(t, err)
+	assert.False(t, d.Allowed, "expected exhausted quota to reject")
+	assert.Equal(t, ReasonQuota, d.Reason)
+	assert.Equal(t, 6*time.Hour, d.RetryAfter)
+
+	// endpoints without limits nor default aren't limited
+	d, _ = l.Allow(ctx, "acme", "CompanyVersions")
+	assert.True(t, d.Allowed)
+
+	now = now.Add(6 * time.Hour)
+	d, _ = l.Allow(ctx, "acme", "CompanyData")
+	assert.True(t, d.Allowed, "expected quota to reset the next day")
+}
+
+// conflictingStore fails the first writes as if another instance updated
+// the bucket in between
+type conflictingStore struct {
+	*Memory
+	conflicts int
+}
+
+func (s *conflictingStore) Put(ctx context.Context, key string, state State, version int64) error {
+	if s.conflicts > 0 {
+		s.conflicts--
+		return ErrConflict
+	}
+	return s.Memory.Put(ctx, key, state, version)
+}
+
+func TestLimiter_Allow_conflicts(t *testing.T) {
+	limits := Limits{DefaultEndpoint: {Rate: 1, Burst: 1}}
+
+	l := New(&conflictingStore{Memory: NewMemory(), conflicts: conflictAttempts - 1}, limits)
+	d, err := l.Allow(context.Background(), "acme", "CompanyData")
+	assert.NoError(t, err)
+	assert.True(t, d.Allowed)
+
+	// every write conflicting is contention, not a store outage
+	l = New(&conflictingStore{Memory: NewMemory(), conflicts: conflictAttempts}, limits)
+	d, err = l.Allow(context.Background(), "acme", "CompanyData")
+	assert.NoError(t, err)
+	assert.Equal(t, Decision{RetryAfter: conflictRetryAfter, Reason: ReasonContended}, d)
+}
@@ -5,7 +5,13 @@ const (
 	ServiceName = "geospatial-lambda"
 
 	// endpoints
-	CompanyDataEndpoint = "CompanyData"
+	CompanyDataEndpoint     = "CompanyData"
+	CompanyVersionsEndpoint = "CompanyVersions"
+	CompanyChangesEndpoint  = "CompanyChanges"
+	EntitlementsEndpoint    = "Entitlements"
+	UsageEndpoint           = "Usage"
+	HealthEndpoint          = "Health"
+	OpenAPIEndpoint         = "OpenAPI"
 
 	DataDiscovery = "DataDiscovery"
 
@@ -0,0 +1,83 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// Backend stores the cached values, it can be an in-process LRU or a
+// remote store such as Redis
+type Backend interface {
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+}
+
+type lruEntry struct {
+	key       string
+	value     []byte
+	expiresAt time.Time
+}
+
+// LRU is an in-process Backend that evicts the least recently used entry
+// once its size is reached
+type LRU struct {
+	mu      sync.Mutex
+	size    int
+	entries map[string]*list.Element
+	order   *list.List
+	now     func() time.Time
+}
+
+func NewLRU(size int) *LRU {
+	return &LRU{
+		size:    size,
+		entries: make(map[string]*list.Element, size),
+		order:   list.New(),
+		now:     time.Now,
+	}
+}
+
+func (c *LRU) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	entry := el.Value.(*lruEntry)
+	if c.now().After(entry.expiresAt) {
+		c.order.Remove(el)
+		delete(c.entries, key)
+		return nil, false, nil
+	}
+	c.order.MoveToFront(el)
+	return entry.value, true, nil
+}
+
+func (c *LRU) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	expiresAt := c.now().Add(ttl)
+	if el, ok := c.entries[key]; ok {
+		entry := el.Value.(*lruEntry)
+		entry.value = value
+		entry.expiresAt = expiresAt
+		c.order.MoveToFront(el)
+		return nil
+	}
+	c.entries[key] = c.order.PushFront(&lruEntry{key: key, value: value, expiresAt: expiresAt})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*lruEntry).key)
+	}
+	return nil
+}
+
+func (c *LRU) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
@@ -0,0 +1,201 @@
+// Package cache provides a read-through cache in front of a storage.Storage
+package cache
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/cytora/geospatial-lambda/internal/metrics"
+	"github.com/cytora/geospatial-lambda/internal/storage"
+	"github.com/cytora/go-platform-utils/logging"
+)
+
+const (
+	valueMarker    byte = 0
+	notFoundMarker byte = 1
+)
+
+// Stats counts the cache lookups
+type Stats struct {
+	Hits         uint64
+	NegativeHits uint64
+	Misses       uint64
+}
+
+// Storage caches the results of the wrapped storage. Company changes are
+// never cached as consumers rely on them to find out about updates.
+type Storage struct {
+	next    storage.Storage
+	backend Backend
+	opts    *Options
+
+	hits         uint64
+	negativeHits uint64
+	misses       uint64
+}
+
+var _ storage.Storage = (*Storage)(nil)
+
+func New(next storage.Storage, backend Backend, opts ...OptionFunc) *Storage {
+	opt := defaultOptions()
+	for _, f := range opts {
+		f(opt)
+	}
+	return &Storage{
+		next:    next,
+		backend: backend,
+		opts:    opt,
+	}
+}
+
+func (s *Storage) Stats() Stats {
+	return Stats{
+		Hits:         atomic.LoadUint64(&s.hits),
+		NegativeHits: atomic.LoadUint64(&s.negativeHits),
+		Misses:       atomic.LoadUint64(&s.misses),
+	}
+}
+
+// get looks key up and decodes the cached value into dst. The returned
+// error is storage.ErrNotFound for negative entries.
+func (s *Storage) get(ctx context.Context, key string, dst interface{}) (bool, error) {
+	raw, ok, err := s.backend.Get(ctx, key)
+	if err != nil {
+		logging.Error(ctx, err, logging.Data{"key": key}, "cache get error")
+		return false, nil
+	}
+	if !ok || len(raw) == 0 {
+		s.count(ctx, &s.misses, metrics.CacheMisses, key)
+		return false, nil
+	}
+	if raw[0] == notFoundMarker {
+		s.count(ctx, &s.negativeHits, metrics.CacheNegativeHits, key)
+		return true, storage.ErrNotFound
+	}
+	if err := gob.NewDecoder(bytes.NewReader(raw[1:])).Decode(dst); err != nil {
+		logging.Error(ctx, err, logging.Data{"key": key}, "cache decode error")
+		s.count(ctx, &s.misses, metrics.CacheMisses, key)
+		return false, nil
+	}
+	s.count(ctx, &s.hits, metrics.CacheHits, key)
+	return true, nil
+}
+
+// count increments a lookup counter and emits its metric for the operation
+// the key belongs to, i.e. its prefix
+func (s *Storage) count(ctx context.Context, counter *uint64, name string, key string) {
+	atomic.AddUint64(counter, 1)
+	operation := key
+	if i := strings.Index(key, ":"); i >= 0 {
+		operation = key[:i]
+	}
+	s.opts.metrics.Emit(ctx, name, 1, metrics.UnitCount, metrics.Dimensions{"Operation": operation})
+}
+
+// set caches the result of a storage call, not found errors are cached for
+// the negative ttl and any other error is not cached
+func (s *Storage) set(ctx context.Context, key string, value interface{}, err error) {
+	var b bytes.Buffer
+	ttl := s.opts.ttl
+	switch err {
+	case nil:
+		// gob can't encode nil pointers, there's nothing worth caching anyway
+		if v := reflect.ValueOf(value); v.Kind() == reflect.Ptr && v.IsNil() {
+			return
+		}
+		b.WriteByte(valueMarker)
+		if err := gob.NewEncoder(&b).Encode(value); err != nil {
+			logging.Error(ctx, err, logging.Data{"key": key}, "cache encode error")
+			return
+		}
+	case storage.ErrNotFound:
+		b.WriteByte(notFoundMarker)
+		ttl = s.opts.negativeTTL
+	default:
+		return
+	}
+	if err := s.backend.Set(ctx, key, b.Bytes(), ttl); err != nil {
+		logging.Error(ctx, err, logging.Data{"key": key}, "cache set error")
+	}
+}
+
+func (s *Storage) CompanyData(ctx context.Context, crn string, groups []string, fields []string, asOf time.Time) (*storage.Data, error) {
+	key := storage.CompanyDataKey(crn, groups, fields, asOf)
+	data := &storage.Data{}
+	if ok, err := s.get(ctx, key, data); ok {
+		if err != nil {
+			return nil, err
+		}
+		return data, nil
+	}
+	data, err := s.next.CompanyData(ctx, crn, groups, fields, asOf)
+	s.set(ctx, key, data, err)
+	return data, err
+}
+
+func (s *Storage) CompanyVersions(ctx context.Context, crn string) ([]storage.Version, error) {
+	key := storage.CompanyVersionsKey(crn)
+	var versions []storage.Version
+	if ok, err := s.get(ctx, key, &versions); ok {
+		return versions, err
+	}
+	versions, err := s.next.CompanyVersions(ctx, crn)
+	s.set(ctx, key, versions, err)
+	return versions, err
+}
+
+func (s *Storage) GroupsMetadata(ctx context.Context, groups []string, asOf time.Time) ([]storage.GroupMetadata, error) {
+	key := storage.GroupsMetadataKey(groups, asOf)
+	var metadata []storage.GroupMetadata
+	if ok, err := s.get(ctx, key, &metadata); ok {
+		return metadata, err
+	}
+	metadata, err := s.next.GroupsMetadata(ctx, groups, asOf)
+	s.set(ctx, key, metadata, err)
+	return metadata, err
+}
+
+func (s *Storage) CompanyChanges(ctx context.Context, since time.Time, afterCRN string, until time.Time, limit int) ([]storage.Change, error) {
+	return s.next.CompanyChanges(ctx, since, afterCRN, until, limit)
+}
+
+func (s *Storage) CompanyFilings(ctx context.Context, crn string, asOf time.Time, page storage.Page) ([]storage.Filing, error) {
+	key := storage.CompanyFilingsKey(crn, asOf, page)
+	var filings []storage.Filing
+	if ok, err := s.get(ctx, key, &filings); ok {
+		return filings, err
+	}
+	filings, err := s.next.CompanyFilings(ctx, crn, asOf, page)
+	s.set(ctx, key, filings, err)
+	return filings, err
+}
+
+func (s *Storage) CompanyOfficers(ctx context.Context, crn string, asOf time.Time, page storage.Page) ([]storage.Officer, error) {
+	key := storage.CompanyOfficersKey(crn, asOf, page)
+	var officers []storage.Officer
+	if ok, err := s.get(ctx, key, &officers); ok {
+		return officers, err
+	}
+	officers, err := s.next.CompanyOfficers(ctx, crn, asOf, page)
+	s.set(ctx, key, officers, err)
+	return officers, err
+}
+
+func (s *Storage) SICHierarchy(ctx context.Context, code string) (*storage.SIC, error) {
+	key := storage.SICHierarchyKey(code)
+	sic := &storage.SIC{}
+	if ok, err := s.get(ctx, key, sic); ok {
+		if err != nil {
+			return nil, err
+		}
+		return sic, nil
+	}
+	sic, err := s.next.SICHierarchy(ctx, code)
+	s.set(ctx, key, sic, err)
+	return sic, err
+}
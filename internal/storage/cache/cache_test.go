@@ -0,0 +1,117 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgtype"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cytora/geospatial-lambda/internal/metrics"
+	"github.com/cytora/geospatial-lambda/internal/storage"
+	"github.com/cytora/geospatial-lambda/internal/storage/mock"
+)
+
+func TestStorage_CompanyData(t *testing.T) {
+	data := &storage.Data{
+		CRN:          pgtype.Text{String: "00111222", Status: pgtype.Present},
+		DnBEmployees: pgtype.Float8{Float: 10, Status: pgtype.Present},
+	}
+	tests := []struct {
+		name          string
+		stgErr        error
+		stgResults    *storage.Data
+		expectedErr   error
+		expectedCalls int
+		expectedStats Stats
+	}{
+		{
+			name:          "cached",
+			stgResults:    data,
+			expectedCalls: 1,
+			expectedStats: Stats{Hits: 1, Misses: 1},
+		},
+		{
+			name:          "not found cached",
+			stgErr:        storage.ErrNotFound,
+			expectedErr:   storage.ErrNotFound,
+			expectedCalls: 1,
+			expectedStats: Stats{NegativeHits: 1, Misses: 1},
+		},
+		{
+			name:          "errors not cached",
+			stgErr:        errors.New("oops"),
+			expectedErr:   errors.New("oops"),
+			expectedCalls: 2,
+			expectedStats: Stats{Misses: 2},
+		},
+	}
+	for i := range tests {
+		tt := tests[i]
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			stg := &mock.StorageMock{
+				Results: tt.stgResults,
+				Err:     tt.stgErr,
+			}
+			reg := metrics.NewRegistry()
+			c := New(stg, NewLRU(10), WithMetrics(reg))
+			for i := 0; i < 2; i++ {
+				got, err := c.CompanyData(ctx, "00111222", []string{"dnb", "base"}, nil, time.Time{})
+				assert.Equal(t, tt.expectedErr, err, "unexpected error")
+				if tt.expectedErr == nil {
+					assert.Equal(t, tt.stgResults, got, "unexpected results")
+				}
+			}
+			assert.Equal(t, tt.expectedCalls, stg.CompanyDataCalls, "unexpected storage calls")
+			assert.Equal(t, tt.expectedStats, c.Stats(), "unexpected stats")
+			dims := metrics.Dimensions{"Operation": "company"}
+			for name, want := range map[string]uint64{
+				metrics.CacheHits:         tt.expectedStats.Hits,
+				metrics.CacheNegativeHits: tt.expectedStats.NegativeHits,
+				metrics.CacheMisses:       tt.expectedStats.Misses,
+			} {
+				got, _ := reg.Get(name, dims)
+				assert.Equal(t, int(want), got.Count, "unexpected %s", name)
+			}
+		})
+	}
+}
+
+func TestStorage_CompanyDataKey(t *testing.T) {
+	ctx := context.Background()
+	stg := &mock.StorageMock{
+		Results: &storage.Data{CRN: pgtype.Text{String: "00111222", Status: pgtype.Present}},
+	}
+	c := New(stg, NewLRU(10))
+	_, _ = c.CompanyData(ctx, "00111222", []string{"dnb", "base"}, nil, time.Time{})
+	_, _ = c.CompanyData(ctx, "00111222", []string{"base", "dnb"}, nil, time.Time{})
+	assert.Equal(t, 1, stg.CompanyDataCalls, "groups order should not matter")
+	_, _ = c.CompanyData(ctx, "00111222", []string{"base", "dnb"}, []string{"dnb_employees"}, time.Time{})
+	_, _ = c.CompanyData(ctx, "00111222", []string{"base", "dnb"}, nil, time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC))
+	assert.Equal(t, 3, stg.CompanyDataCalls, "fields and as of should be part of the key")
+}
+
+func TestLRU(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := NewLRU(2)
+	c.now = func() time.Time { return now }
+
+	assert.Nil(t, c.Set(ctx, "a", []byte("1"), time.Minute))
+	assert.Nil(t, c.Set(ctx, "b", []byte("2"), time.Minute))
+	_, ok, _ := c.Get(ctx, "a")
+	assert.True(t, ok, "a should be cached")
+
+	// b is the least recently used
+	assert.Nil(t, c.Set(ctx, "c", []byte("3"), time.Minute))
+	_, ok, _ = c.Get(ctx, "b")
+	assert.False(t, ok, "b should be evicted")
+	assert.Equal(t, 2, c.Len(), "unexpected size")
+
+	now = now.Add(2 * time.Minute)
+	_, ok, _ = c.Get(ctx, "a")
+	assert.False(t, ok, "a should be expired")
+}
@@ -0,0 +1,44 @@
+package cache
+
+import (
+	"time"
+
+	"github.com/cytora/geospatial-lambda/internal/metrics"
+)
+
+type OptionFunc func(opt *Options)
+
+type Options struct {
+	ttl         time.Duration
+	negativeTTL time.Duration
+	metrics     metrics.Emitter
+}
+
+func defaultOptions() *Options {
+	return &Options{
+		ttl:         5 * time.Minute,
+		negativeTTL: time.Minute,
+		metrics:     metrics.Nop{},
+	}
+}
+
+// WithTTL sets for how long retrieved data is cached
+func WithTTL(ttl time.Duration) OptionFunc {
+	return func(opt *Options) {
+		opt.ttl = ttl
+	}
+}
+
+// WithNegativeTTL sets for how long a not found result is cached
+func WithNegativeTTL(ttl time.Duration) OptionFunc {
+	return func(opt *Options) {
+		opt.negativeTTL = ttl
+	}
+}
+
+// WithMetrics emits the cache hits, negative hits and misses to emitter
+func WithMetrics(emitter metrics.Emitter) OptionFunc {
+	return func(opt *Options) {
+		opt.metrics = emitter
+	}
+}
@@ -0,0 +1,92 @@
+// Package coalesce shares a single storage query between concurrent
+// identical requests
+package coalesce
+
+import (
+	"context"
+	"time"
+
+	"github.com/cytora/geospatial-lambda/internal/storage"
+)
+
+// Storage coalesces the concurrent identical calls to the wrapped storage,
+// callers sharing a call receive the same results and must not modify them
+type Storage struct {
+	next  storage.Storage
+	calls group
+}
+
+var _ storage.Storage = (*Storage)(nil)
+
+func New(next storage.Storage) *Storage {
+	return &Storage{
+		next: next,
+	}
+}
+
+// do shares fn between the concurrent calls with the same key, a caller
+// leaving because of its deadline gets storage.ErrTimeout like the storage
+// it wraps would return
+func (s *Storage) do(ctx context.Context, key string, fn func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	v, err := s.calls.do(ctx, key, fn)
+	if err == context.DeadlineExceeded {
+		return nil, storage.ErrTimeout
+	}
+	return v, err
+}
+
+func (s *Storage) CompanyData(ctx context.Context, crn string, groups []string, fields []string, asOf time.Time) (*storage.Data, error) {
+	v, err := s.do(ctx, storage.CompanyDataKey(crn, groups, fields, asOf), func(ctx context.Context) (interface{}, error) {
+		return s.next.CompanyData(ctx, crn, groups, fields, asOf)
+	})
+	data, _ := v.(*storage.Data)
+	return data, err
+}
+
+func (s *Storage) CompanyVersions(ctx context.Context, crn string) ([]storage.Version, error) {
+	v, err := s.do(ctx, storage.CompanyVersionsKey(crn), func(ctx context.Context) (interface{}, error) {
+		return s.next.CompanyVersions(ctx, crn)
+	})
+	versions, _ := v.([]storage.Version)
+	return versions, err
+}
+
+func (s *Storage) GroupsMetadata(ctx context.Context, groups []string, asOf time.Time) ([]storage.GroupMetadata, error) {
+	v, err := s.do(ctx, storage.GroupsMetadataKey(groups, asOf), func(ctx context.Context) (interface{}, error) {
+		return s.next.GroupsMetadata(ctx, groups, asOf)
+	})
+	metadata, _ := v.([]storage.GroupMetadata)
+	return metadata, err
+}
+
+func (s *Storage) CompanyChanges(ctx context.Context, since time.Time, afterCRN string, until time.Time, limit int) ([]storage.Change, error) {
+	v, err := s.do(ctx, storage.CompanyChangesKey(since, afterCRN, until, limit), func(ctx context.Context) (interface{}, error) {
+		return s.next.CompanyChanges(ctx, since, afterCRN, until, limit)
+	})
+	changes, _ := v.([]storage.Change)
+	return changes, err
+}
+
+func (s *Storage) CompanyFilings(ctx context.Context, crn string, asOf time.Time, page storage.Page) ([]storage.Filing, error) {
+	v, err := s.do(ctx, storage.CompanyFilingsKey(crn, asOf, page), func(ctx context.Context) (interface{}, error) {
+		return s.next.CompanyFilings(ctx, crn, asOf, page)
+	})
+	filings, _ := v.([]storage.Filing)
+	return filings, err
+}
+
+func (s *Storage) CompanyOfficers(ctx context.Context, crn string, asOf time.Time, page storage.Page) ([]storage.Officer, error) {
+	v, err := s.do(ctx, storage.CompanyOfficersKey(crn, asOf, page), func(ctx context.Context) (interface{}, error) {
+		return s.next.CompanyOfficers(ctx, crn, asOf, page)
+	})
+	officers, _ := v.([]storage.Officer)
+	return officers, err
+}
+
+func (s *Storage) SICHierarchy(ctx context.Context, code string) (*storage.SIC, error) {
+	v, err := s.do(ctx, storage.SICHierarchyKey(code), func(ctx context.Context) (interface{}, error) {
+		return s.next.SICHierarchy(ctx, code)
+	})
+	sic, _ := v.(*storage.SIC)
+	return sic, err
+}
@@ -0,0 +1,166 @@
+package coalesce
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// call is a query in flight shared by every caller asking for the same key
+type call struct {
+	done    chan struct{}
+	val     interface{}
+	err     error
+	waiters int
+	ctx     *callContext
+}
+
+// group runs a single call per key at a time. The call runs on a context
+// detached from the callers' ones: a caller giving up only stops waiting,
+// the call is cancelled once no caller is left waiting for it. The call's
+// deadline is the latest of its callers' ones so it gets as long as the
+// most patient caller is willing to wait, and none when any caller has none.
+// A caller doesn't join a call whose context is already done, the call is
+// bound to fail, it starts a fresh one instead.
+type group struct {
+	mu    sync.Mutex
+	calls map[string]*call
+}
+
+func (g *group) do(ctx context.Context, key string, fn func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*call)
+	}
+	c, ok := g.calls[key]
+	if !ok || c.ctx.Err() != nil {
+		c = &call{done: make(chan struct{}), ctx: newCallContext(ctx)}
+		g.calls[key] = c
+		go func() {
+			c.val, c.err = fn(c.ctx)
+			c.ctx.cancel(context.Canceled)
+			g.forget(key, c)
+			close(c.done)
+		}()
+	} else {
+		c.ctx.extend(ctx)
+	}
+	c.waiters++
+	g.mu.Unlock()
+
+	select {
+	case <-c.done:
+		return c.val, c.err
+	case <-ctx.Done():
+		g.mu.Lock()
+		c.waiters--
+		if c.waiters == 0 {
+			c.ctx.cancel(context.Canceled)
+			if g.calls[key] == c {
+				delete(g.calls, key)
+			}
+		}
+		g.mu.Unlock()
+		return nil, ctx.Err()
+	}
+}
+
+func (g *group) forget(key string, c *call) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.calls[key] == c {
+		delete(g.calls, key)
+	}
+}
+
+// callContext keeps the values of the first caller's context, e.g. logging
+// and auth data, but not its cancellation. Its deadline is extended to the
+// ones of the callers joining later, it's cancelled with
+// context.DeadlineExceeded once the latest one expires.
+type callContext struct {
+	parent context.Context
+	done   chan struct{}
+
+	mu       sync.Mutex
+	deadline time.Time
+	timer    *time.Timer
+	err      error
+}
+
+func newCallContext(ctx context.Context) *callContext {
+	c := &callContext{parent: ctx, done: make(chan struct{})}
+	if deadline, ok := ctx.Deadline(); ok {
+		// expire may run before the timer is assigned when the deadline
+		// already passed
+		c.mu.Lock()
+		c.deadline = deadline
+		c.timer = time.AfterFunc(time.Until(deadline), c.expire)
+		c.mu.Unlock()
+	}
+	return c
+}
+
+// extend moves the deadline to the one of ctx when it's later, a caller
+// without deadline removes it
+func (c *callContext) extend(ctx context.Context) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil || c.deadline.IsZero() {
+		return
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		c.deadline = time.Time{}
+		c.timer.Stop()
+		return
+	}
+	if deadline.After(c.deadline) {
+		c.deadline = deadline
+		c.timer.Reset(time.Until(deadline))
+	}
+}
+
+// expire cancels the context unless the deadline was extended meanwhile
+func (c *callContext) expire() {
+	c.mu.Lock()
+	if c.deadline.IsZero() {
+		c.mu.Unlock()
+		return
+	}
+	if left := time.Until(c.deadline); left > 0 {
+		c.timer.Reset(left)
+		c.mu.Unlock()
+		return
+	}
+	c.mu.Unlock()
+	c.cancel(context.DeadlineExceeded)
+}
+
+func (c *callContext) cancel(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return
+	}
+	c.err = err
+	if c.timer != nil {
+		c.timer.Stop()
+	}
+	close(c.done)
+}
+
+func (c *callContext) Deadline() (time.Time, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.deadline, !c.deadline.IsZero()
+}
+
+func (c *callContext) Done() <-chan struct{} { return c.done }
+
+func (c *callContext) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+func (c *callContext) Value(key interface{}) interface{} { return c.parent.Value(key) }
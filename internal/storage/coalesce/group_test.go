@@ -0,0 +1,218 @@
+package coalesce
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGroup_shared(t *testing.T) {
+	g := &group{}
+	var calls int32
+	release := make(chan struct{})
+	fn := func(ctx context.Context) (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return "result", nil
+	}
+	var wg sync.WaitGroup
+	results := make([]interface{}, 5)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], _ = g.do(context.Background(), "key", fn)
+		}(i)
+	}
+	waitForWaiters(t, g, "key", len(results))
+	close(release)
+	wg.Wait()
+	if calls != 1 {
+		t.Errorf("expected a single call, got %d", calls)
+	}
+	for i := range results {
+		if results[i] != "result" {
+			t.Errorf("unexpected result %v", results[i])
+		}
+	}
+}
+
+func TestGroup_cancelledCaller(t *testing.T) {
+	g := &group{}
+	release := make(chan struct{})
+	fn := func(ctx context.Context) (interface{}, error) {
+		select {
+		case <-release:
+			return "result", nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancelledErr := make(chan error)
+	go func() {
+		_, err := g.do(ctx, "key", fn)
+		cancelledErr <- err
+	}()
+	waitForWaiters(t, g, "key", 1)
+	other := make(chan interface{})
+	go func() {
+		v, _ := g.do(context.Background(), "key", fn)
+		other <- v
+	}()
+	waitForWaiters(t, g, "key", 2)
+
+	cancel()
+	if err := <-cancelledErr; err != context.Canceled {
+		t.Errorf("expected cancelled caller to get %v, got %v", context.Canceled, err)
+	}
+	close(release)
+	if v := <-other; v != "result" {
+		t.Errorf("expected other caller to get the result, got %v", v)
+	}
+}
+
+func TestGroup_allCallersCancelled(t *testing.T) {
+	g := &group{}
+	callErr := make(chan error, 1)
+	fn := func(ctx context.Context) (interface{}, error) {
+		<-ctx.Done()
+		callErr <- ctx.Err()
+		return nil, ctx.Err()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		_, _ = g.do(ctx, "key", fn)
+		close(done)
+	}()
+	waitForWaiters(t, g, "key", 1)
+	cancel()
+	<-done
+	select {
+	case err := <-callErr:
+		if err != context.Canceled {
+			t.Errorf("unexpected call error %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Error("call not cancelled once every caller gave up")
+	}
+}
+
+func TestGroup_joinAfterExpiry(t *testing.T) {
+	g := &group{}
+	// a call whose deadline passed while its query is still returning, its
+	// caller is about to give up
+	expired := &call{done: make(chan struct{}), ctx: newCallContext(context.Background()), waiters: 1}
+	expired.ctx.cancel(context.DeadlineExceeded)
+	g.calls = map[string]*call{"key": expired}
+
+	var calls int32
+	fn := func(ctx context.Context) (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		return "result", nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	v, err := g.do(ctx, "key", fn)
+	if err != nil || v != "result" {
+		t.Errorf("expected the caller joining after the expiry to get the result, got %v %v", v, err)
+	}
+	if calls != 1 {
+		t.Errorf("expected a fresh call, got %d calls", calls)
+	}
+	if expired.waiters != 1 {
+		t.Errorf("expected the expired call to be left to its caller, got %d waiters", expired.waiters)
+	}
+}
+
+func waitForWaiters(t *testing.T, g *group, key string, waiters int) {
+	t.Helper()
+	for i := 0; i < 1000; i++ {
+		g.mu.Lock()
+		c, ok := g.calls[key]
+		n := 0
+		if ok {
+			n = c.waiters
+		}
+		g.mu.Unlock()
+		if n == waiters {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %d waiters", waiters)
+}
+
+func TestGroup_latestDeadline(t *testing.T) {
+	g := &group{}
+	release := make(chan struct{})
+	deadlines := make(chan time.Time, 1)
+	fn := func(ctx context.Context) (interface{}, error) {
+		<-release
+		deadline, _ := ctx.Deadline()
+		deadlines <- deadline
+		return "result", nil
+	}
+	soon, cancelSoon := context.WithTimeout(context.Background(), time.Minute)
+	defer cancelSoon()
+	later, cancelLater := context.WithTimeout(context.Background(), time.Hour)
+	defer cancelLater()
+	var wg sync.WaitGroup
+	for i, ctx := range []context.Context{soon, later} {
+		wg.Add(1)
+		go func(ctx context.Context) {
+			defer wg.Done()
+			_, _ = g.do(ctx, "key", fn)
+		}(ctx)
+		waitForWaiters(t, g, "key", i+1)
+	}
+	close(release)
+	wg.Wait()
+	want, _ := later.Deadline()
+	if got := <-deadlines; !got.Equal(want) {
+		t.Errorf("expected the call's deadline to be the latest caller's %v, got %v", want, got)
+	}
+}
+
+func TestCallContext(t *testing.T) {
+	soon, cancelSoon := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancelSoon()
+
+	expiring := newCallContext(soon)
+	select {
+	case <-expiring.Done():
+		if err := expiring.Err(); err != context.DeadlineExceeded {
+			t.Errorf("expected %v, got %v", context.DeadlineExceeded, err)
+		}
+	case <-time.After(time.Second):
+		t.Error("call context not cancelled once its deadline expired")
+	}
+
+	again, cancelAgain := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancelAgain()
+	extended := newCallContext(again)
+	later, cancelLater := context.WithTimeout(context.Background(), time.Hour)
+	defer cancelLater()
+	extended.extend(later)
+	want, _ := later.Deadline()
+	if got, ok := extended.Deadline(); !ok || !got.Equal(want) {
+		t.Errorf("expected deadline %v, got %v", want, got)
+	}
+	unbounded := newCallContext(later)
+	unbounded.extend(context.Background())
+	if _, ok := unbounded.Deadline(); ok {
+		t.Error("expected no deadline once a caller without one joined")
+	}
+	time.Sleep(20 * time.Millisecond)
+	if err := extended.Err(); err != nil {
+		t.Errorf("expected the extended context to be alive, got %v", err)
+	}
+	extended.cancel(context.Canceled)
+	unbounded.cancel(context.Canceled)
+}
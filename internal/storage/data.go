@@ -1,6 +1,8 @@
 package storage
 
 import (
+	"time"
+
 	"github.com/jackc/pgtype"
 )
 
@@ -24,4 +26,110 @@ type Data struct {
 	DnBMaxCredit              pgtype.Float8 `db:"dnb_max_credit"`
 	DnBWageEstimate           pgtype.Float8 `db:"dnb_wage_estimate"`
 	DnBWhiteCollarEmployees   pgtype.Float8 `db:"dnb_white_collar_employees"`
+
+	CHCompanyStatus     pgtype.Text `db:"ch_company_status"`
+	CHCompanyType       pgtype.Text `db:"ch_company_type"`
+	CHIncorporationDate pgtype.Date `db:"ch_incorporation_date"`
+	CHDissolutionDate   pgtype.Date `db:"ch_dissolution_date"`
+
+	// only set when the data is retrieved as of a point in time
+	ValidFrom pgtype.Timestamptz `db:"valid_from"`
+	ValidTo   pgtype.Timestamptz `db:"valid_to"`
+}
+
+// Filing is a single entry of a company's Companies House filing history
+type Filing struct {
+	TransactionID pgtype.Text `db:"transaction_id"`
+	Date          pgtype.Date `db:"filing_date"`
+	Category      pgtype.Text `db:"category"`
+	Type          pgtype.Text `db:"type"`
+	Description   pgtype.Text `db:"description"`
+}
+
+// Officer is a director, secretary or other officer registered against a company
+type Officer struct {
+	Name        pgtype.Text `db:"name"`
+	Role        pgtype.Text `db:"role"`
+	AppointedOn pgtype.Date `db:"appointed_on"`
+	ResignedOn  pgtype.Date `db:"resigned_on"`
+	Nationality pgtype.Text `db:"nationality"`
+	Occupation  pgtype.Text `db:"occupation"`
+}
+
+// Version is the validity period of a snapshot of a company's data, ValidTo
+// is null for the current version
+type Version struct {
+	ValidFrom pgtype.Timestamptz `db:"valid_from"`
+	ValidTo   pgtype.Timestamptz `db:"valid_to"`
+}
+
+// Change records the last time a company's data was updated
+type Change struct {
+	CRN       pgtype.Text        `db:"crn"`
+	UpdatedAt pgtype.Timestamptz `db:"updated_at"`
+}
+
+// SIC is a UK SIC 2007 code along with the levels of the hierarchy it
+// belongs to
+type SIC struct {
+	Code                pgtype.Text `db:"code"`
+	Description         pgtype.Text `db:"description"`
+	SectionCode         pgtype.Text `db:"section_code"`
+	SectionDescription  pgtype.Text `db:"section_description"`
+	DivisionCode        pgtype.Text `db:"division_code"`
+	DivisionDescription pgtype.Text `db:"division_description"`
+	GroupCode           pgtype.Text `db:"group_code"`
+	GroupDescription    pgtype.Text `db:"group_description"`
+	ClassCode           pgtype.Text `db:"class_code"`
+	ClassDescription    pgtype.Text `db:"class_description"`
+}
+
+// GroupMetadata describes the latest load of a group's data
+type GroupMetadata struct {
+	Group    pgtype.Text        `db:"group_name"`
+	Source   pgtype.Text        `db:"source"`
+	LoadedAt pgtype.Timestamptz `db:"loaded_at"`
+}
+
+// Page limits the number of rows returned by list queries
+type Page struct {
+	Limit  int
+	Offset int
+}
+
+// Entitlement lists the groups, fields and layers a partner can request
+type Entitlement struct {
+	PartnerID pgtype.Text `db:"partner_id"`
+	Groups    []string    `db:"groups"`
+	Fields    []string    `db:"fields"`
+	Layers    []string    `db:"layers"`
+}
+
+// UsageEvent is a row of the usage_events table
+type UsageEvent struct {
+	PartnerID    string
+	Endpoint     string
+	ResourceType string
+	Resource     string
+	Lookups      int
+	OccurredAt   time.Time
+}
+
+// UsageSummary totals the lookups of a resource in a period
+type UsageSummary struct {
+	PeriodStart  pgtype.Timestamptz `db:"period_start"`
+	ResourceType pgtype.Text        `db:"resource_type"`
+	Resource     pgtype.Text        `db:"resource"`
+	Lookups      pgtype.Int8        `db:"lookups"`
+}
+
+// Layer is a geometry column registered in PostGIS' geometry_columns,
+// Indexed tells whether a spatial index covers it
+type Layer struct {
+	Schema  pgtype.Text `db:"schema"`
+	Table   pgtype.Text `db:"table"`
+	Column  pgtype.Text `db:"column"`
+	Type    pgtype.Text `db:"type"`
+	SRID    pgtype.Int4 `db:"srid"`
+	Indexed pgtype.Bool `db:"indexed"`
 }
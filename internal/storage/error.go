@@ -8,5 +8,8 @@ import (
 var (
 	ErrStorage       = errors.New("storage error")
 	ErrInvalidGroups = fmt.Errorf("%w invalid groups", ErrStorage)
+	ErrInvalidFields = fmt.Errorf("%w invalid fields", ErrStorage)
 	ErrNotFound      = fmt.Errorf("%w not found", ErrStorage)
+	ErrTimeout       = fmt.Errorf("%w timeout", ErrStorage)
+	ErrCancelled     = fmt.Errorf("%w cancelled", ErrStorage)
 )
@@ -0,0 +1,93 @@
+package storage
+
+// Groups of company data, a request always gets the base group
+const (
+	GroupBase           = "base"
+	GroupDnB            = "dnb"
+	GroupCompaniesHouse = "companies_house"
+	GroupOfficers       = "officers"
+
+	// FieldCRN is always returned whatever the fields requested
+	FieldCRN = "crn"
+	// FieldFilings and FieldOfficers are loaded from their own tables, they
+	// aren't columns of entries_crn
+	FieldFilings  = "filings"
+	FieldOfficers = "officers"
+)
+
+// groupsFields lists the fields each group is built from, it's the only
+// registry of the groups and fields that can be requested
+var groupsFields = map[string][]string{
+	GroupBase: {
+		FieldCRN,
+		"company_name",
+		"primary_trade",
+		"registered_address",
+	},
+	GroupDnB: {
+		"dnb_blue_collar_employees",
+		"dnb_delinquency_score",
+		"dnb_duns_number",
+		"dnb_employees",
+		"dnb_estimate_net_worth",
+		"dnb_estimate_sales",
+		"dnb_estimate_working_capital",
+		"dnb_failure_score",
+		"dnb_max_credit",
+		"dnb_wage_estimate",
+		"dnb_white_collar_employees",
+	},
+	GroupCompaniesHouse: {
+		"ch_company_status",
+		"ch_company_type",
+		"ch_incorporation_date",
+		"ch_dissolution_date",
+		FieldFilings,
+	},
+	GroupOfficers: {
+		FieldOfficers,
+	},
+}
+
+// IsColumn tells whether field is a column of entries_crn
+func IsColumn(field string) bool {
+	return field != FieldFilings && field != FieldOfficers
+}
+
+// IsGroup tells whether group can be requested
+func IsGroup(group string) bool {
+	_, ok := groupsFields[group]
+	return ok
+}
+
+// GroupFields returns the fields of group, nil when the group isn't known
+func GroupFields(group string) []string {
+	return groupsFields[group]
+}
+
+// ValidateGroups checks every group can be requested
+func ValidateGroups(groups []string) bool {
+	for i := range groups {
+		if !IsGroup(groups[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidateFields checks every field belongs to one of the given groups or to
+// the base group
+func ValidateFields(groups []string, fields []string) bool {
+	allowed := make(map[string]bool)
+	for _, group := range append([]string{GroupBase}, groups...) {
+		for _, field := range groupsFields[group] {
+			allowed[field] = true
+		}
+	}
+	for i := range fields {
+		if !allowed[fields[i]] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,49 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateFields(t *testing.T) {
+	tests := []struct {
+		name   string
+		groups []string
+		fields []string
+		want   bool
+	}{
+		{
+			name: "no fields",
+			want: true,
+		},
+		{
+			name:   "fields in groups",
+			groups: []string{GroupDnB},
+			fields: []string{"company_name", "dnb_employees"},
+			want:   true,
+		},
+		{
+			name:   "field of a group not requested",
+			groups: []string{GroupBase},
+			fields: []string{"dnb_employees"},
+			want:   false,
+		},
+		{
+			name:   "unknown field",
+			groups: []string{GroupBase},
+			fields: []string{"crn; drop table entries_crn"},
+			want:   false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, ValidateFields(tt.groups, tt.fields))
+		})
+	}
+}
+
+func TestValidateGroups(t *testing.T) {
+	assert.True(t, ValidateGroups([]string{GroupBase, GroupDnB, GroupCompaniesHouse, GroupOfficers}))
+	assert.False(t, ValidateGroups([]string{GroupDnB, "xxx"}))
+}
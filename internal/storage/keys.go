@@ -0,0 +1,54 @@
+package storage
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Keys identifying storage calls, two calls with the same key return the
+// same results
+
+func CompanyDataKey(crn string, groups []string, fields []string, asOf time.Time) string {
+	return "company:" + crn + ":" + sortedKey(groups) + ":" + sortedKey(fields) + asOfKey(asOf)
+}
+
+func CompanyVersionsKey(crn string) string {
+	return "versions:" + crn
+}
+
+func GroupsMetadataKey(groups []string, asOf time.Time) string {
+	return "metadata:" + sortedKey(groups) + asOfKey(asOf)
+}
+
+func CompanyChangesKey(since time.Time, afterCRN string, until time.Time, limit int) string {
+	return "changes:" + since.UTC().Format(time.RFC3339Nano) + ":" + afterCRN + ":" + until.UTC().Format(time.RFC3339Nano) + ":" + strconv.Itoa(limit)
+}
+
+func CompanyFilingsKey(crn string, asOf time.Time, page Page) string {
+	return "filings:" + crn + ":" + strconv.Itoa(page.Limit) + ":" + strconv.Itoa(page.Offset) + asOfKey(asOf)
+}
+
+func CompanyOfficersKey(crn string, asOf time.Time, page Page) string {
+	return "officers:" + crn + ":" + strconv.Itoa(page.Limit) + ":" + strconv.Itoa(page.Offset) + asOfKey(asOf)
+}
+
+func SICHierarchyKey(code string) string {
+	return "sic:" + code
+}
+
+// asOfKey is empty for the current data so its keys are unchanged
+func asOfKey(asOf time.Time) string {
+	if asOf.IsZero() {
+		return ""
+	}
+	return ":" + asOf.UTC().Format(time.RFC3339Nano)
+}
+
+// sortedKey joins a copy of values in a stable order
+func sortedKey(values []string) string {
+	sorted := append([]string(nil), values...)
+	sort.Strings(sorted)
+	return strings.Join(sorted, ",")
+}
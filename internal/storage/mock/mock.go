@@ -3,25 +3,110 @@ package mock
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/cytora/geospatial-lambda/internal/storage"
 )
 
 type StorageMock struct {
-	Results *storage.Data
-	Err     error
+	Results         *storage.Data
+	FilingsResults  []storage.Filing
+	OfficersResults []storage.Officer
+	VersionsResults []storage.Version
+	ChangesResults  []storage.Change
+	SICResults      *storage.SIC
+	MetadataResults []storage.GroupMetadata
+	SICErr          error
+	Err             error
 
 	IsCalled         bool
+	CalledWithCtx    context.Context
+	CompanyDataCalls int
+	MetadataCalls    int
 	CalledWithCRN    string
 	CalledWithGroups []string
+	CalledWithFields []string
+	CalledWithAsOf   time.Time
+	CalledWithPage   storage.Page
+	CalledWithSince  time.Time
+	CalledWithUntil  time.Time
+	CalledWithLimit  int
 }
 
-func (s *StorageMock) CompanyData(ctx context.Context, crn string, groups []string) (*storage.Data, error) {
+func (s *StorageMock) CompanyData(ctx context.Context, crn string, groups []string, fields []string, asOf time.Time) (*storage.Data, error) {
 	if s.Results == nil && s.Err == nil {
 		return nil, errors.New("mock not configured")
 	}
 	s.IsCalled = true
+	s.CompanyDataCalls++
+	s.CalledWithCtx = ctx
 	s.CalledWithCRN = crn
 	s.CalledWithGroups = groups
+	s.CalledWithFields = fields
+	s.CalledWithAsOf = asOf
 	return s.Results, s.Err
 }
+
+func (s *StorageMock) CompanyFilings(ctx context.Context, crn string, asOf time.Time, page storage.Page) ([]storage.Filing, error) {
+	s.CalledWithPage = page
+	s.CalledWithAsOf = asOf
+	return s.FilingsResults, s.Err
+}
+
+func (s *StorageMock) CompanyOfficers(ctx context.Context, crn string, asOf time.Time, page storage.Page) ([]storage.Officer, error) {
+	s.CalledWithPage = page
+	s.CalledWithAsOf = asOf
+	return s.OfficersResults, s.Err
+}
+
+func (s *StorageMock) CompanyVersions(ctx context.Context, crn string) ([]storage.Version, error) {
+	if s.VersionsResults == nil && s.Err == nil {
+		return nil, errors.New("mock not configured")
+	}
+	s.IsCalled = true
+	s.CalledWithCRN = crn
+	return s.VersionsResults, s.Err
+}
+
+func (s *StorageMock) CompanyChanges(ctx context.Context, since time.Time, afterCRN string, until time.Time, limit int) ([]storage.Change, error) {
+	s.IsCalled = true
+	s.CalledWithSince = since
+	s.CalledWithCRN = afterCRN
+	s.CalledWithUntil = until
+	s.CalledWithLimit = limit
+	if s.Err != nil {
+		return nil, s.Err
+	}
+	// like the database, only the changes in (since, afterCRN) - until are
+	// listed
+	changes := make([]storage.Change, 0, len(s.ChangesResults))
+	for _, c := range s.ChangesResults {
+		updatedAt := c.UpdatedAt.Time
+		after := updatedAt.After(since) || (updatedAt.Equal(since) && c.CRN.String > afterCRN)
+		if after && updatedAt.Before(until) && len(changes) < limit {
+			changes = append(changes, c)
+		}
+	}
+	return changes, nil
+}
+
+func (s *StorageMock) SICHierarchy(ctx context.Context, code string) (*storage.SIC, error) {
+	if s.SICResults == nil && s.SICErr == nil {
+		return nil, storage.ErrNotFound
+	}
+	return s.SICResults, s.SICErr
+}
+
+func (s *StorageMock) GroupsMetadata(ctx context.Context, groups []string, asOf time.Time) ([]storage.GroupMetadata, error) {
+	s.MetadataCalls++
+	var metadata []storage.GroupMetadata
+	for i := range s.MetadataResults {
+		for j := range groups {
+			if s.MetadataResults[i].Group.String == groups[j] {
+				metadata = append(metadata, s.MetadataResults[i])
+				break
+			}
+		}
+	}
+	return metadata, nil
+}
@@ -0,0 +1,167 @@
+package pg
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/rds/rdsutils"
+	"github.com/georgysavva/scany/pgxscan"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/cytora/geospatial-lambda/internal/config"
+	"github.com/cytora/geospatial-lambda/internal/metrics"
+	"github.com/cytora/geospatial-lambda/internal/tracing"
+	"github.com/cytora/go-platform-utils/logging"
+)
+
+const (
+	pingTimeout = 2 * time.Second
+)
+
+func awsCredentials(conf *config.Config) *credentials.Credentials {
+	sess := session.Must(session.NewSession(&aws.Config{
+		Region: aws.String(conf.AWSRegion),
+	}))
+	return sess.Config.Credentials
+}
+
+// connString builds the connection string of the configured auth mode,
+// the password is left out in iam mode as RDS IAM auth tokens expire after
+// 15 minutes: a fresh one is generated before opening each connection
+func connString(conf *config.Config) (string, error) {
+	if conf.DBAuthMode == config.DBAuthDSN {
+		return conf.DBDSN, nil
+	}
+	psqlUrl, err := url.Parse("postgres://")
+	if err != nil {
+		return "", err
+	}
+	psqlUrl.Host = conf.RDSProxyEndpoint
+	psqlUrl.User = url.User(conf.RDSProxyUser)
+	if conf.DBAuthMode == config.DBAuthPassword {
+		psqlUrl.User = url.UserPassword(conf.RDSProxyUser, conf.DBPassword)
+	}
+	psqlUrl.Path = conf.RDSDBName
+	q := psqlUrl.Query()
+	q.Add("sslmode", conf.DBSSLMode)
+	if conf.DBSSLRootCert != "" {
+		q.Add("sslrootcert", conf.DBSSLRootCert)
+	}
+	psqlUrl.RawQuery = q.Encode()
+	return psqlUrl.String(), nil
+}
+
+func (s *Storage) poolConfig() (*pgxpool.Config, error) {
+	connStr, err := connString(s.conf)
+	if err != nil {
+		return nil, err
+	}
+	poolConf, err := pgxpool.ParseConfig(connStr)
+	if err != nil {
+		return nil, err
+	}
+	if s.conf.DBAuthMode == config.DBAuthIAM {
+		poolConf.BeforeConnect = s.beforeConnect
+	}
+	poolConf.MaxConns = int32(s.conf.DBPoolMaxConns)
+	poolConf.MinConns = int32(s.conf.DBPoolMinConns)
+	poolConf.MaxConnLifetime = s.conf.DBPoolMaxConnLifetime
+	poolConf.MaxConnIdleTime = s.conf.DBPoolMaxConnIdleTime
+	poolConf.HealthCheckPeriod = s.conf.DBPoolHealthCheckPeriod
+	poolConf.ConnConfig.ConnectTimeout = s.conf.DBConnectTimeout
+	// set once per connection in the startup message, a SET per query would
+	// cost round trips and pin the connection to the client behind RDS Proxy
+	if ms := s.conf.DBStatementTimeout.Milliseconds(); ms > 0 {
+		poolConf.ConnConfig.RuntimeParams["statement_timeout"] = strconv.FormatInt(ms, 10)
+	}
+	return poolConf, nil
+}
+
+// run runs fn on the pool. pgx cancels the query when ctx is done, the
+// connections' statement_timeout bounds the queries without deadline.
+func (s *Storage) run(ctx context.Context, fn func(ctx context.Context, db pgxscan.Querier) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return fn(ctx, s.getPool())
+}
+
+func (s *Storage) beforeConnect(ctx context.Context, connConf *pgx.ConnConfig) error {
+	ts := time.Now()
+	token, err := rdsutils.BuildAuthToken(s.conf.RDSProxyEndpoint, s.conf.AWSRegion, s.conf.RDSProxyUser, s.creds)
+	if err != nil {
+		logging.Error(ctx, err, logging.Data{"proxy": s.conf.RDSProxyEndpoint}, "failed to build auth token")
+		return err
+	}
+	connConf.Password = token
+	logging.Info(ctx, logging.Data{"proxy": s.conf.RDSProxyEndpoint, "auth_time": time.Since(ts)}, "auth token built")
+	return nil
+}
+
+func (s *Storage) connect(ctx context.Context) (*pgxpool.Pool, error) {
+	poolConf, err := s.poolConfig()
+	if err != nil {
+		return nil, err
+	}
+	cts := time.Now()
+	pool, err := pgxpool.ConnectConfig(ctx, poolConf)
+	if err != nil {
+		return nil, err
+	}
+	logging.Info(ctx, logging.Data{
+		"proxy":           poolConf.ConnConfig.Host,
+		"auth_mode":       s.conf.DBAuthMode,
+		"connection_time": time.Since(cts),
+		"max_conns":       poolConf.MaxConns,
+		"min_conns":       poolConf.MinConns,
+	}, "connection stats")
+	return pool, nil
+}
+
+func (s *Storage) getPool() *pgxpool.Pool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.pool
+}
+
+// reconnect replaces the pool when it can't reach the database anymore.
+// Queries running on the old pool are let finish before it's closed.
+func (s *Storage) reconnect(ctx context.Context) (err error) {
+	ctx, span := tracing.Start(ctx, "pg.reconnect")
+	defer func() { tracing.End(span, err) }()
+	s.reconnecting.Lock()
+	defer s.reconnecting.Unlock()
+	ctx, cancel := context.WithTimeout(ctx, pingTimeout)
+	defer cancel()
+	// another caller may have already replaced the pool
+	if err := s.getPool().Ping(ctx); err == nil {
+		span.SetAttributes(attribute.Bool("pg.reconnected_by_other", true))
+		return nil
+	}
+	logging.Info(ctx, nil, "reconnecting")
+	pool, err := s.connect(ctx)
+	if err != nil {
+		s.opts.metrics.Emit(ctx, metrics.Reconnects, 1, metrics.UnitCount, metrics.Dimensions{"Outcome": "failure"})
+		return err
+	}
+	s.opts.metrics.Emit(ctx, metrics.Reconnects, 1, metrics.UnitCount, metrics.Dimensions{"Outcome": "success"})
+	s.mu.Lock()
+	old := s.pool
+	s.pool = pool
+	s.mu.Unlock()
+	// Close waits for every acquired connection to be released
+	go old.Close()
+	return nil
+}
+
+// Close closes every connection of the pool
+func (s *Storage) Close() {
+	s.getPool().Close()
+}
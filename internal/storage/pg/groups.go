@@ -3,31 +3,11 @@ package pg
 import (
 	"fmt"
 	"strings"
+
+	"github.com/cytora/geospatial-lambda/internal/storage"
 )
 
 const (
-	baseGroup = "base"
-	dnbGroup  = "dnb"
-
-	baseGroupFields = `
-	"crn",
-	"company_name",
-	"primary_trade",
-	"registered_address"`
-
-	dnbGroupFields = `
-	"dnb_blue_collar_employees",
-	"dnb_delinquency_score",
-	"dnb_duns_number",
-	"dnb_employees",
-	"dnb_estimate_net_worth",
-    "dnb_estimate_sales",
-	"dnb_estimate_working_capital",
-	"dnb_failure_score",
-	"dnb_max_credit",
-	"dnb_wage_estimate",
-	"dnb_white_collar_employees"`
-
 	/*
 		"dnb_risk_indicator",
 		"dnb_sic_code",
@@ -35,37 +15,131 @@ const (
 	*/
 
 	retrieveQuery = `select %s from entries_crn where crn=$1`
-)
 
-var (
-	groupsFields = map[string]string{
-		baseGroup: baseGroupFields,
-		dnbGroup:  dnbGroupFields,
-	}
+	// snapshots hold every version of an entries_crn row, valid_to is null
+	// for the version currently live
+	retrieveAsOfQuery = `
+	select %s, "valid_from", "valid_to"
+	from entries_crn_snapshots
+	where crn=$1 and "valid_from" <= $2 and ("valid_to" is null or "valid_to" > $2)`
+
+	versionsQuery = `
+	select "valid_from", "valid_to"
+	from entries_crn_snapshots
+	where crn=$1
+	order by "valid_from" desc`
+
+	changesQuery = `
+	select "crn", "updated_at"
+	from entries_crn
+	where ("updated_at", "crn") > ($1, $2) and "updated_at" < $3
+	order by "updated_at", "crn"
+	limit $4`
+
+	groupsMetadataQuery = `
+	select distinct on ("group_name") "group_name", "source", "loaded_at"
+	from data_loads
+	where "group_name" = any($1)
+	order by "group_name", "loaded_at" desc`
+
+	// groupsMetadataAsOfQuery returns the latest loads by $2
+	groupsMetadataAsOfQuery = `
+	select distinct on ("group_name") "group_name", "source", "loaded_at"
+	from data_loads
+	where "group_name" = any($1) and "loaded_at" <= $2
+	order by "group_name", "loaded_at" desc`
+
+	sicQuery = `
+	select "code", "description",
+		"section_code", "section_description",
+		"division_code", "division_description",
+		"group_code", "group_description",
+		"class_code", "class_description"
+	from sic_2007
+	where code=$1`
+
+	filingsQuery = `
+	select "transaction_id", "filing_date", "category", "type", "description"
+	from companies_house_filings
+	where crn=$1
+	order by "filing_date" desc, "transaction_id"
+	limit $2 offset $3`
+
+	// filingsAsOfQuery leaves out the filings made after $4
+	filingsAsOfQuery = `
+	select "transaction_id", "filing_date", "category", "type", "description"
+	from companies_house_filings
+	where crn=$1 and "filing_date" <= $4
+	order by "filing_date" desc, "transaction_id"
+	limit $2 offset $3`
+
+	officersQuery = `
+	select "name", "role", "appointed_on", "resigned_on", "nationality", "occupation"
+	from companies_house_officers
+	where crn=$1
+	order by "resigned_on" desc nulls first, "appointed_on" desc, "name"
+	limit $2 offset $3`
+
+	// officersAsOfQuery returns the officers appointed by $4, the ones who
+	// resigned later were still in office then
+	officersAsOfQuery = `
+	select "name", "role", "appointed_on",
+		case when "resigned_on" <= $4 then "resigned_on" end as "resigned_on",
+		"nationality", "occupation"
+	from companies_house_officers
+	where crn=$1 and "appointed_on" <= $4
+	order by 4 desc nulls first, "appointed_on" desc, "name"
+	limit $2 offset $3`
+
+	entitlementQuery = `
+	select "partner_id",
+		coalesce("groups", '{}') as "groups",
+		coalesce("fields", '{}') as "fields",
+		coalesce("layers", '{}') as "layers"
+	from partner_entitlements
+	where partner_id=$1`
+
+	usageSummaryQuery = `
+	select date_trunc($2, "occurred_at" at time zone 'UTC') at time zone 'UTC' as "period_start", "resource_type", "resource", sum("lookups") as "lookups"
+	from usage_events
+	where partner_id=$1 and "occurred_at" >= $3 and "occurred_at" < $4
+	group by 1, 2, 3
+	order by 1, 2, 3`
 )
 
-func validateGroups(groups []string) bool {
-	for i := range groups {
-		group := groups[i]
-		if _, ok := groupsFields[group]; !ok {
-			return false
+var usageEventsColumns = []string{"partner_id", "endpoint", "resource_type", "resource", "lookups", "occurred_at"}
+
+// generateQuery fills query with every column of the given groups, or only the
+// given fields when any is provided. crn is always selected.
+func generateQuery(query string, groups []string, fields []string) string {
+	selected := []string{storage.FieldCRN}
+	seen := map[string]bool{storage.FieldCRN: true}
+	add := func(field string) {
+		if seen[field] || !storage.IsColumn(field) {
+			return
+		}
+		seen[field] = true
+		selected = append(selected, field)
+	}
+	if len(fields) > 0 {
+		for i := range fields {
+			add(fields[i])
+		}
+	} else {
+		for i := range groups {
+			for _, field := range storage.GroupFields(groups[i]) {
+				add(field)
+			}
 		}
 	}
-	return true
-}
-
-func generateQuery(groups []string) string {
 	b := strings.Builder{}
-	separator := ""
-	for i := range groups {
-		group := groups[i]
-		groupFields, ok := groupsFields[group]
-		if !ok {
-			continue
+	for i := range selected {
+		if i > 0 {
+			b.WriteString(",")
 		}
-		b.WriteString(separator)
-		b.WriteString(groupFields)
-		separator = ","
+		b.WriteString(`"`)
+		b.WriteString(selected[i])
+		b.WriteString(`"`)
 	}
-	return fmt.Sprintf(retrieveQuery, b.String())
+	return fmt.Sprintf(query, b.String())
 }
@@ -0,0 +1,47 @@
+package pg
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cytora/geospatial-lambda/internal/storage"
+)
+
+func Test_generateQuery(t *testing.T) {
+	tests := []struct {
+		name   string
+		groups []string
+		fields []string
+		want   string
+	}{
+		{
+			name:   "base group",
+			groups: []string{storage.GroupBase},
+			want:   `"crn","company_name","primary_trade","registered_address"`,
+		},
+		{
+			name:   "officers have no columns",
+			groups: []string{storage.GroupOfficers, storage.GroupBase},
+			want:   `"crn","company_name","primary_trade","registered_address"`,
+		},
+		{
+			name:   "filings aren't a column",
+			groups: []string{storage.GroupCompaniesHouse, storage.GroupBase},
+			fields: []string{"filings", "ch_company_status"},
+			want:   `"crn","ch_company_status"`,
+		},
+		{
+			name:   "projection keeps crn",
+			groups: []string{storage.GroupDnB, storage.GroupBase},
+			fields: []string{"dnb_employees", "dnb_max_credit", "dnb_employees"},
+			want:   `"crn","dnb_employees","dnb_max_credit"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, fmt.Sprintf(retrieveQuery, tt.want), generateQuery(retrieveQuery, tt.groups, tt.fields))
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package pg
+
+import (
+	"context"
+
+	"github.com/georgysavva/scany/pgxscan"
+
+	"github.com/cytora/geospatial-lambda/internal/storage"
+	"github.com/cytora/go-platform-utils/logging"
+)
+
+const (
+	pingQuery = `select 1`
+
+	postgisVersionQuery = `select postgis_lib_version()`
+
+	// a layer is indexed when a gist, spgist or brin index covers its
+	// geometry column
+	layersQuery = `
+	select
+		g."f_table_schema" as "schema",
+		g."f_table_name" as "table",
+		g."f_geometry_column" as "column",
+		g."type" as "type",
+		g."srid" as "srid",
+		exists (
+			select 1
+			from pg_index i
+			join pg_class c on c.oid = i.indexrelid
+			join pg_am am on am.oid = c.relam
+			join pg_attribute a on a.attrelid = i.indrelid and a.attnum = any(i.indkey)
+			where i.indrelid = format('%I.%I', g."f_table_schema", g."f_table_name")::regclass
+				and a.attname = g."f_geometry_column"
+				and am.amname in ('gist', 'spgist', 'brin')
+		) as "indexed"
+	from geometry_columns g
+	order by g."f_table_schema", g."f_table_name", g."f_geometry_column"`
+)
+
+// The health checks aren't retried, a failing dependency is reported as
+// soon as it's seen.
+
+// Ping runs a trivial query on a connection of the pool
+func (s *Storage) Ping(ctx context.Context) error {
+	var one int
+	if err := s.getPool().QueryRow(ctx, pingQuery).Scan(&one); err != nil {
+		logging.Error(ctx, err, nil, "ping error")
+		return queryError(err)
+	}
+	return nil
+}
+
+func (s *Storage) PostGISVersion(ctx context.Context) (string, error) {
+	var version string
+	if err := s.getPool().QueryRow(ctx, postgisVersionQuery).Scan(&version); err != nil {
+		logging.Error(ctx, err, nil, "postgis version query error")
+		return "", queryError(err)
+	}
+	return version, nil
+}
+
+// Layers lists the geometry columns registered in PostGIS
+func (s *Storage) Layers(ctx context.Context) ([]storage.Layer, error) {
+	var layers []storage.Layer
+	if err := pgxscan.Select(ctx, s.getPool(), &layers, layersQuery); err != nil {
+		logging.Error(ctx, err, nil, "layers query error")
+		return nil, queryError(err)
+	}
+	return layers, nil
+}
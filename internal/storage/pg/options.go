@@ -0,0 +1,23 @@
+package pg
+
+import "github.com/cytora/geospatial-lambda/internal/metrics"
+
+type OptionFunc func(opt *Options)
+
+type Options struct {
+	metrics metrics.Emitter
+}
+
+func defaultOptions() *Options {
+	return &Options{
+		metrics: metrics.Nop{},
+	}
+}
+
+// WithMetrics emits the query times, errors, retries, reconnects and pool
+// usage to emitter
+func WithMetrics(emitter metrics.Emitter) OptionFunc {
+	return func(opt *Options) {
+		opt.metrics = emitter
+	}
+}
@@ -0,0 +1,239 @@
+package pg
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"syscall"
+	"time"
+
+	backoff "github.com/cenkalti/backoff/v4"
+	"github.com/georgysavva/scany/pgxscan"
+	"github.com/jackc/pgconn"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/cytora/geospatial-lambda/internal/config"
+	"github.com/cytora/geospatial-lambda/internal/metrics"
+	"github.com/cytora/geospatial-lambda/internal/storage"
+	"github.com/cytora/geospatial-lambda/internal/tracing"
+	"github.com/cytora/go-platform-utils/logging"
+)
+
+// postgres error codes worth retrying, see
+// https://www.postgresql.org/docs/current/errcodes-appendix.html
+var retryableCodes = map[string]bool{
+	"40001": true, // serialization_failure
+	"40P01": true, // deadlock_detected
+	"53300": true, // too_many_connections
+	"57P01": true, // admin_shutdown
+	"57P02": true, // crash_shutdown
+	"57P03": true, // cannot_connect_now
+}
+
+// retryPolicy retries storage operations failing with transient errors
+// using an exponential backoff
+type retryPolicy struct {
+	initialInterval time.Duration
+	maxInterval     time.Duration
+	maxElapsedTime  time.Duration
+	maxAttempts     uint64
+}
+
+func newRetryPolicy(conf *config.Config) *retryPolicy {
+	return &retryPolicy{
+		initialInterval: conf.DBRetryInitialInterval,
+		maxInterval:     conf.DBRetryMaxInterval,
+		maxElapsedTime:  conf.DBRetryMaxElapsedTime,
+		maxAttempts:     uint64(conf.DBRetryMaxAttempts),
+	}
+}
+
+func (p *retryPolicy) backOff(ctx context.Context) backoff.BackOff {
+	bo := backoff.NewExponentialBackOff()
+	bo.InitialInterval = p.initialInterval
+	bo.MaxInterval = p.maxInterval
+	bo.MaxElapsedTime = p.maxElapsedTime
+	var b backoff.BackOff = &deadlineBackOff{BackOff: bo, ctx: ctx}
+	if p.maxAttempts > 0 {
+		b = backoff.WithMaxRetries(b, p.maxAttempts-1)
+	}
+	return backoff.WithContext(b, ctx)
+}
+
+// deadlineBackOff stops retrying when the context's deadline would expire
+// before the next attempt
+type deadlineBackOff struct {
+	backoff.BackOff
+	ctx context.Context
+}
+
+func (b *deadlineBackOff) NextBackOff() time.Duration {
+	next := b.BackOff.NextBackOff()
+	if deadline, ok := b.ctx.Deadline(); ok && next != backoff.Stop && time.Until(deadline) < next {
+		return backoff.Stop
+	}
+	return next
+}
+
+// isConnectionError tells whether err means the connection to the database
+// is broken, the pool needs to be checked before retrying
+func isConnectionError(err error) bool {
+	if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		// connection_exception class
+		return len(pgErr.Code) == 5 && pgErr.Code[:2] == "08"
+	}
+	return false
+}
+
+// isTimeout tells whether err is caused by the caller's deadline or the
+// connection's statement_timeout
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var pgErr *pgconn.PgError
+	// query_canceled
+	return errors.As(err, &pgErr) && pgErr.Code == "57014"
+}
+
+// queryError maps a failed query to the storage error returned to callers
+func queryError(err error) error {
+	switch {
+	case isTimeout(err):
+		return storage.ErrTimeout
+	case errors.Is(err, context.Canceled):
+		return storage.ErrCancelled
+	default:
+		return storage.ErrStorage
+	}
+}
+
+// errorType classifies the errors of the queries for the metrics
+func errorType(err error) string {
+	switch {
+	case isTimeout(err):
+		return "timeout"
+	case isConnectionError(err):
+		return "connection"
+	case isRetryable(err):
+		return "transient"
+	default:
+		return "query"
+	}
+}
+
+// isRetryable tells whether an operation failing with err can succeed if
+// attempted again
+func isRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if isConnectionError(err) || pgconn.SafeToRetry(err) {
+		return true
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return retryableCodes[pgErr.Code]
+	}
+	// timeouts of the connection to the proxy
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return false
+}
+
+// query names the operation run by withRetry in the logs and metrics, its
+// span carries the statement sanitized and the attributes. dimensions are
+// added to the operation's ones on the query time.
+type query struct {
+	operation  string
+	statement  string
+	attributes []attribute.KeyValue
+	dimensions metrics.Dimensions
+}
+
+// withRetry runs fn until it succeeds, fails with an error that can't be
+// retried or the policy gives up. The last error is returned.
+func (s *Storage) withRetry(ctx context.Context, q query, fn func(ctx context.Context, db pgxscan.Querier) error) (err error) {
+	operation := q.operation
+	ctx, span := tracing.Start(ctx, "pg."+operation, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		semconv.DBSystemPostgreSQL,
+		semconv.DBOperationKey.String(operation),
+		semconv.DBStatementKey.String(tracing.SanitizeSQL(q.statement)),
+	), trace.WithAttributes(q.attributes...))
+	defer func() {
+		if pgxscan.NotFound(err) {
+			tracing.End(span, nil)
+			return
+		}
+		tracing.End(span, err)
+	}()
+	attempt := 0
+	op := func() error {
+		attempt++
+		err := s.run(ctx, fn)
+		if err == nil {
+			return nil
+		}
+		if !isRetryable(err) {
+			return backoff.Permanent(err)
+		}
+		if isConnectionError(err) {
+			if err := s.reconnect(ctx); err != nil {
+				logging.Error(ctx, err, logging.Data{"operation": operation}, "failed to reconnect")
+			}
+		}
+		return err
+	}
+	notify := func(err error, next time.Duration) {
+		s.opts.metrics.Emit(ctx, metrics.QueryRetries, 1, metrics.UnitCount, metrics.Dimensions{"Operation": operation})
+		span.AddEvent("retry", trace.WithAttributes(
+			attribute.Int("attempt", attempt),
+			attribute.String("next_attempt_in", next.String()),
+			attribute.String("error", err.Error()),
+		))
+		logging.Info(ctx, logging.Data{"operation": operation, "attempt": attempt, "next_attempt_in": next, "error": err.Error()}, "retrying query")
+	}
+	ts := time.Now()
+	err = backoff.RetryNotify(op, s.retry.backOff(ctx), notify)
+	var permanent *backoff.PermanentError
+	if errors.As(err, &permanent) {
+		err = permanent.Err
+	}
+	s.emitQueryMetrics(ctx, q, time.Since(ts), err)
+	return err
+}
+
+// emitQueryMetrics emits the time of an operation, its error if any and
+// how busy the pool is once it's done. Rows not found and queries cancelled
+// as the caller went away aren't errors.
+func (s *Storage) emitQueryMetrics(ctx context.Context, q query, queryTime time.Duration, err error) {
+	operation := q.operation
+	dims := metrics.Dimensions{"Operation": operation}
+	for name, value := range q.dimensions {
+		dims[name] = value
+	}
+	s.opts.metrics.Emit(ctx, metrics.QueryTime, metrics.Milliseconds(queryTime), metrics.UnitMilliseconds, dims)
+	if err != nil && !pgxscan.NotFound(err) && !errors.Is(err, context.Canceled) {
+		s.opts.metrics.Emit(ctx, metrics.QueryErrors, 1, metrics.UnitCount, metrics.Dimensions{"Operation": operation, "ErrorType": errorType(err)})
+	}
+	pool := s.getPool()
+	if pool == nil {
+		return
+	}
+	stat := pool.Stat()
+	s.opts.metrics.Emit(ctx, metrics.PoolAcquiredConns, float64(stat.AcquiredConns()), metrics.UnitCount, nil)
+	if stat.MaxConns() > 0 {
+		s.opts.metrics.Emit(ctx, metrics.PoolSaturation, 100*float64(stat.AcquiredConns())/float64(stat.MaxConns()), metrics.UnitPercent, nil)
+	}
+}
@@ -0,0 +1,166 @@
+package pg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/georgysavva/scany/pgxscan"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+
+	"github.com/cytora/geospatial-lambda/internal/config"
+	"github.com/cytora/geospatial-lambda/internal/metrics"
+	"github.com/cytora/geospatial-lambda/internal/storage"
+)
+
+func Test_isRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "unexpected eof", err: fmt.Errorf("read: %w", io.ErrUnexpectedEOF), want: true},
+		{name: "connection reset", err: fmt.Errorf("read: %w", syscall.ECONNRESET), want: true},
+		{name: "connection exception", err: &pgconn.PgError{Code: "08006"}, want: true},
+		{name: "serialization failure", err: &pgconn.PgError{Code: "40001"}, want: true},
+		{name: "admin shutdown", err: &pgconn.PgError{Code: "57P01"}, want: true},
+		{name: "syntax error", err: &pgconn.PgError{Code: "42601"}, want: false},
+		{name: "not found", err: pgx.ErrNoRows, want: false},
+		{name: "cancelled", err: context.Canceled, want: false},
+		{name: "deadline", err: fmt.Errorf("query: %w", context.DeadlineExceeded), want: false},
+		{name: "unknown", err: errors.New("oops"), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, isRetryable(tt.err))
+		})
+	}
+}
+
+func TestStorage_withRetry(t *testing.T) {
+	policy := &retryPolicy{
+		initialInterval: time.Millisecond,
+		maxInterval:     time.Millisecond,
+		maxElapsedTime:  time.Second,
+		maxAttempts:     3,
+	}
+	tests := []struct {
+		name             string
+		errs             []error
+		expectedErr      error
+		expectedAttempts int
+	}{
+		{
+			name:             "success",
+			errs:             []error{nil},
+			expectedAttempts: 1,
+		},
+		{
+			name:             "retried",
+			errs:             []error{&pgconn.PgError{Code: "40001"}, nil},
+			expectedAttempts: 2,
+		},
+		{
+			name:             "not retryable",
+			errs:             []error{pgx.ErrNoRows},
+			expectedErr:      pgx.ErrNoRows,
+			expectedAttempts: 1,
+		},
+		{
+			name:             "max attempts",
+			errs:             []error{&pgconn.PgError{Code: "40001"}, &pgconn.PgError{Code: "40001"}, &pgconn.PgError{Code: "40P01"}, nil},
+			expectedErr:      &pgconn.PgError{Code: "40P01"},
+			expectedAttempts: 3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := tracetest.NewSpanRecorder()
+			otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec)))
+			s := &Storage{conf: &config.Config{}, retry: policy, opts: defaultOptions()}
+			attempts := 0
+			err := s.withRetry(context.Background(), query{operation: "test", statement: "select 1 where crn = '00111222'"}, func(ctx context.Context, db pgxscan.Querier) error {
+				err := tt.errs[attempts]
+				attempts++
+				return err
+			})
+			assert.Equal(t, tt.expectedErr, err, "unexpected error")
+			assert.Equal(t, tt.expectedAttempts, attempts, "unexpected attempts")
+			if tt.expectedErr == pgx.ErrNoRows {
+				assert.True(t, pgxscan.NotFound(err), "not found should be preserved")
+			}
+			spans := rec.Ended()
+			if assert.Len(t, spans, 1, "expected a span per query") {
+				span := spans[0]
+				assert.Equal(t, "pg.test", span.Name(), "unexpected span name")
+				assert.Contains(t, span.Attributes(), semconv.DBStatementKey.String("select ? where crn = ?"), "expected the statement sanitized")
+				retries := 0
+				for _, event := range span.Events() {
+					if event.Name == "retry" {
+						retries++
+					}
+				}
+				assert.Equal(t, tt.expectedAttempts-1, retries, "expected an event per retry")
+				wantStatus := codes.Unset
+				if tt.expectedErr != nil && tt.expectedErr != pgx.ErrNoRows {
+					wantStatus = codes.Error
+				}
+				assert.Equal(t, wantStatus, span.Status().Code, "unexpected span status")
+			}
+		})
+	}
+}
+
+func TestStorage_withRetry_metrics(t *testing.T) {
+	policy := &retryPolicy{initialInterval: time.Millisecond, maxInterval: time.Millisecond, maxElapsedTime: time.Second, maxAttempts: 3}
+	reg := metrics.NewRegistry()
+	opts := defaultOptions()
+	WithMetrics(reg)(opts)
+	s := &Storage{conf: &config.Config{}, retry: policy, opts: opts}
+	q := query{operation: "company_data", statement: "select 1", dimensions: metrics.Dimensions{"Groups": "base,dnb"}}
+	errs := []error{&pgconn.PgError{Code: "40001"}, nil}
+	attempts := 0
+	err := s.withRetry(context.Background(), q, func(ctx context.Context, db pgxscan.Querier) error {
+		err := errs[attempts]
+		attempts++
+		return err
+	})
+	assert.NoError(t, err)
+
+	queryTime, ok := reg.Get(metrics.QueryTime, metrics.Dimensions{"Operation": "company_data", "Groups": "base,dnb"})
+	assert.True(t, ok, "expected the query time by operation and groups")
+	assert.Equal(t, 1, queryTime.Count, "expected a single query time for the retried operation")
+	retries, _ := reg.Get(metrics.QueryRetries, metrics.Dimensions{"Operation": "company_data"})
+	assert.Equal(t, 1, retries.Count, "unexpected retries")
+	_, ok = reg.Get(metrics.QueryErrors, metrics.Dimensions{"Operation": "company_data", "ErrorType": "serialization_failure"})
+	assert.False(t, ok, "the retried error shouldn't be counted")
+}
+
+func Test_queryError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{name: "deadline", err: fmt.Errorf("query: %w", context.DeadlineExceeded), want: storage.ErrTimeout},
+		{name: "statement timeout", err: &pgconn.PgError{Code: "57014"}, want: storage.ErrTimeout},
+		{name: "cancelled", err: context.Canceled, want: storage.ErrCancelled},
+		{name: "syntax error", err: &pgconn.PgError{Code: "42601"}, want: storage.ErrStorage},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, queryError(tt.err))
+		})
+	}
+}
@@ -2,113 +2,259 @@ package pg
 
 import (
 	"context"
-	"errors"
-	"io"
-	"net/url"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/rds/rdsutils"
-	backoff "github.com/cenkalti/backoff/v4"
+	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/georgysavva/scany/pgxscan"
+	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/cytora/geospatial-lambda/internal/config"
+	"github.com/cytora/geospatial-lambda/internal/metrics"
 	"github.com/cytora/geospatial-lambda/internal/storage"
+	"github.com/cytora/geospatial-lambda/internal/tracing"
 	"github.com/cytora/go-platform-utils/logging"
 )
 
 type Storage struct {
-	pool *pgxpool.Pool
-	conf *config.Config
+	conf  *config.Config
+	creds *credentials.Credentials
+	retry *retryPolicy
+	opts  *Options
+
+	// mu guards pool, reconnecting serialises the reconnections
+	mu           sync.RWMutex
+	pool         *pgxpool.Pool
+	reconnecting sync.Mutex
 }
 
-func connect(conf *config.Config) (*pgxpool.Pool, error) {
-	ats := time.Now()
-	sess := session.Must(session.NewSession(&aws.Config{
-		Region: aws.String(conf.AWSRegion),
-	}))
-	token, err := rdsutils.BuildAuthToken(conf.RDSProxyEndpoint, conf.AWSRegion, conf.RDSProxyUser, sess.Config.Credentials)
-	if err != nil {
-		logging.FatalNoCtx(err, logging.Data{"version": conf.Version}, "failed to retrieve token")
+func New(conf *config.Config, opts ...OptionFunc) (*Storage, error) {
+	opt := defaultOptions()
+	for _, f := range opts {
+		f(opt)
 	}
-	psqlUrl, err := url.Parse("postgres://")
-	if err != nil {
-		logging.FatalNoCtx(err, logging.Data{"version": conf.Version}, "failed to parse url")
-	}
-	psqlUrl.Host = conf.RDSProxyEndpoint
-	psqlUrl.User = url.UserPassword(conf.RDSProxyUser, token)
-	psqlUrl.Path = conf.RDSDBName
-	q := psqlUrl.Query()
-	q.Add("sslmode", "require")
-	psqlUrl.RawQuery = q.Encode()
-	cts := time.Now()
-	pool, err := pgxpool.Connect(context.Background(), psqlUrl.String())
+	s := &Storage{
+		conf:  conf,
+		retry: newRetryPolicy(conf),
+		opts:  opt,
+	}
+	if conf.DBAuthMode == config.DBAuthIAM {
+		s.creds = awsCredentials(conf)
+	}
+	pool, err := s.connect(context.Background())
 	if err != nil {
 		return nil, err
 	}
-	logging.Info(context.Background(), logging.Data{"proxy": conf.RDSProxyEndpoint, "connection_time": time.Since(cts), "auth_time": time.Since(ats)}, "connection stats")
-	return pool, nil
+	s.pool = pool
+	return s, nil
 }
 
-func New(conf *config.Config) (*Storage, error) {
-	pool, err := connect(conf)
-	return &Storage{
-		conf: conf,
-		pool: pool,
-	}, err
+func (s *Storage) CompanyData(ctx context.Context, crn string, groups []string, fields []string, asOf time.Time) (*storage.Data, error) {
+	if !storage.ValidateGroups(groups) {
+		return nil, storage.ErrInvalidGroups
+	}
+	if !storage.ValidateFields(groups, fields) {
+		return nil, storage.ErrInvalidFields
+	}
+	groups = append([]string{storage.GroupBase}, groups...)
+	// in a stable order, the query time is broken down by the groups requested
+	sorted := append([]string(nil), groups...)
+	sort.Strings(sorted)
+	joined := strings.Join(sorted, ",")
+	q := query{
+		operation: "company_data",
+		statement: generateQuery(retrieveQuery, groups, fields),
+		attributes: []attribute.KeyValue{
+			attribute.String("geospatial.groups", joined),
+			attribute.Int("geospatial.fields", len(fields)),
+		},
+		dimensions: metrics.Dimensions{"Groups": joined},
+	}
+	args := []interface{}{crn}
+	if !asOf.IsZero() {
+		q.statement = generateQuery(retrieveAsOfQuery, groups, fields)
+		args = append(args, asOf)
+	}
+	ts := time.Now()
+	data := &storage.Data{}
+	err := s.withRetry(ctx, q, func(ctx context.Context, db pgxscan.Querier) error {
+		return pgxscan.Get(ctx, db, data, q.statement, args...)
+	})
+	if err != nil {
+		if pgxscan.NotFound(err) {
+			return nil, storage.ErrNotFound
+		}
+		logging.Error(ctx, err, logging.Data{"crn": crn}, "query error")
+		return nil, queryError(err)
+	}
+	logging.Info(ctx, logging.Data{"crn": crn, "groups": groups, "fields": fields, "as_of": asOf, "query_time": time.Since(ts)}, "query stats")
+	return data, nil
 }
 
-func (s *Storage) reconnect(ctx context.Context) error {
-	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
-	defer cancel()
-	if err := s.pool.Ping(ctx); err == nil {
-		return nil
+func (s *Storage) CompanyFilings(ctx context.Context, crn string, asOf time.Time, page storage.Page) ([]storage.Filing, error) {
+	q := query{operation: "company_filings", statement: filingsQuery}
+	args := []interface{}{crn, page.Limit, page.Offset}
+	if !asOf.IsZero() {
+		q.statement = filingsAsOfQuery
+		args = append(args, asOf)
 	}
-	logging.Info(ctx, nil, "reconnecting")
-	pool, err := connect(s.conf)
+	ts := time.Now()
+	var filings []storage.Filing
+	err := s.withRetry(ctx, q, func(ctx context.Context, db pgxscan.Querier) error {
+		filings = nil
+		return pgxscan.Select(ctx, db, &filings, q.statement, args...)
+	})
 	if err != nil {
-		return err
+		logging.Error(ctx, err, logging.Data{"crn": crn}, "filings query error")
+		return nil, queryError(err)
 	}
-	s.pool = pool
-	return nil
+	logging.Info(ctx, logging.Data{"crn": crn, "filings": len(filings), "query_time": time.Since(ts)}, "filings query stats")
+	return filings, nil
 }
 
-func (s *Storage) CompanyData(ctx context.Context, crn string, groups []string) (*storage.Data, error) {
-	if !validateGroups(groups) {
-		return nil, storage.ErrInvalidGroups
+func (s *Storage) CompanyOfficers(ctx context.Context, crn string, asOf time.Time, page storage.Page) ([]storage.Officer, error) {
+	q := query{operation: "company_officers", statement: officersQuery}
+	args := []interface{}{crn, page.Limit, page.Offset}
+	if !asOf.IsZero() {
+		q.statement = officersAsOfQuery
+		args = append(args, asOf)
 	}
-	groups = append(groups, baseGroup)
-	query := generateQuery(groups)
 	ts := time.Now()
-	data := &storage.Data{}
+	var officers []storage.Officer
+	err := s.withRetry(ctx, q, func(ctx context.Context, db pgxscan.Querier) error {
+		officers = nil
+		return pgxscan.Select(ctx, db, &officers, q.statement, args...)
+	})
+	if err != nil {
+		logging.Error(ctx, err, logging.Data{"crn": crn}, "officers query error")
+		return nil, queryError(err)
+	}
+	logging.Info(ctx, logging.Data{"crn": crn, "officers": len(officers), "query_time": time.Since(ts)}, "officers query stats")
+	return officers, nil
+}
+
+func (s *Storage) CompanyVersions(ctx context.Context, crn string) ([]storage.Version, error) {
+	ts := time.Now()
+	var versions []storage.Version
+	err := s.withRetry(ctx, query{operation: "company_versions", statement: versionsQuery}, func(ctx context.Context, db pgxscan.Querier) error {
+		versions = nil
+		return pgxscan.Select(ctx, db, &versions, versionsQuery, crn)
+	})
+	if err != nil {
+		logging.Error(ctx, err, logging.Data{"crn": crn}, "versions query error")
+		return nil, queryError(err)
+	}
+	if len(versions) == 0 {
+		return nil, storage.ErrNotFound
+	}
+	logging.Info(ctx, logging.Data{"crn": crn, "versions": len(versions), "query_time": time.Since(ts)}, "versions query stats")
+	return versions, nil
+}
+
+func (s *Storage) CompanyChanges(ctx context.Context, since time.Time, afterCRN string, until time.Time, limit int) ([]storage.Change, error) {
+	ts := time.Now()
+	var changes []storage.Change
+	err := s.withRetry(ctx, query{operation: "company_changes", statement: changesQuery}, func(ctx context.Context, db pgxscan.Querier) error {
+		changes = nil
+		return pgxscan.Select(ctx, db, &changes, changesQuery, since, afterCRN, until, limit)
+	})
+	if err != nil {
+		logging.Error(ctx, err, logging.Data{"since": since, "after_crn": afterCRN, "until": until}, "changes query error")
+		return nil, queryError(err)
+	}
+	logging.Info(ctx, logging.Data{"since": since, "after_crn": afterCRN, "changes": len(changes), "query_time": time.Since(ts)}, "changes query stats")
+	return changes, nil
+}
 
-	bo := backoff.NewExponentialBackOff()
-	bo.InitialInterval = 50 * time.Millisecond
-	bo.MaxElapsedTime = 10 * time.Second
-	ticker := backoff.NewTicker(bo)
-	var err error
-	for range ticker.C {
-		if err = pgxscan.Get(ctx, s.pool, data, query, crn); err != nil {
-			logging.Error(ctx, err, nil, "query error")
-			switch {
-			case pgxscan.NotFound(err):
-				return nil, storage.ErrNotFound
-			case errors.Is(err, io.ErrUnexpectedEOF):
-				if err := s.reconnect(ctx); err != nil {
-					logging.Error(ctx, err, nil, "failed to reconnect")
-				}
-			default:
-				return nil, storage.ErrStorage
-			}
-		} else {
-			ticker.Stop()
+func (s *Storage) SICHierarchy(ctx context.Context, code string) (*storage.SIC, error) {
+	sic := &storage.SIC{}
+	err := s.withRetry(ctx, query{operation: "sic_hierarchy", statement: sicQuery}, func(ctx context.Context, db pgxscan.Querier) error {
+		return pgxscan.Get(ctx, db, sic, sicQuery, code)
+	})
+	if err != nil {
+		if pgxscan.NotFound(err) {
+			return nil, storage.ErrNotFound
 		}
+		logging.Error(ctx, err, logging.Data{"sic_code": code}, "sic query error")
+		return nil, queryError(err)
 	}
+	return sic, nil
+}
+
+func (s *Storage) GroupsMetadata(ctx context.Context, groups []string, asOf time.Time) ([]storage.GroupMetadata, error) {
+	q := query{operation: "groups_metadata", statement: groupsMetadataQuery}
+	args := []interface{}{groups}
+	if !asOf.IsZero() {
+		q.statement = groupsMetadataAsOfQuery
+		args = append(args, asOf)
+	}
+	var metadata []storage.GroupMetadata
+	err := s.withRetry(ctx, q, func(ctx context.Context, db pgxscan.Querier) error {
+		metadata = nil
+		return pgxscan.Select(ctx, db, &metadata, q.statement, args...)
+	})
 	if err != nil {
-		return nil, storage.ErrStorage
+		logging.Error(ctx, err, logging.Data{"groups": groups}, "groups metadata query error")
+		return nil, queryError(err)
 	}
-	logging.Info(ctx, logging.Data{"crn": crn, "groups": groups, "query_time": time.Since(ts)}, "query stats")
-	return data, nil
+	return metadata, nil
+}
+
+// PartnerEntitlement loads what a partner is entitled to, it's not part of
+// storage.Storage as it's only read by the entitlements package
+func (s *Storage) PartnerEntitlement(ctx context.Context, partnerID string) (*storage.Entitlement, error) {
+	entitlement := &storage.Entitlement{}
+	err := s.withRetry(ctx, query{operation: "partner_entitlement", statement: entitlementQuery}, func(ctx context.Context, db pgxscan.Querier) error {
+		return pgxscan.Get(ctx, db, entitlement, entitlementQuery, partnerID)
+	})
+	if err != nil {
+		if pgxscan.NotFound(err) {
+			return nil, storage.ErrNotFound
+		}
+		logging.Error(ctx, err, logging.Data{"partner_id": partnerID}, "entitlement query error")
+		return nil, queryError(err)
+	}
+	return entitlement, nil
+}
+
+// RecordUsage inserts usage events, the copy isn't retried as it could have
+// been committed before failing and the lookups would be counted twice
+func (s *Storage) RecordUsage(ctx context.Context, events []storage.UsageEvent) (err error) {
+	ctx, span := tracing.Start(ctx, "pg.record_usage", trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		semconv.DBSystemPostgreSQL,
+		semconv.DBOperationKey.String("record_usage"),
+		semconv.DBStatementKey.String("COPY usage_events"),
+		attribute.Int("geospatial.events", len(events)),
+	))
+	defer func() { tracing.End(span, err) }()
+	rows := make([][]interface{}, 0, len(events))
+	for i := range events {
+		e := events[i]
+		rows = append(rows, []interface{}{e.PartnerID, e.Endpoint, e.ResourceType, e.Resource, e.Lookups, e.OccurredAt})
+	}
+	_, err = s.getPool().CopyFrom(ctx, pgx.Identifier{"usage_events"}, usageEventsColumns, pgx.CopyFromRows(rows))
+	if err != nil {
+		logging.Error(ctx, err, logging.Data{"events": len(events)}, "usage insert error")
+		return queryError(err)
+	}
+	return nil
+}
+
+func (s *Storage) UsageSummary(ctx context.Context, partnerID string, from, to time.Time, period string) ([]storage.UsageSummary, error) {
+	var summaries []storage.UsageSummary
+	err := s.withRetry(ctx, query{operation: "usage_summary", statement: usageSummaryQuery}, func(ctx context.Context, db pgxscan.Querier) error {
+		summaries = nil
+		return pgxscan.Select(ctx, db, &summaries, usageSummaryQuery, partnerID, period, from, to)
+	})
+	if err != nil {
+		logging.Error(ctx, err, logging.Data{"partner_id": partnerID, "from": from, "to": to, "period": period}, "usage summary query error")
+		return nil, queryError(err)
+	}
+	return summaries, nil
 }
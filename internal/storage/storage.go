@@ -1,7 +1,30 @@
 package storage
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 type Storage interface {
-	CompanyData(ctx context.Context, crn string, groups []string) (*Data, error)
+	// CompanyData retrieves the current company's data, or the data as it was
+	// at asOf when it is not zero
+	CompanyData(ctx context.Context, crn string, groups []string, fields []string, asOf time.Time) (*Data, error)
+	CompanyVersions(ctx context.Context, crn string) ([]Version, error)
+	// GroupsMetadata returns the latest load of each of the given groups, or
+	// the latest load before asOf when it is not zero. Groups never loaded are
+	// left out
+	GroupsMetadata(ctx context.Context, groups []string, asOf time.Time) ([]GroupMetadata, error)
+	// CompanyChanges lists the companies updated after (since, afterCRN) and
+	// before until, ordered by update time and crn
+	CompanyChanges(ctx context.Context, since time.Time, afterCRN string, until time.Time, limit int) ([]Change, error)
+	// CompanyFilings lists the company's filings, only the ones filed by asOf
+	// when it is not zero
+	CompanyFilings(ctx context.Context, crn string, asOf time.Time, page Page) ([]Filing, error)
+	// CompanyOfficers lists the company's officers, as they were at asOf when
+	// it is not zero: officers appointed later are left out and resignations
+	// after asOf aren't reported
+	CompanyOfficers(ctx context.Context, crn string, asOf time.Time, page Page) ([]Officer, error)
+	// SICHierarchy looks a code up in the SIC 2007 classification, which
+	// doesn't change over time so it holds whatever the point in time
+	SICHierarchy(ctx context.Context, code string) (*SIC, error)
 }
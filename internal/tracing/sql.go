@@ -0,0 +1,22 @@
+package tracing
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	// string literals, with '' escaping a quote
+	sqlStrings = regexp.MustCompile(`'(?:[^']|'')*'`)
+	// numbers that aren't part of an identifier nor a $n placeholder
+	sqlNumbers = regexp.MustCompile(`([^\w$.]|^)-?\d+(?:\.\d+)?`)
+	sqlSpaces  = regexp.MustCompile(`\s+`)
+)
+
+// SanitizeSQL replaces the literals of a statement with ? so values don't
+// leak into the traces, and collapses its whitespace
+func SanitizeSQL(statement string) string {
+	statement = sqlStrings.ReplaceAllString(statement, "?")
+	statement = sqlNumbers.ReplaceAllString(statement, "${1}?")
+	return strings.TrimSpace(sqlSpaces.ReplaceAllString(statement, " "))
+}
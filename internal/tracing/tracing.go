@@ -0,0 +1,93 @@
+// Package tracing sets up the OpenTelemetry tracing of the service and
+// provides the helpers the handler, the storage and the client use to
+// create their spans
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/cytora/geospatial-lambda/internal/config"
+)
+
+// InstrumentationName names the tracer of the service's spans
+const InstrumentationName = "github.com/cytora/geospatial-lambda"
+
+// Propagator carries the W3C trace context and baggage across the
+// services, it's used whether a caller registered a global one or not
+var Propagator = propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+
+var (
+	ErrTracing         = errors.New("tracing error")
+	ErrUnknownExporter = fmt.Errorf("%w unknown exporter", ErrTracing)
+)
+
+// Setup registers the tracer provider of the exporter configured and
+// Propagator. Spans aren't exported with the none
+// exporter, the context is still propagated. The provider returned must be
+// flushed at the end of every invocation: Lambda freezes the process in
+// between and may end it without the provider being shut down.
+func Setup(ctx context.Context, conf *config.Config) (*sdktrace.TracerProvider, error) {
+	otel.SetTextMapPropagator(Propagator)
+	res := resource.NewWithAttributes(semconv.SchemaURL,
+		semconv.ServiceNameKey.String(conf.Service),
+		semconv.ServiceVersionKey.String(conf.Version),
+		semconv.DeploymentEnvironmentKey.String(conf.Env),
+	)
+	opts := []sdktrace.TracerProviderOption{
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(conf.TracingSampleRatio))),
+	}
+	switch conf.TracingExporter {
+	case config.TracingNone:
+	case config.TracingStdout:
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+		if err != nil {
+			return nil, err
+		}
+		// local runs, spans are written as soon as they end
+		opts = append(opts, sdktrace.WithSyncer(exporter))
+	case config.TracingOTLP:
+		var clientOpts []otlptracehttp.Option
+		if conf.TracingEndpoint != "" {
+			clientOpts = append(clientOpts, otlptracehttp.WithEndpoint(conf.TracingEndpoint))
+		}
+		exporter, err := otlptracehttp.New(ctx, clientOpts...)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, sdktrace.WithBatcher(exporter))
+	default:
+		return nil, fmt.Errorf("%w %q", ErrUnknownExporter, conf.TracingExporter)
+	}
+	provider := sdktrace.NewTracerProvider(opts...)
+	otel.SetTracerProvider(provider)
+	return provider, nil
+}
+
+// Start starts a span with the service's tracer, the span is a child of the
+// one in ctx if any
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return otel.Tracer(InstrumentationName).Start(ctx, name, opts...)
+}
+
+// End ends span, marking it as failed when err isn't nil
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
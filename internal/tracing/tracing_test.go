@@ -0,0 +1,65 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestSanitizeSQL(t *testing.T) {
+	tests := []struct {
+		name      string
+		statement string
+		want      string
+	}{
+		{
+			name:      "placeholders kept",
+			statement: "select * from companies where crn = $1 limit $2",
+			want:      "select * from companies where crn = $1 limit $2",
+		},
+		{
+			name:      "strings",
+			statement: "select * from companies where crn = '00111222' and name = 'it''s'",
+			want:      "select * from companies where crn = ? and name = ?",
+		},
+		{
+			name:      "numbers",
+			statement: "select v2.a from t2 where x > 10.5 limit 3",
+			want:      "select v2.a from t2 where x > ? limit ?",
+		},
+		{
+			name:      "whitespace",
+			statement: "\n\tselect a\n\t\tfrom t\n",
+			want:      "select a from t",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, SanitizeSQL(tt.statement))
+		})
+	}
+}
+
+func TestEnd(t *testing.T) {
+	rec := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec)))
+
+	_, span := Start(context.Background(), "ok")
+	End(span, nil)
+	_, span = Start(context.Background(), "failed")
+	End(span, errors.New("oops"))
+
+	spans := rec.Ended()
+	if assert.Len(t, spans, 2) {
+		assert.Equal(t, codes.Unset, spans[0].Status().Code, "unexpected status of the successful span")
+		assert.Equal(t, codes.Error, spans[1].Status().Code, "unexpected status of the failed span")
+		assert.Equal(t, "oops", spans[1].Status().Description, "unexpected status description")
+		assert.Len(t, spans[1].Events(), 1, "expected the error to be recorded")
+	}
+}
@@ -0,0 +1,52 @@
+'''
+purpose: fail when the FastAPI app in api/ serves a route, or a query parameter of a route, that
+docs/openapi.json doesn't document. The served OpenAPI document is generated from the Go routes,
+handler.GeospatialRoutes mirrors the Python ones so it has to be updated with them.
+
+usage: python scripts/check_openapi.py
+'''
+import json
+import os
+import sys
+
+ROOT = os.path.dirname(os.path.dirname(os.path.abspath(__file__)))
+sys.path.insert(0, os.path.join(ROOT, 'api'))
+
+
+def query_params(operation: dict) -> set:
+    return {p['name'] for p in operation.get('parameters', []) if p.get('in') == 'query'}
+
+
+def undocumented(served: dict, documented: dict) -> list:
+    '''
+    returns the operations, or their query parameters, of served missing from documented
+    '''
+    missing = []
+    for path, item in served.get('paths', {}).items():
+        for method, operation in item.items():
+            documented_operation = documented.get('paths', {}).get(path, {}).get(method)
+            if documented_operation is None:
+                missing.append(f'{method.upper()} {path}')
+                continue
+            for name in sorted(query_params(operation) - query_params(documented_operation)):
+                missing.append(f'{method.upper()} {path} query parameter {name}')
+    return missing
+
+
+def main() -> int:
+    from fastapi import FastAPI
+    from v1.routers import router
+
+    # api/main.py's app without its root route, which isn't part of the API
+    app = FastAPI()
+    app.include_router(router, prefix='/v1')
+    with open(os.path.join(ROOT, 'docs', 'openapi.json')) as f:
+        documented = json.load(f)
+    missing = undocumented(app.openapi(), documented)
+    for m in missing:
+        print(f'undocumented: {m}', file=sys.stderr)
+    return 1 if missing else 0
+
+
+if __name__ == '__main__':
+    sys.exit(main())
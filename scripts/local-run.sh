@@ -61,6 +61,14 @@ services:
       - "4566:4566"
     volumes:
       - /var/run/docker.sock:/var/run/docker.sock
+  postgis:
+    image: postgis/postgis:11-3.1
+    environment:
+      - POSTGRES_USER=postgres
+      - POSTGRES_PASSWORD=postgres
+      - POSTGRES_DB=geospatial
+    ports:
+      - "5432:5432"
 EOF
 )
 
@@ -151,7 +159,8 @@ create_sam_template() {
 # Input:
 #   $1 = path to service-spec.yml
 # Output:
-#   0: if docker-compose is needed.  This means service-spec.yml specifies dynamo tables or SNS topics.
+#   0: if docker-compose is needed.  This means service-spec.yml specifies dynamo tables or SNS topics,
+#      or LOCAL_POSTGIS is set to run the database locally.
 #   1: otherwise
 need_docker_compose() {
   local service_spec_file="$1"
@@ -159,6 +168,10 @@ need_docker_compose() {
     echo "Service specfile \"$service_spec_file\" not found"
   fi
 
+  if [[ "$LOCAL_POSTGIS" == "true" ]]; then
+    return 0
+  fi
+
   if [[ "$(yq r "$service_spec_file" --length 'spec.databases')" -gt 0 ]]; then
     return 0
   fi
@@ -197,6 +210,17 @@ check_aws_services_ready() {
   return 0
 }
 
+# Output:
+#   1: if PostGIS isn't accepting connections
+#   0: otherwise
+check_postgis_ready() {
+  docker-compose -f <(echo "$DOCKER_COMPOSE_TEMPLATE") exec -T postgis pg_isready -U postgres 2>/dev/null 1>&2 || {
+    echo "PostGIS not ready"
+    return 1
+  }
+  return 0
+}
+
 # Starts docker-compose in background mode, and also print logs in background.
 start_docker_compose() {
   stop_docker_compose
@@ -224,6 +248,19 @@ start_docker_compose() {
     echo "docker-compose stopped!"
     exit 1
   fi
+  if [[ "$LOCAL_POSTGIS" == "true" ]]; then
+    counter=0
+    while ! check_postgis_ready && ((counter++ < 20)); do
+      echo "Waiting for PostGIS to become ready, retry $counter ..."
+      sleep 1
+    done
+    if ! check_postgis_ready; then
+      echo "PostGIS failed to become ready, stopping docker-compose ..."
+      stop_docker_compose
+      echo "docker-compose stopped!"
+      exit 1
+    fi
+  fi
   echo "docker-compose started!"
 }
 